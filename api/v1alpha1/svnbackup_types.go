@@ -0,0 +1,214 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SVNBackupSpec defines the desired state of SVNBackup
+type SVNBackupSpec struct {
+	// +kubebuilder:validation:Required
+	// SVNServer is the SVNServer to back up.
+	// It must be in the same namespace as the SVNBackup, since the backup Job mounts the volume of the server.
+	SVNServer SVNServerRef `json:"svnServer,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9._-]*$"
+	// Repository is the name of the repository on the server to back up.
+	// Repositories of SVNRepositories in other namespaces than the server are named `namespace_name`.
+	// If not specified, all repositories on the server are backed up.
+	Repository string `json:"repository,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Dump;Hotcopy
+	// Method is how repositories are backed up. Defaults to Dump.
+	// Dump writes the output of `svnadmin dump` compressed with gzip, which any version of Subversion can load.
+	// Hotcopy copies repositories with `svnadmin hotcopy`, which is faster to restore and keeps hooks and configurations.
+	Method string `json:"method,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// IncrementalFrom is the name of a succeeded SVNBackup in the same namespace that uses the Dump method.
+	// If specified, only revisions after the ones captured by it are dumped with `svnadmin dump --incremental`.
+	// This is only available with the Dump method.
+	IncrementalFrom string `json:"incrementalFrom,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// Target is where the backup is stored.
+	Target BackupTarget `json:"target,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// DeletionPolicy specifies what happens to the files of the backup when the SVNBackup is deleted.
+	// Defaults to Retain.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// BackupTarget is a location to store backups in. Exactly one of its fields must be specified.
+type BackupTarget struct {
+	// +kubebuilder:validation:Optional
+	// PersistentVolumeClaim stores backups in a PersistentVolumeClaim in the same namespace as the SVNBackup.
+	PersistentVolumeClaim *PersistentVolumeClaimBackupTarget `json:"persistentVolumeClaim,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// S3 stores backups in a bucket of an S3-compatible object storage such as Amazon S3 and MinIO.
+	// Dumps are uploaded as they are made, so they need no scratch space. Only the Dump method is available.
+	S3 *S3BackupTarget `json:"s3,omitempty"`
+}
+
+// PersistentVolumeClaimBackupTarget is a directory in a PersistentVolumeClaim.
+type PersistentVolumeClaimBackupTarget struct {
+	// +kubebuilder:validation:Required
+	// ClaimName is the name of the PersistentVolumeClaim.
+	ClaimName string `json:"claimName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Path is a directory relative to the root of the volume.
+	// Each backup is stored in a subdirectory named after the SVNBackup.
+	Path string `json:"path,omitempty"`
+}
+
+// S3BackupTarget is a prefix of keys in a bucket of an S3-compatible object storage.
+type S3BackupTarget struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="^https?://"
+	// Endpoint is the URL of the storage, such as `https://s3.us-east-1.amazonaws.com` or `http://minio.minio:9000`.
+	// Buckets are accessed with path-style URLs.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Region is the region of the bucket. Defaults to us-east-1.
+	Region string `json:"region,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// Bucket is the name of the bucket.
+	Bucket string `json:"bucket,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Prefix is prepended to the keys of objects.
+	// Each backup is stored under `<prefix>/<name of the SVNBackup>/`.
+	Prefix string `json:"prefix,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// CredentialsSecret is a Secret in the same namespace as the SVNBackup that contains the credentials of the bucket
+	// in `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY` and optionally `AWS_SESSION_TOKEN`.
+	CredentialsSecret corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+}
+
+// Here is a list of allowed values of SVNBackupSpec.Method.
+const (
+	// BackupMethodDump backs up repositories with `svnadmin dump`.
+	BackupMethodDump = "Dump"
+
+	// BackupMethodHotcopy backs up repositories with `svnadmin hotcopy`.
+	BackupMethodHotcopy = "Hotcopy"
+)
+
+// Here is a list of phases of SVNBackups.
+const (
+	// BackupPhasePending means the backup is waiting for the server or the backup it is based on.
+	BackupPhasePending = "Pending"
+
+	// BackupPhaseRunning means the backup Job is running.
+	BackupPhaseRunning = "Running"
+
+	// BackupPhaseSucceeded means the backup has been stored in the target.
+	BackupPhaseSucceeded = "Succeeded"
+
+	// BackupPhaseFailed means the backup has failed. Failed backups are not retried.
+	BackupPhaseFailed = "Failed"
+)
+
+// SVNBackupStatus defines the observed state of SVNBackup
+type SVNBackupStatus struct {
+	// +kubebuilder:validation:Optional
+	// Phase is one of `Pending`, `Running`, `Succeeded` and `Failed`.
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message is the reason why the backup is pending or has failed.
+	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// JobName is the name of the Job that runs the backup.
+	JobName string `json:"jobName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the backup Job was created.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the backup succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Path is the directory of the backup relative to the root of the target, or the prefix of the keys of its objects.
+	Path string `json:"path,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Repositories is the range of revisions captured for each repository.
+	Repositories []BackupRepositoryStatus `json:"repositories,omitempty"`
+}
+
+// BackupRepositoryStatus is the range of revisions of a repository captured by a backup.
+type BackupRepositoryStatus struct {
+	// Name is the name of the repository on the server.
+	Name string `json:"name"`
+
+	// FromRevision is the first captured revision.
+	// It is greater than ToRevision if an incremental backup has found no new revisions.
+	FromRevision int64 `json:"fromRevision"`
+
+	// ToRevision is the last captured revision.
+	ToRevision int64 `json:"toRevision"`
+
+	// +kubebuilder:validation:Optional
+	// File is the path to the backup of the repository relative to Path.
+	// It is empty if there is nothing to back up.
+	File string `json:"file,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.svnServer.name`
+// +kubebuilder:printcolumn:name="Repository",type=string,JSONPath=`.spec.repository`
+// +kubebuilder:printcolumn:name="Method",type=string,JSONPath=`.spec.method`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SVNBackup is the Schema for the svnbackups API
+type SVNBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SVNBackupSpec   `json:"spec,omitempty"`
+	Status SVNBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SVNBackupList contains a list of SVNBackup
+type SVNBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SVNBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SVNBackup{}, &SVNBackupList{})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SVNBackupScheduleSpec defines the desired state of SVNBackupSchedule
+type SVNBackupScheduleSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// Schedule is a cron expression in UTC (e.g. `0 3 * * *`). Macros such as `@daily` are also accepted.
+	// Missed schedules are not caught up except for the last one.
+	Schedule string `json:"schedule,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Suspend stops creating new SVNBackups.
+	Suspend bool `json:"suspend,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// Retention is the number of succeeded SVNBackups to keep. Older ones are deleted,
+	// except for ones that the kept incremental backups are based on.
+	// Whether their files are deleted as well depends on BackupTemplate.DeletionPolicy.
+	Retention int32 `json:"retention,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// FullBackupInterval is the number of backups per full backup with the Dump method.
+	// The others are incremental from the previous backup. Defaults to 1, which means every backup is full.
+	FullBackupInterval int32 `json:"fullBackupInterval,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// BackupTemplate is the spec of SVNBackups created by the schedule.
+	// Its IncrementalFrom is ignored in favor of FullBackupInterval.
+	BackupTemplate SVNBackupSpec `json:"backupTemplate,omitempty"`
+}
+
+// SVNBackupScheduleStatus defines the observed state of SVNBackupSchedule
+type SVNBackupScheduleStatus struct {
+	// +kubebuilder:validation:Optional
+	Conditions []Condition `json:"conditions"`
+
+	// +kubebuilder:validation:Optional
+	// LastScheduleTime is the last time an SVNBackup was scheduled.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastBackupName is the name of the last SVNBackup created by the schedule.
+	LastBackupName string `json:"lastBackupName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastSuccessfulBackupName is the name of the last succeeded SVNBackup created by the schedule.
+	LastSuccessfulBackupName string `json:"lastSuccessfulBackupName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+// +kubebuilder:printcolumn:name="Last Successful Backup",type=string,JSONPath=`.status.lastSuccessfulBackupName`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SVNBackupSchedule is the Schema for the svnbackupschedules API
+type SVNBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SVNBackupScheduleSpec   `json:"spec,omitempty"`
+	Status SVNBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SVNBackupScheduleList contains a list of SVNBackupSchedule
+type SVNBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SVNBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SVNBackupSchedule{}, &SVNBackupScheduleList{})
+}
@@ -26,22 +26,54 @@ import (
 // SVNGroupSpec defines the desired state of SVNGroup
 type SVNGroupSpec struct {
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9.-]*$"
-	// The name of the SVNServer
-	SVNServer string `json:"svnServer,omitempty"`
+	// The SVNServer that the SVNGroup belongs to.
+	SVNServer SVNServerRef `json:"svnServer,omitempty"`
 
 	// +kubebuilder:validation:Required
 	// The permissions that the group have.
 	Permissions []Permission `json:"permissions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Groups is a list of SVNGroups that are members of the group.
+	// Members of these groups are also members of the group.
+	Groups []GroupRef `json:"groups,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// MemberSelector selects SVNUsers that are members of the group by their labels.
+	// The selected SVNUsers must reside in the same namespace as the group, and must belong to the same SVNServer.
+	// They are members of the group in addition to the SVNUsers that refer to the group in their `groups` field.
+	// An empty selector selects all SVNUsers, while a null selector selects nothing.
+	MemberSelector *metav1.LabelSelector `json:"memberSelector,omitempty"`
 }
 
+// Permission is a permission to access to SVNRepositories.
+//
+// The permission applies to all SVNRepositories specified by either Repository, RepositorySelector or AllRepositories.
 type Permission struct {
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9.-]*$"
 	// The name of the SVNRepository to give access to.
-	// The SVNRepository must reside in the same namespace as the SVNGroup.
+	// The SVNRepository must reside in the same namespace as the SVNGroup or the SVNUser.
 	Repository string `json:"repository,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// RepositorySelector selects SVNRepositories to give access to by their labels.
+	// The selected SVNRepositories must reside in the same namespace as the SVNGroup or the SVNUser,
+	// and must belong to the same SVNServer.
+	RepositorySelector *metav1.LabelSelector `json:"repositorySelector,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AllRepositories gives access to all SVNRepositories on the SVNServer
+	// that reside in the same namespace as the SVNGroup or the SVNUser if set to true.
+	AllRepositories bool `json:"allRepositories,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(?:/|(?:/[^/\[\]\x00-\x1f\x7f]+)+)$`
+	// The path inside the repository that the permission applies to.
+	// The path must be absolute and must not end with a slash.
+	// If not specified, the permission applies to the whole repository.
+	Path string `json:"path,omitempty"`
+
 	// +kubebuilder:validation:Pattern="^(?:r|rw|)$"
 	// The permission to access to the repository.
 	Permission string `json:"permission,omitempty"`
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,24 +28,425 @@ import (
 // SVNRepositorySpec defines the desired state of SVNRepository
 type SVNRepositorySpec struct {
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9.-]*$"
-	// The name of the SVNServer
-	SVNServer string `json:"svnServer,omitempty"`
+	// The SVNServer that the SVNRepository belongs to.
+	SVNServer SVNServerRef `json:"svnServer,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=none;r
+	// AnonymousAccess is a permission given to users who are not logged in.
+	// If set to `r`, anyone can read from the repository without credentials, while writes still need them.
+	// Defaults to `none`.
+	AnonymousAccess string `json:"anonymousAccess,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DefaultPermissions overrides SVNServer's DefaultPermissions for this repository.
+	DefaultPermissions *DefaultPermissions `json:"defaultPermissions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Retain;Archive;Delete
+	// DeletionPolicy specifies what happens to the actual repository when the SVNRepository is deleted.
+	// Defaults to `Retain`.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// InitialLayout is a set of directories that are committed as revision 1 when the repository is created.
+	// It has no effect on repositories that already exist.
+	InitialLayout *InitialLayout `json:"initialLayout,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Seed is a set of files that are committed as revision 1 along with InitialLayout when the repository is created.
+	// It has no effect on repositories that already exist.
+	Seed *Seed `json:"seed,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Source is the history that the repository is created from.
+	// It cannot be used with InitialLayout nor Seed.
+	// A dump file has no effect on repositories that already exist, while a mirror keeps being synchronized.
+	Source *RepositorySource `json:"source,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Hooks is a set of hook scripts installed into the repository.
+	// Hooks that are not declared here are removed from the repository.
+	Hooks *Hooks `json:"hooks,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CommitPolicy is a set of checks applied to every commit by the built-in pre-commit hook.
+	// The checks run before the pre-commit hook in Hooks, if any.
+	CommitPolicy *CommitPolicy `json:"commitPolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Quota limits the size of the repository. The size is measured periodically,
+	// and commits are rejected once it reaches the quota.
+	Quota *Quota `json:"quota,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ReadOnly freezes the repository. All permissions to the repository are reduced to at most `r`,
+	// and the built-in pre-commit and pre-lock hooks reject writes with ReadOnlyMessage.
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ReadOnlyMessage tells users why the repository is read-only (e.g. `Frozen for the migration until 5/1`).
+	ReadOnlyMessage string `json:"readOnlyMessage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=fsfs;fsx
+	// FSType is the type of the filesystem of the repository. Defaults to the default of `svnadmin create`, which is fsfs.
+	// This is used only when the repository is created, including when it is restored from dump files.
+	FSType string `json:"fsType,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^1\.[0-9]+(\.[0-9]+)?$`
+	// CompatibleVersion is the oldest version of Subversion that can read the repository (e.g. `1.8`),
+	// which keeps the repository readable by older clients and replicas.
+	// This is used only when the repository is created, including when it is restored from dump files.
+	CompatibleVersion string `json:"compatibleVersion,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+	// UUID is the UUID of the repository, which is useful to recreate a repository that clients have checked out.
+	// Unlike the other creation options, it is also set to existing repositories with `svnadmin setuuid`.
+	UUID string `json:"uuid,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// FSFS is a set of tuning options of FSFS, which are written into `db/fsfs.conf`.
+	// This is used only when the repository is created, and only if FSType is fsfs.
+	FSFS *FSFSOptions `json:"fsfs,omitempty"`
+}
+
+// FSFSOptions is a set of tuning options of FSFS.
+type FSFSOptions struct {
+	// +kubebuilder:validation:Optional
+	// EnableRepSharing enables the deduplication of identical file contents. Defaults to true.
+	EnableRepSharing *bool `json:"enableRepSharing,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RevPropPacking configures how revision properties are packed by `svnadmin pack`.
+	RevPropPacking *RevPropPacking `json:"revPropPacking,omitempty"`
+}
+
+// RevPropPacking configures how revision properties are packed.
+type RevPropPacking struct {
+	// +kubebuilder:validation:Optional
+	// PackSize is the maximum size of a pack file of revision properties (e.g. `64Ki`).
+	// It is rounded down to kibibytes.
+	PackSize *resource.Quantity `json:"packSize,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Compress compresses pack files of revision properties.
+	Compress *bool `json:"compress,omitempty"`
+}
+
+// CommitPolicy is a set of common checks of commits. Commits that fail any of them are rejected
+// with messages telling which rules they violate.
+type CommitPolicy struct {
+	// +kubebuilder:validation:Optional
+	// LogMessagePattern is a regular expression in RE2 syntax that log messages must match (e.g. `^[A-Z]+-[0-9]+: `).
+	LogMessagePattern string `json:"logMessagePattern,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MinLogMessageLength is the minimum number of characters of log messages, excluding surrounding spaces.
+	MinLogMessageLength int32 `json:"minLogMessageLength,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// MaxFileSize is the maximum size of each added or modified file (e.g. `10Mi`).
+	MaxFileSize *resource.Quantity `json:"maxFileSize,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RejectCaseCollisions rejects new paths that differ from other paths in the same directory only in case,
+	// which cannot be checked out on case-insensitive file systems.
+	RejectCaseCollisions bool `json:"rejectCaseCollisions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// BlockedPaths is a list of glob patterns of paths that cannot be added nor modified.
+	// Patterns without slashes match base names (e.g. `*.exe`), and others match paths from the root
+	// (e.g. `/trunk/build`). Paths in matching directories are blocked as well.
+	BlockedPaths []string `json:"blockedPaths,omitempty"`
+}
+
+// Hooks is a set of hook scripts taken from a ConfigMap.
+type Hooks struct {
+	// +kubebuilder:validation:Required
+	// ConfigMapName is the name of the ConfigMap in the same namespace as the SVNRepository.
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Items maps hook names to keys of the ConfigMap.
+	// If not specified, every key of the ConfigMap must be a hook name and is installed as that hook.
+	Items []HookItem `json:"items,omitempty"`
+}
+
+// HookItem maps a key of a ConfigMap to a hook of a repository.
+type HookItem struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=start-commit;pre-commit;post-commit;pre-lock;post-lock;pre-unlock;post-unlock;pre-revprop-change;post-revprop-change
+	// Name is the name of the hook.
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Key is the key of the ConfigMap that contains the script. Defaults to Name.
+	// The script is run without any environment variables, so it should start with a shebang line
+	// and use absolute paths.
+	Key string `json:"key,omitempty"`
+}
+
+// RepositorySource is the history that a repository is created from.
+type RepositorySource struct {
+	// +kubebuilder:validation:Optional
+	// Dump is a dump file created by `svnadmin dump`.
+	Dump *DumpSource `json:"dump,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Mirror is an upstream repository that the repository replicates with `svnsync`.
+	// It cannot be used with Dump.
+	Mirror *MirrorSource `json:"mirror,omitempty"`
+}
+
+// MirrorSource is an upstream repository that is replicated periodically with `svnsync`.
+//
+// Mirrors are read-only: all permissions to them are reduced to at most `r`, and the built-in hooks
+// reject commits, locks and changes of revision properties by anyone but svnsync.
+// The credentials Secret is mounted to the SVN server, so it must be in the same namespace as the SVNServer,
+// and the SVNRepository must be in that namespace too.
+// Note that mounting the Secret restarts the SVN server.
+type MirrorSource struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^(https?|svn)://`
+	// URL is the URL of the upstream repository (e.g. `https://svn.example.com/repos/project`).
+	URL string `json:"url,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CredentialsSecret is a Secret that has `username` and `password` to access the upstream repository.
+	// The upstream repository is accessed anonymously if not specified.
+	// Only http and https URLs support credentials.
+	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Interval is the interval between synchronizations (e.g. `10m`). Defaults to `5m`.
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
+// DumpSource is a dump file in a PersistentVolumeClaim or a ConfigMap.
+// Exactly one of PersistentVolumeClaim and ConfigMap must be specified.
+//
+// The volume is mounted to the SVN server, so it must be in the same namespace as the SVNServer,
+// and the SVNRepository must be in that namespace too.
+// Note that mounting the volume restarts the SVN server.
+type DumpSource struct {
+	// +kubebuilder:validation:Optional
+	// PersistentVolumeClaim is a PVC that contains the dump file.
+	PersistentVolumeClaim *PersistentVolumeClaimDumpSource `json:"persistentVolumeClaim,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ConfigMap is a ConfigMap that contains the dump file.
+	ConfigMap *ConfigMapDumpSource `json:"configMap,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=auto;none;gzip;bzip2;xz
+	// Compression is the compression format of the dump file.
+	// Defaults to `auto`, which detects the format from the content.
+	Compression string `json:"compression,omitempty"`
+}
+
+// PersistentVolumeClaimDumpSource is a dump file in a PersistentVolumeClaim.
+type PersistentVolumeClaimDumpSource struct {
+	// +kubebuilder:validation:Required
+	// ClaimName is the name of the PersistentVolumeClaim.
+	ClaimName string `json:"claimName,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// Path is a path to the dump file relative to the root of the volume.
+	Path string `json:"path,omitempty"`
+}
+
+// ConfigMapDumpSource is a dump file in a ConfigMap.
+type ConfigMapDumpSource struct {
+	// +kubebuilder:validation:Required
+	// Name is the name of the ConfigMap.
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// Key is the key of the dump file in the ConfigMap. Compressed dump files should be put in `binaryData`.
+	Key string `json:"key,omitempty"`
+}
+
+// InitialLayout is a set of directories that new repositories start with.
+type InitialLayout struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=standard;none
+	// Preset is a predefined set of directories.
+	// `standard` creates `trunk`, `branches` and `tags`. Defaults to `none`.
+	Preset string `json:"preset,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Directories is a list of directories relative to the root of the repository (e.g. `trunk/docs`).
+	// They are created in addition to the directories of Preset.
+	Directories []string `json:"directories,omitempty"`
+}
+
+// Here is a list of allowed values of InitialLayout.Preset.
+const (
+	// LayoutPresetStandard is the standard layout of SVN repositories, that is, `trunk`, `branches` and `tags`.
+	LayoutPresetStandard = "standard"
+
+	// LayoutPresetNone creates no directories.
+	LayoutPresetNone = "none"
+)
+
+// Seed is a set of files taken from a ConfigMap.
+type Seed struct {
+	// +kubebuilder:validation:Required
+	// ConfigMapName is the name of the ConfigMap in the same namespace as the SVNRepository.
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Items maps keys of the ConfigMap to paths in the repository.
+	// If not specified, every key is committed to the root of the repository with the same name.
+	Items []SeedItem `json:"items,omitempty"`
+}
+
+// SeedItem maps a key of a ConfigMap to a file in a repository.
+type SeedItem struct {
+	// +kubebuilder:validation:Required
+	// Key is the key of the ConfigMap.
+	Key string `json:"key,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Path is a path relative to the root of the repository (e.g. `trunk/README.md`).
+	// Parent directories are created automatically. Defaults to Key.
+	Path string `json:"path,omitempty"`
+}
+
+// Here is a list of allowed values of SVNRepositorySpec.AnonymousAccess.
+const (
+	// AnonymousAccessNone means anonymous users cannot access the repository.
+	AnonymousAccessNone = "none"
+
+	// AnonymousAccessR means anonymous users can read from the repository.
+	AnonymousAccessR = "r"
+)
+
+// Here is a list of allowed values of SVNRepositorySpec.DeletionPolicy.
+const (
+	// DeletionPolicyRetain leaves the repository as is.
+	// You can restore it by recreating the SVNRepository.
+	DeletionPolicyRetain = "Retain"
+
+	// DeletionPolicyArchive moves the repository to a timestamped directory in the trash folder of the volume.
+	DeletionPolicyArchive = "Archive"
+
+	// DeletionPolicyDelete removes the repository.
+	DeletionPolicyDelete = "Delete"
+)
+
+// Here is a list of allowed values of SVNRepositorySpec.FSType.
+const (
+	// FSTypeFSFS is the default filesystem of Subversion.
+	FSTypeFSFS = "fsfs"
+
+	// FSTypeFSX is the experimental successor of FSFS.
+	FSTypeFSX = "fsx"
+)
+
 // SVNRepositoryStatus defines the observed state of SVNRepository
 type SVNRepositoryStatus struct {
 	// +Kubebuilder:validation:Optional
 	Conditions []Condition `json:"conditions"`
+
+	// +kubebuilder:validation:Optional
+	// Load is the progress of loading Spec.Source.Dump.
+	Load *LoadStatus `json:"load,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Mirror is the progress of synchronizing Spec.Source.Mirror.
+	Mirror *MirrorStatus `json:"mirror,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Usage is the size of the repository. It is reported only if the repository or its SVNServer has a quota.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Repository is the state of the actual repository reported by the SVN server.
+	// It is not reported until the repository is created on the server.
+	Repository *RepositoryInfo `json:"repository,omitempty"`
+}
+
+// RepositoryInfo is the state of an actual repository on an SVN server.
+type RepositoryInfo struct {
+	// Revision is the youngest revision.
+	Revision int64 `json:"revision"`
+
+	// +kubebuilder:validation:Optional
+	// UUID is the UUID of the repository.
+	UUID string `json:"uuid,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Size is the size of the repository on disk, which is measured periodically.
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// FSType is the type of the filesystem of the repository (e.g. `fsfs`).
+	FSType string `json:"fsType,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// FSFormat is the format number of the filesystem of the repository.
+	FSFormat int32 `json:"fsFormat,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastChangedAuthor is the author of the youngest revision.
+	LastChangedAuthor string `json:"lastChangedAuthor,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastChangedTime is the date of the youngest revision.
+	LastChangedTime *metav1.Time `json:"lastChangedTime,omitempty"`
+}
+
+// LoadStatus is the progress of loading a dump file.
+type LoadStatus struct {
+	// Phase is one of `Loading`, `Succeeded` and `Failed`.
+	// Failed loads are retried periodically from the last loaded revision.
+	Phase string `json:"phase"`
+
+	// LastLoadedRevision is the last revision that has been loaded.
+	LastLoadedRevision int64 `json:"lastLoadedRevision"`
+
+	// +kubebuilder:validation:Optional
+	// Message is the reason of the failure.
+	Message string `json:"message,omitempty"`
+}
+
+// MirrorStatus is the progress of synchronizing a mirror.
+type MirrorStatus struct {
+	// LastSyncedRevision is the last revision that has been copied from the upstream repository.
+	LastSyncedRevision int64 `json:"lastSyncedRevision"`
+
+	// +kubebuilder:validation:Optional
+	// LastSyncTime is when the last successful synchronization finished.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message is the reason of the failure of the last synchronization.
+	// Failed synchronizations are retried at the next interval.
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.svnServer.name`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.repository.revision`
+// +kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.repository.size`
+// +kubebuilder:printcolumn:name="Last Author",type=string,JSONPath=`.status.repository.lastChangedAuthor`
+// +kubebuilder:printcolumn:name="Last Changed",type=date,JSONPath=`.status.repository.lastChangedTime`
+// +kubebuilder:printcolumn:name="UUID",type=string,JSONPath=`.status.repository.uuid`,priority=1
+// +kubebuilder:printcolumn:name="FS Type",type=string,JSONPath=`.status.repository.fsType`,priority=1
+// +kubebuilder:printcolumn:name="FS Format",type=integer,JSONPath=`.status.repository.fsFormat`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // SVNRepository is the Schema for the svnrepositories API
 //
-// The svn-operator does not delete actual repositories if SVNRepository resources are deleted. In such case, you can restore repositories by recreating SVNRepository resources.
-// Currently we do not provide methods to configure this behavior.
+// What happens to the actual repository when the SVNRepository is deleted depends on its DeletionPolicy.
+// By default, the svn-operator does not delete actual repositories, so you can restore them by recreating SVNRepository resources.
+// Otherwise the SVNRepository is kept by a finalizer until the SVN server archives or deletes the repository.
 type SVNRepository struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SVNRestoreSpec defines the desired state of SVNRestore
+type SVNRestoreSpec struct {
+	// +kubebuilder:validation:Required
+	// SVNServer is the SVNServer to restore the repository on.
+	// It must be in the same namespace as the SVNRestore, since the restore Job mounts the volume of the server.
+	SVNServer SVNServerRef `json:"svnServer,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// BackupName is the name of a succeeded SVNBackup in the same namespace to restore from.
+	// If it is an incremental backup, the backups it is based on are applied first.
+	BackupName string `json:"backupName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9._-]*$"
+	// SourceRepository is the name of the repository in the backup. Defaults to Repository.
+	SourceRepository string `json:"sourceRepository,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9._-]*$"
+	// Repository is the name of the repository on the server to restore into.
+	// Repositories of SVNRepositories in other namespaces than the server are named `namespace_name`.
+	// If the repository exists, it is made read-only while the restore runs and then moved to the trash.
+	Repository string `json:"repository,omitempty"`
+}
+
+// Here is a list of phases of SVNRestores.
+const (
+	// RestorePhasePending means the restore is waiting for the server.
+	RestorePhasePending = "Pending"
+
+	// RestorePhaseRunning means the restore Job is running.
+	RestorePhaseRunning = "Running"
+
+	// RestorePhaseSucceeded means the repository has been restored and verified.
+	RestorePhaseSucceeded = "Succeeded"
+
+	// RestorePhaseFailed means the restore has failed. Failed restores are not retried.
+	RestorePhaseFailed = "Failed"
+)
+
+// SVNRestoreStatus defines the observed state of SVNRestore
+type SVNRestoreStatus struct {
+	// +kubebuilder:validation:Optional
+	// Phase is one of `Pending`, `Running`, `Succeeded` and `Failed`.
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message is the reason why the restore is pending or has failed.
+	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// JobName is the name of the Job that runs the restore.
+	JobName string `json:"jobName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the restore Job was created.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the restore succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Backups is the list of SVNBackups applied in order.
+	Backups []string `json:"backups,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RestoredRevision is the youngest revision of the restored repository.
+	RestoredRevision *int64 `json:"restoredRevision,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Verify is the result of `svnadmin verify` on the restored repository.
+	Verify *RestoreVerifyStatus `json:"verify,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ArchivedPath is the path in the volume of the server that the replaced repository has been moved to.
+	ArchivedPath string `json:"archivedPath,omitempty"`
+}
+
+// RestoreVerifyStatus is the result of `svnadmin verify`.
+type RestoreVerifyStatus struct {
+	// Passed is true if the restored repository has been verified successfully.
+	Passed bool `json:"passed"`
+
+	// +kubebuilder:validation:Optional
+	// Message is the output of `svnadmin verify` if it has failed.
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.svnServer.name`
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupName`
+// +kubebuilder:printcolumn:name="Repository",type=string,JSONPath=`.spec.repository`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.restoredRevision`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SVNRestore is the Schema for the svnrestores API
+type SVNRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SVNRestoreSpec   `json:"spec,omitempty"`
+	Status SVNRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SVNRestoreList contains a list of SVNRestore
+type SVNRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SVNRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SVNRestore{}, &SVNRestoreList{})
+}
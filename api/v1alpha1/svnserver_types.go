@@ -17,7 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +33,101 @@ type SVNServerSpec struct {
 	// +kubebuilder:validation:Required
 	// VolumeClaimTemplate is a PVC to store SVN repositories and configuration files in.
 	VolumeClaimTemplate corev1.PersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DefaultPermissions is a set of permissions to every SVNRepository on the server.
+	// They are granted in addition to the permissions given to SVNGroups and SVNUsers.
+	// Each SVNRepository can override this field.
+	// If not specified, no permissions are granted by default.
+	DefaultPermissions *DefaultPermissions `json:"defaultPermissions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// NamespaceSelector selects namespaces whose SVNRepositories, SVNGroups and SVNUsers can belong to the server.
+	// Objects in the same namespace as the server can always belong to it.
+	// If not specified, objects in other namespaces cannot belong to the server.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Quota limits the total size of the repositories on the server, including archived ones.
+	// Once the total size reaches the quota, commits to every repository on the server are rejected.
+	Quota *Quota `json:"quota,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// Replicas is the number of Pods that serve the repositories.
+	// The first Pod is the primary, and the others are read-only replicas that copy the repositories from
+	// the primary with svnsync and proxy writes to the primary. The Service balances requests between them.
+	// If not specified, the server has only the primary.
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// Quota is a limit of storage usage.
+type Quota struct {
+	// +kubebuilder:validation:Required
+	// MaxSize is the maximum size (e.g. `10Gi`).
+	MaxSize resource.Quantity `json:"maxSize"`
+}
+
+// Usage is the storage usage measured periodically by the SVN server.
+type Usage struct {
+	// Size is the last measured size.
+	Size resource.Quantity `json:"size"`
+
+	// +kubebuilder:validation:Optional
+	// MaxSize is the quota, if any.
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Exceeded is true if the usage reaches the quota, in which case commits are rejected.
+	Exceeded bool `json:"exceeded,omitempty"`
+}
+
+// SVNServerRef is a reference to an SVNServer.
+type SVNServerRef struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9.-]*$"
+	// Name is the name of the SVNServer.
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Namespace is the namespace of the SVNServer.
+	// If not specified, the namespace of the referring object is used.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare string as the name of the SVNServer as well,
+// so that objects stored before SVNServerRef was introduced can still be read.
+// The API server no longer accepts the bare string, so it is not a way to create or update objects.
+func (r *SVNServerRef) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = SVNServerRef{Name: name}
+		return nil
+	}
+	type rawSVNServerRef SVNServerRef
+	var raw rawSVNServerRef
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = SVNServerRef(raw)
+	return nil
+}
+
+// NamespaceOr returns the namespace of the SVNServer.
+// If the namespace is not specified, it returns defaultNamespace instead.
+func (r *SVNServerRef) NamespaceOr(defaultNamespace string) string {
+	if r.Namespace == "" {
+		return defaultNamespace
+	}
+	return r.Namespace
+}
+
+// DefaultPermissions is a set of permissions that are implicitly granted.
+type DefaultPermissions struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^(?:r|rw|)$"
+	// Authenticated is a permission given to all users who have logged in.
+	Authenticated string `json:"authenticated,omitempty"`
 }
 
 // PodTemplate is an optional template to create SVN server pods.
@@ -71,6 +169,37 @@ type PodTemplate struct {
 type SVNServerStatus struct {
 	// +kubebuilder:validation:Optional
 	Conditions []Condition `json:"conditions"`
+
+	// +kubebuilder:validation:Optional
+	// Usage is the total size of the repositories on the server. It is reported only if Spec.Quota is specified.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Replicas is the state of each read-only replica.
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+}
+
+// ReplicaStatus is the state of a read-only replica reported by its server-updater.
+type ReplicaStatus struct {
+	// Name is the name of the Pod.
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	// RevisionLag is the largest number of revisions that the replica is behind the primary among the repositories.
+	// It is not reported if the replica or the primary is not available.
+	RevisionLag *int64 `json:"revisionLag,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LaggingRepositories is the number of repositories that the replica is behind the primary.
+	LaggingRepositories int32 `json:"laggingRepositories,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastSyncTime is the oldest time when the repositories on the replica were synchronized.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message describes why the lag is not known.
+	Message string `json:"message,omitempty"`
 }
 
 type Condition struct {
@@ -88,6 +217,8 @@ const (
 	ConditionTypeNone   ConditionType = ""
 	ConditionTypeSynced ConditionType = "Synced"
 	ConditionTypeFailed ConditionType = "Failed"
+	// ConditionTypeInactive means that the SVNUser is disabled or has expired.
+	ConditionTypeInactive ConditionType = "Inactive"
 )
 
 // +kubebuilder:object:root=true
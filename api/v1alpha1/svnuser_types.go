@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,16 +27,21 @@ import (
 // SVNUserSpec defines the desired state of SVNUser
 type SVNUserSpec struct {
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9][a-zA-Z0-9.-]*$"
-	// The name of the SVNServer
-	SVNServer string `json:"svnServer,omitempty"`
+	// The SVNServer that the SVNUser belongs to.
+	SVNServer SVNServerRef `json:"svnServer,omitempty"`
 
 	// Groups is a list of SVNGroups that the user belongs to.
 	Groups []GroupRef `json:"groups,omitempty"`
 
-	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Optional
+	// Permissions is a list of permissions that are directly given to the user.
+	// They are granted in addition to the permissions of the groups that the user belongs to.
+	Permissions []Permission `json:"permissions,omitempty"`
+
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9+/=.${}]+$"
 	// EncryptedPassword is a password encrypted by `htpasswd`.
+	// Either this field or PasswordSecretRef must be specified.
 	// This must be computed elsewhere in order to avoid additional complexity of
 	// letting controllers manage sensitive values.
 	//
@@ -47,6 +53,41 @@ type SVNUserSpec struct {
 	//
 	// See https://httpd.apache.org/docs/2.4/misc/password_encryptions.html for more information.
 	EncryptedPassword string `json:"encryptedPassword,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PasswordSecretRef is a reference to a Secret that holds a plaintext password.
+	// The controller encrypts the password, and updates it whenever the Secret changes.
+	// Either this field or EncryptedPassword must be specified.
+	PasswordSecretRef *PasswordSecretRef `json:"passwordSecretRef,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Disabled prevents the user from logging in while keeping the SVNUser and its group memberships.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ExpiresAt is the time when the user can no longer log in, e.g. `2021-12-31T00:00:00Z`.
+	// If not specified, the user never expires.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// PasswordSecretRef is a reference to a key of a Secret.
+type PasswordSecretRef struct {
+	// +kubebuilder:validation:Required
+	// Name is the name of the Secret in the same namespace as the SVNUser.
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	// Key is the key of the password in the Secret.
+	// If not specified, `password` is used, which `kubernetes.io/basic-auth` Secrets have.
+	Key string `json:"key,omitempty"`
+}
+
+// KeyOrDefault returns the key of the password in the Secret.
+func (r *PasswordSecretRef) KeyOrDefault() string {
+	if r.Key == "" {
+		return corev1.BasicAuthPasswordKey
+	}
+	return r.Key
 }
 
 // GroupRef is a reference to SVNGroups.
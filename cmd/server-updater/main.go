@@ -19,10 +19,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-logr/zapr"
@@ -33,11 +37,25 @@ import (
 )
 
 func main() {
-	var initdScript, svnAdmin string
+	var initdScript, svnAdmin, svnMucc, svnLook, svnSync, xz, hookCommand string
 	var timeoutMs int
+	var listenAddr, primaryHost, statusTokenFile string
+	var resyncInterval, usageInterval, mirrorPollInterval, replicaInterval time.Duration
 	flag.StringVar(&initdScript, "initd-script", "/etc/init.d/apache2", "Path to /etc/init.d/apache2 (or its variant)")
 	flag.StringVar(&svnAdmin, "svnadmin", "/usr/bin/svnadmin", "Path to `svnadmin` command")
+	flag.StringVar(&svnMucc, "svnmucc", "/usr/bin/svnmucc", "Path to `svnmucc` command")
+	flag.StringVar(&svnLook, "svnlook", "/usr/bin/svnlook", "Path to `svnlook` command")
+	flag.StringVar(&svnSync, "svnsync", "/usr/bin/svnsync", "Path to `svnsync` command")
+	flag.StringVar(&xz, "xz", "/usr/bin/xz", "Path to `xz` command")
+	flag.StringVar(&hookCommand, "hook-command", "/work/svn-hook", "Path to `svn-hook` command")
 	flag.IntVar(&timeoutMs, "exec-timeout", 10000, "Timeout to run commands")
+	flag.StringVar(&listenAddr, "listen-address", fmt.Sprintf(":%d", controllers.UpdaterPort), "The address the status endpoint binds to")
+	flag.StringVar(&statusTokenFile, "status-token-file", filepath.Join(controllers.VolumePathUpdater, controllers.SecretKeyUpdaterToken), "Path to the token that clients must send to read the status")
+	flag.DurationVar(&resyncInterval, "resync-interval", time.Minute, "Interval to retry operations on repositories")
+	flag.DurationVar(&usageInterval, "usage-interval", 5*time.Minute, "Interval to measure the sizes of repositories")
+	flag.DurationVar(&mirrorPollInterval, "mirror-poll-interval", 10*time.Second, "Interval to check whether mirrors should be synchronized")
+	flag.StringVar(&primaryHost, "primary-host", "", "Host name of the primary server; if specified, the server runs as a read-only replica of it")
+	flag.DurationVar(&replicaInterval, "replica-interval", 10*time.Second, "Interval between synchronizations of repositories on replicas")
 	flag.Parse()
 
 	zapLog, err := zap.NewProduction()
@@ -47,13 +65,35 @@ func main() {
 	}
 	log := zapr.NewLogger(zapLog)
 
+	statusToken, err := ioutil.ReadFile(statusTokenFile)
+	if err != nil {
+		log.Error(err, "failed to read the token of the status endpoint")
+		os.Exit(1)
+	}
+
 	u := &serverupdater.Updater{
-		InitdScript: initdScript,
-		SvnAdmin:    svnAdmin,
-		ReposConfig: filepath.Join(controllers.VolumePathConfig, controllers.ConfigMapKeyRepos),
-		ReposDir:    filepath.Join(controllers.VolumePathRepos, "repos"),
-		TimeoutMs:   timeoutMs,
-		Log:         log,
+		InitdScript:     initdScript,
+		SvnAdmin:        svnAdmin,
+		SvnMucc:         svnMucc,
+		SvnLook:         svnLook,
+		SvnSync:         svnSync,
+		Xz:              xz,
+		HookCommand:     hookCommand,
+		ReposConfig:     filepath.Join(controllers.VolumePathConfig, controllers.ConfigMapKeyRepos),
+		ReposDir:        filepath.Join(controllers.VolumePathRepos, "repos"),
+		TrashDir:        filepath.Join(controllers.VolumePathRepos, "trash"),
+		LoadingDir:      filepath.Join(controllers.VolumePathRepos, "loading"),
+		StatusToken:     strings.TrimSpace(string(statusToken)),
+		StatusTokenFile: statusTokenFile,
+		ServerURL:       "http://localhost/",
+		TimeoutMs:       timeoutMs,
+		Log:             log,
+	}
+	if primaryHost != "" {
+		u.PrimaryURL = fmt.Sprintf("http://%s/repos", primaryHost)
+		u.PrimaryUpdaterURL = fmt.Sprintf("http://%s:%d", primaryHost, controllers.UpdaterPort)
+		u.ReplicationCredentialsDir = controllers.VolumePathReplication
+		u.ReplicaInterval = replicaInterval
 	}
 
 	watcher, err := fsnotify.NewWatcher()
@@ -61,13 +101,16 @@ func main() {
 		log.Error(err, "failed to initialize watcher")
 		os.Exit(1)
 	}
-	if err := watcher.Add(controllers.VolumePathConfig); err != nil {
-		log.Error(err, "failed to watch config files")
-		os.Exit(1)
-	}
-	// Volumes made from ConfigMaps stores all values inside ConfigMaps in `..data` directory.
+	// Volumes made from ConfigMaps and Secrets store all values inside them in `..data` directory.
 	// See https://github.com/kubernetes/kubernetes/blob/master/pkg/volume/util/atomic_writer.go
-	dataDir := filepath.Join(controllers.VolumePathConfig, "..data")
+	dataDirs := map[string]bool{}
+	for _, dir := range []string{controllers.VolumePathConfig, controllers.VolumePathAuth} {
+		if err := watcher.Add(dir); err != nil {
+			log.Error(err, "failed to watch config files", "directory", dir)
+			os.Exit(1)
+		}
+		dataDirs[filepath.Join(dir, "..data")] = true
+	}
 
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
@@ -77,14 +120,52 @@ func main() {
 	if err != nil {
 		log.Error(err, "failed to initialize settings")
 	}
+	err = u.MeasureUsage()
+	if err != nil {
+		log.Error(err, "failed to measure usage")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(serverupdater.StatusPath, u)
+	mux.HandleFunc(serverupdater.ReadyPath, u.ServeReady)
+	mux.HandleFunc(serverupdater.SyncPath, u.ServeSync)
+	go func() {
+		log.Info("serving status", "address", listenAddr)
+		if err := http.ListenAndServe(listenAddr, mux); err != nil {
+			log.Error(err, "failed to serve status")
+			os.Exit(1)
+		}
+	}()
+
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+	usage := time.NewTicker(usageInterval)
+	defer usage.Stop()
+	mirror := time.NewTicker(mirrorPollInterval)
+	defer mirror.Stop()
 
 	for {
 		select {
+		case <-resync.C:
+			err = u.SyncRepositories()
+			if err != nil {
+				log.Error(err, "failed to sync repositories")
+			}
+		case <-usage.C:
+			err = u.MeasureUsage()
+			if err != nil {
+				log.Error(err, "failed to measure usage")
+			}
+		case <-mirror.C:
+			err = u.SyncMirrors()
+			if err != nil {
+				log.Error(err, "failed to synchronize mirrors")
+			}
 		case ev := <-watcher.Events:
 			if ev.Op&(fsnotify.Create|fsnotify.Write) == 0 {
 				continue
 			}
-			if ev.Name != dataDir {
+			if !dataDirs[ev.Name] {
 				continue
 			}
 			log.Info("detected config change", "filename", ev.Name)
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command svn-backup backs up repositories of an SVN server. It is run in Jobs created for SVNBackups.
+//
+// The result is written to the termination log of the container as JSON, so that the controller can read it.
+// If the backup fails, the error message is written instead.
+//
+// Backups are stored in -dest, or in an S3-compatible bucket if -s3-bucket is specified.
+// The credentials of the bucket are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/genkami/svn-operator/controllers"
+	"github.com/genkami/svn-operator/pkg/backup"
+)
+
+func main() {
+	b := &backup.Backup{}
+	var incremental, cleanup bool
+	var since, resultPath string
+	var s3Endpoint, s3Region, s3Bucket, s3Prefix string
+	flag.StringVar(&b.SvnAdmin, "svnadmin", "/usr/bin/svnadmin", "Path to `svnadmin` command")
+	flag.StringVar(&b.SvnLook, "svnlook", "/usr/bin/svnlook", "Path to `svnlook` command")
+	flag.StringVar(&b.ReposDir, "repos-dir", filepath.Join(controllers.VolumePathRepos, "repos"), "Path to the directory that repositories reside in")
+	flag.StringVar(&b.Dest, "dest", "", "Path to the directory to store the backup in")
+	flag.StringVar(&b.Method, "method", backup.MethodDump, "Method to back up repositories (Dump or Hotcopy)")
+	flag.StringVar(&b.Repository, "repository", "", "Name of the repository to back up (default: all repositories)")
+	flag.BoolVar(&incremental, "incremental", false, "Dump only revisions after the ones specified by -since")
+	flag.StringVar(&since, "since", "", "Last revisions captured by the previous backup (e.g. repo1=12,repo2=30)")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "URL of the S3-compatible storage to store the backup in")
+	flag.StringVar(&s3Region, "s3-region", "", "Region of the S3-compatible storage")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "Bucket to store the backup in instead of -dest")
+	flag.StringVar(&s3Prefix, "s3-prefix", "", "Prefix of the keys of the objects in the bucket")
+	flag.BoolVar(&cleanup, "cleanup", false, "Delete the backup instead of creating it")
+	flag.StringVar(&resultPath, "result", "/dev/termination-log", "Path to write the result to")
+	flag.Parse()
+
+	if s3Bucket != "" {
+		store, err := backup.NewS3Store(s3Endpoint, s3Region, s3Bucket, s3Prefix)
+		if err != nil {
+			fail(resultPath, err)
+		}
+		b.Store = store
+	} else if b.Dest == "" {
+		fail(resultPath, fmt.Errorf("either -dest or -s3-bucket is required"))
+	}
+	if cleanup {
+		store := b.Store
+		if store == nil {
+			store = &backup.DirStore{Dir: b.Dest}
+		}
+		if err := store.RemoveAll(); err != nil {
+			fail(resultPath, err)
+		}
+		return
+	}
+	if incremental {
+		var err error
+		if b.Since, err = backup.ParseSince(since); err != nil {
+			fail(resultPath, err)
+		}
+	}
+	result, err := b.Run()
+	if err != nil {
+		fail(resultPath, err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		fail(resultPath, err)
+	}
+	fmt.Println(string(data))
+	if err := ioutil.WriteFile(resultPath, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write the result:", err)
+		os.Exit(1)
+	}
+}
+
+func fail(resultPath string, err error) {
+	fmt.Fprintln(os.Stderr, err)
+	_ = ioutil.WriteFile(resultPath, []byte(err.Error()), 0644)
+	os.Exit(1)
+}
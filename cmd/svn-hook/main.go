@@ -0,0 +1,174 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command svn-hook implements the built-in hooks of SVN repositories managed by svn-operator.
+//
+// It is installed as a hook by server-updater and run by SVN as:
+//
+//	svn-hook [-user-hook PATH] HOOK_NAME REPOS_PATH ARGS...
+//
+// If all checks pass and the user's hook exists, it runs the user's hook with the same arguments and standard input.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/genkami/svn-operator/pkg/commitpolicy"
+	"github.com/genkami/svn-operator/pkg/serverupdater"
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// notifyTimeout is a timeout to wait for the replicas to synchronize a changed repository.
+const notifyTimeout = 10 * time.Second
+
+func main() {
+	var svnLook, userHook string
+	// Hooks are run without PATH, so commands must be specified by their absolute paths.
+	flag.StringVar(&svnLook, "svnlook", "/usr/bin/svnlook", "Path to `svnlook` command")
+	flag.StringVar(&userHook, "user-hook", "", "Path to the user's hook that runs after the built-in checks")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: svn-hook [-user-hook PATH] HOOK_NAME REPOS_PATH ARGS...")
+		os.Exit(2)
+	}
+	hookName, repos, hookArgs := args[0], args[1], args[2:]
+
+	// Some hooks (e.g. pre-revprop-change) receive data from the standard input, which is passed to the user's hook.
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+
+	config, err := readHookConfig(repos)
+	if err != nil {
+		fail(err)
+	}
+	var rejections []string
+	if config.ReadOnly != nil && (hookName == "pre-commit" || hookName == "pre-lock") {
+		for _, v := range commitpolicy.CheckReadOnly(config.ReadOnly) {
+			rejections = append(rejections, v.String())
+		}
+	}
+	if config.Mirror != nil {
+		// The user is the second argument of start-commit and the third of pre-lock and pre-revprop-change.
+		var violations []commitpolicy.Violation
+		switch hookName {
+		case "start-commit":
+			violations = commitpolicy.CheckMirror(config.Mirror, argOf(hookArgs, 0))
+		case "pre-lock", "pre-revprop-change":
+			violations = commitpolicy.CheckMirror(config.Mirror, argOf(hookArgs, 1))
+		}
+		for _, v := range violations {
+			rejections = append(rejections, v.String())
+		}
+	}
+	switch hookName {
+	case "pre-commit":
+		if len(hookArgs) < 1 {
+			fail(errors.New("pre-commit needs a transaction name"))
+		}
+		if config.Quota != nil {
+			for _, v := range commitpolicy.CheckQuota(config.Quota) {
+				rejections = append(rejections, v.String())
+			}
+		}
+		if config.CommitPolicy != nil {
+			txn := &commitpolicy.SvnLookTransaction{SvnLook: svnLook, Repos: repos, Txn: hookArgs[0]}
+			violations, err := commitpolicy.Check(config.CommitPolicy, txn)
+			if err != nil {
+				fail(err)
+			}
+			for _, v := range violations {
+				rejections = append(rejections, v.String())
+			}
+		}
+	}
+	if len(rejections) > 0 {
+		// The standard error of pre-* hooks is shown to the user who tries to commit.
+		fmt.Fprintf(os.Stderr, "Rejected by the policy of the repository:\n")
+		for _, r := range rejections {
+			fmt.Fprintf(os.Stderr, "  %s\n", r)
+		}
+		os.Exit(1)
+	}
+
+	if config.Replicas != nil && (hookName == "post-commit" || hookName == "post-revprop-change") {
+		// The change has already been made, so replicas that fail to synchronize now catch up by polling the primary.
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err := serverupdater.NotifyReplicas(ctx, config.Replicas, filepath.Base(repos))
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "svn-hook: warning: %s\n", err)
+		}
+	}
+
+	if userHook == "" {
+		return
+	}
+	if _, err := os.Stat(userHook); os.IsNotExist(err) {
+		return
+	}
+	cmd := exec.Command(userHook, append([]string{repos}, hookArgs...)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fail(err)
+	}
+}
+
+func readHookConfig(repos string) (*svnconfig.HookConfig, error) {
+	config := &svnconfig.HookConfig{}
+	data, err := ioutil.ReadFile(filepath.Join(repos, svnconfig.HookConfigPath))
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// argOf returns the i-th argument of the hook, or an empty string if there is not.
+func argOf(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "svn-hook: %s\n", err)
+	os.Exit(1)
+}
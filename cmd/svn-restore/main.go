@@ -0,0 +1,126 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command svn-restore restores a repository of an SVN server from backups. It is run in Jobs created for SVNRestores.
+//
+// The result is written to the termination log of the container as JSON, so that the controller can read it.
+// The result is written even if the restore fails, with the error message in it.
+//
+// Dump files are read from an S3-compatible bucket if -s3-bucket is specified.
+// The credentials of the bucket are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/genkami/svn-operator/controllers"
+	"github.com/genkami/svn-operator/pkg/backup"
+)
+
+// steps is a flag.Value that collects backups to apply.
+type steps []backup.Step
+
+func (s *steps) String() string {
+	items := make([]string, 0, len(*s))
+	for _, step := range *s {
+		items = append(items, step.Method+":"+step.Path)
+	}
+	return strings.Join(items, ",")
+}
+
+func (s *steps) Set(value string) error {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return fmt.Errorf("invalid step: %q", value)
+	}
+	method, path := value[:i], value[i+1:]
+	if method != backup.MethodDump && method != backup.MethodHotcopy {
+		return fmt.Errorf("unknown method: %q", method)
+	}
+	*s = append(*s, backup.Step{Method: method, Path: path})
+	return nil
+}
+
+func main() {
+	r := &backup.Restore{}
+	var resultPath string
+	var s3Endpoint, s3Region, s3Bucket string
+	svnDir := controllers.VolumePathRepos
+	flag.StringVar(&r.SvnAdmin, "svnadmin", "/usr/bin/svnadmin", "Path to `svnadmin` command")
+	flag.StringVar(&r.SvnLook, "svnlook", "/usr/bin/svnlook", "Path to `svnlook` command")
+	flag.StringVar(&r.ReposDir, "repos-dir", filepath.Join(svnDir, "repos"), "Path to the directory that repositories reside in")
+	flag.StringVar(&r.TrashDir, "trash-dir", filepath.Join(svnDir, "trash"), "Path to the directory to move replaced repositories to")
+	flag.StringVar(&r.StagingDir, "staging-dir", filepath.Join(svnDir, "restoring"), "Path to the directory to restore repositories in")
+	flag.StringVar(&r.Repository, "repository", "", "Name of the repository to restore")
+	flag.Var((*steps)(&r.Steps), "step", "Backup to apply in the form of METHOD:PATH (can be specified multiple times)")
+	flag.StringVar(&r.FSType, "fs-type", "", "Type of the filesystem of the repository restored from dump files")
+	flag.StringVar(&r.CompatibleVersion, "compatible-version", "", "Oldest version of Subversion that can read the repository restored from dump files")
+	flag.DurationVar(&r.MaintenanceTimeout, "maintenance-timeout", 0, "How long to wait for the existing repository to become read-only (0 to replace it without waiting)")
+	flag.DurationVar(&r.PollInterval, "poll-interval", 5*time.Second, "Interval to check whether the existing repository has become read-only")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "URL of the S3-compatible storage to read dump files from")
+	flag.StringVar(&s3Region, "s3-region", "", "Region of the S3-compatible storage")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "Bucket to read dump files from, whose paths are keys of objects")
+	flag.StringVar(&resultPath, "result", "/dev/termination-log", "Path to write the result to")
+	flag.Parse()
+
+	if s3Bucket != "" {
+		store, err := backup.NewS3Store(s3Endpoint, s3Region, s3Bucket, "")
+		if err != nil {
+			finish(resultPath, nil, err)
+		}
+		r.Store = store
+	}
+
+	if r.Repository == "" {
+		finish(resultPath, nil, fmt.Errorf("-repository is required"))
+	}
+	if len(r.Steps) == 0 {
+		finish(resultPath, nil, fmt.Errorf("at least one -step is required"))
+	}
+	result, err := r.Run()
+	finish(resultPath, result, err)
+}
+
+func finish(resultPath string, result *backup.RestoreResult, err error) {
+	if result == nil {
+		result = &backup.RestoreResult{}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		result.Error = err.Error()
+	}
+	data, merr := json.Marshal(result)
+	if merr != nil {
+		fmt.Fprintln(os.Stderr, "failed to encode the result:", merr)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+	if werr := ioutil.WriteFile(resultPath, data, 0644); werr != nil {
+		fmt.Fprintln(os.Stderr, "failed to write the result:", werr)
+		os.Exit(1)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
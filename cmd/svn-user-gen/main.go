@@ -19,10 +19,12 @@ func main() {
 	var user string
 	var cost int
 	var svnServer string
+	var svnServerNamespace string
 	var svnGroups string
 	var password string
 	flag.StringVar(&user, "user", "", "The name of the user")
 	flag.StringVar(&svnServer, "svn-server", "TYPE_THE_SERVER_NAME_HERE", "The name of SVNServer resource")
+	flag.StringVar(&svnServerNamespace, "svn-server-namespace", "", "The namespace of SVNServer resource if it differs from the user's")
 	flag.StringVar(&svnGroups, "svn-groups", "", "Comma-separated list of SVNGroups that the user belongs to")
 	flag.IntVar(&cost, "cost", bcrypt.DefaultCost, "The cost of bcrypt encryption")
 	flag.StringVar(&password, "password", "", "Password")
@@ -69,6 +71,7 @@ func main() {
 		"User":              user,
 		"EncryptedPassword": string(encryptedPassword),
 		"Server":            svnServer,
+		"ServerNamespace":   svnServerNamespace,
 		"Groups":            groups,
 	})
 	if err != nil {
@@ -83,7 +86,11 @@ kind: SVNUser
 metadata:
   name: {{ .User }}
 spec:
-  svnServer: {{ .Server }}
+  svnServer:
+    name: {{ .Server }}
+{{- if .ServerNamespace }}
+    namespace: {{ .ServerNamespace }}
+{{- end }}
   encryptedPassword: {{ .EncryptedPassword }}
 {{- if lt 0 (len .Groups) }}
   groups:
@@ -33,6 +33,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/serverupdater"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -86,7 +87,29 @@ var _ = BeforeSuite(func() {
 		Scheme:                k8sManager.GetScheme(),
 		Log:                   ctrl.Log.WithName("controllers").WithName("SVNServer"),
 		DefaultSVNServerImage: defaultSVNServerImageForTest,
+		UpdaterClient:         &serverupdater.Client{},
 	}).SetupWithManager(ctx, k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+	err = (&SVNBackupReconciler{
+		Client:                k8sManager.GetClient(),
+		Scheme:                k8sManager.GetScheme(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("SVNBackup"),
+		DefaultSVNServerImage: defaultSVNServerImageForTest,
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+	err = (&SVNBackupScheduleReconciler{
+		Client: k8sManager.GetClient(),
+		Scheme: k8sManager.GetScheme(),
+		Log:    ctrl.Log.WithName("controllers").WithName("SVNBackupSchedule"),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+	err = (&SVNRestoreReconciler{
+		Client:                k8sManager.GetClient(),
+		Scheme:                k8sManager.GetScheme(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("SVNRestore"),
+		DefaultSVNServerImage: defaultSVNServerImageForTest,
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
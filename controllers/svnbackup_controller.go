@@ -0,0 +1,635 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/backup"
+)
+
+const (
+	VolumeNameBackup = "backup"
+	VolumePathBackup = "/svn-backup"
+
+	ContainerNameBackup = "backup"
+
+	// BackupCommand is a path to the `svn-backup` command in the SVN server image.
+	BackupCommand = "/work/svn-backup"
+
+	// BackupUserID is the ID of the user and the group that own the repositories in the SVN server image (www-data).
+	BackupUserID = 33
+
+	// LabelBackupKey is a label of Jobs and Pods that run SVNBackups.
+	LabelBackupKey = "svn.k8s.oyasumi.club/backup"
+
+	// FinalizerBackup is a finalizer that keeps SVNBackups until the files of the backups are deleted.
+	FinalizerBackup = "svn.k8s.oyasumi.club/backup"
+
+	// BackupPollInterval is an interval to check SVNBackups that are waiting for something.
+	BackupPollInterval = 10 * time.Second
+
+	// cleanupJobSuffix is a suffix of the names of cleanup Jobs.
+	cleanupJobSuffix = "-cleanup"
+
+	// maxBackupNameLength is the longest name of SVNBackups whose cleanup Jobs are named without shortening.
+	maxBackupNameLength = validation.DNS1123LabelMaxLength - len(cleanupJobSuffix)
+)
+
+// SVNBackupReconciler reconciles a SVNBackup object
+type SVNBackupReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// DefaultSVNServerImage is a Docker image name to run SVN server, which contains `svn-backup` as well.
+	DefaultSVNServerImage string
+}
+
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+// Reconcile runs a Job that backs up the repositories of an SVNServer, and records the captured revisions
+// that the Job reports in its termination message.
+// When an SVNBackup with the Delete policy is deleted, it runs another Job that removes the files of the backup.
+func (r *SVNBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("svnbackup", req.NamespacedName)
+
+	b := &svnv1alpha1.SVNBackup{}
+	if err := r.Get(ctx, req.NamespacedName, b); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("SVNBackup not found; ignoring.")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SVNBackup")
+		return ctrl.Result{}, err
+	}
+
+	if !b.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, log, b)
+	}
+	if has, needs := controllerutil.ContainsFinalizer(b, FinalizerBackup), needsBackupFinalizer(b); has != needs {
+		if needs {
+			controllerutil.AddFinalizer(b, FinalizerBackup)
+		} else {
+			controllerutil.RemoveFinalizer(b, FinalizerBackup)
+		}
+		if err := r.Update(ctx, b); err != nil {
+			log.Error(err, "Failed to update finalizers of SVNBackup")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if b.Status.Phase == svnv1alpha1.BackupPhaseSucceeded || b.Status.Phase == svnv1alpha1.BackupPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	status := b.Status.DeepCopy()
+	result, err := r.runBackup(ctx, log, b, status)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if reflect.DeepEqual(status, &b.Status) {
+		return result, nil
+	}
+	if status.Phase == svnv1alpha1.BackupPhaseSucceeded || status.Phase == svnv1alpha1.BackupPhaseFailed {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		log.Info("backup finished", "phase", status.Phase, "message", status.Message)
+	}
+	b.Status = *status
+	if err := r.Status().Update(ctx, b); err != nil {
+		log.Error(err, "Failed to update SVNBackup status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// runBackup creates the backup Job if it does not exist, and updates status according to the Job.
+func (r *SVNBackupReconciler) runBackup(ctx context.Context, log logr.Logger, b *svnv1alpha1.SVNBackup, status *svnv1alpha1.SVNBackupStatus) (ctrl.Result, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: backupJobNameOf(b)}, job)
+	if err == nil {
+		return ctrl.Result{}, r.updateBackupStatus(ctx, job, status)
+	}
+	if !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Job")
+		return ctrl.Result{}, err
+	}
+
+	pending := func(format string, args ...interface{}) (ctrl.Result, error) {
+		status.Phase = svnv1alpha1.BackupPhasePending
+		status.Message = fmt.Sprintf(format, args...)
+		return ctrl.Result{RequeueAfter: BackupPollInterval}, nil
+	}
+	failed := func(err error) (ctrl.Result, error) {
+		status.Phase = svnv1alpha1.BackupPhaseFailed
+		status.Message = err.Error()
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateBackup(b); err != nil {
+		return failed(err)
+	}
+	server := &svnv1alpha1.SVNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.SVNServer.Name}, server); err != nil {
+		if errors.IsNotFound(err) {
+			return pending("SVNServer %q not found", b.Spec.SVNServer.Name)
+		}
+		log.Error(err, "Failed to get SVNServer")
+		return ctrl.Result{}, err
+	}
+
+	var since map[string]int64
+	if b.Spec.IncrementalFrom != "" {
+		base := &svnv1alpha1.SVNBackup{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.IncrementalFrom}, base); err != nil {
+			if errors.IsNotFound(err) {
+				return pending("SVNBackup %q not found", b.Spec.IncrementalFrom)
+			}
+			log.Error(err, "Failed to get SVNBackup")
+			return ctrl.Result{}, err
+		}
+		if base.Status.Phase != svnv1alpha1.BackupPhaseSucceeded && base.Status.Phase != svnv1alpha1.BackupPhaseFailed {
+			return pending("waiting for SVNBackup %q to finish", base.Name)
+		}
+		if since, err = sinceOf(b, base); err != nil {
+			return failed(err)
+		}
+	}
+
+	job, err = r.backupJobFor(server, b, since)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Job")
+		return ctrl.Result{}, err
+	}
+	log.Info("Creating a new Job", "Job.Name", job.Name)
+	if err := r.Create(ctx, job); err != nil {
+		log.Error(err, "Failed to create new Job")
+		return ctrl.Result{}, err
+	}
+	now := metav1.Now()
+	status.Phase = svnv1alpha1.BackupPhaseRunning
+	status.Message = ""
+	status.JobName = job.Name
+	status.StartTime = &now
+	status.Path = backupPathOf(b)
+	return ctrl.Result{}, nil
+}
+
+// validateBackup returns an error if the backup can never run.
+func validateBackup(b *svnv1alpha1.SVNBackup) error {
+	if b.Spec.SVNServer.NamespaceOr(b.Namespace) != b.Namespace {
+		return fmt.Errorf("SVNServer must be in the same namespace as the SVNBackup")
+	}
+	if b.Spec.IncrementalFrom != "" && backupMethodOf(b) != svnv1alpha1.BackupMethodDump {
+		return fmt.Errorf("incrementalFrom is only available with the %s method", svnv1alpha1.BackupMethodDump)
+	}
+	t := &b.Spec.Target
+	if (t.PersistentVolumeClaim == nil) == (t.S3 == nil) {
+		return fmt.Errorf("exactly one target must be specified")
+	}
+	if t.S3 != nil && backupMethodOf(b) != svnv1alpha1.BackupMethodDump {
+		return fmt.Errorf("the S3 target is only available with the %s method", svnv1alpha1.BackupMethodDump)
+	}
+	return nil
+}
+
+// sinceOf returns the last revisions captured by base, after which b dumps revisions incrementally.
+func sinceOf(b, base *svnv1alpha1.SVNBackup) (map[string]int64, error) {
+	if base.Status.Phase != svnv1alpha1.BackupPhaseSucceeded {
+		return nil, fmt.Errorf("SVNBackup %q has failed", base.Name)
+	}
+	if base.Spec.SVNServer.Name != b.Spec.SVNServer.Name {
+		return nil, fmt.Errorf("SVNBackup %q is a backup of another SVNServer", base.Name)
+	}
+	since := map[string]int64{}
+	for _, repo := range base.Status.Repositories {
+		since[repo.Name] = repo.ToRevision
+	}
+	if b.Spec.Repository != "" {
+		if _, ok := since[b.Spec.Repository]; !ok {
+			return nil, fmt.Errorf("SVNBackup %q does not contain %q", base.Name, b.Spec.Repository)
+		}
+	}
+	return since, nil
+}
+
+// updateBackupStatus updates status according to the state of the backup Job.
+func (r *SVNBackupReconciler) updateBackupStatus(ctx context.Context, job *batchv1.Job, status *svnv1alpha1.SVNBackupStatus) error {
+	finished, succeeded := jobFinished(job)
+	if !finished {
+		status.Phase = svnv1alpha1.BackupPhaseRunning
+		return nil
+	}
+	message, err := terminationMessageOf(ctx, r, job, ContainerNameBackup, succeeded)
+	if err != nil {
+		return err
+	}
+	if !succeeded {
+		status.Phase = svnv1alpha1.BackupPhaseFailed
+		status.Message = message
+		if status.Message == "" {
+			status.Message = "the backup Job has failed"
+		}
+		return nil
+	}
+	repos, err := backupResultOf(message)
+	if err != nil {
+		status.Phase = svnv1alpha1.BackupPhaseFailed
+		status.Message = fmt.Sprintf("failed to read the result of the backup: %s", err.Error())
+		return nil
+	}
+	status.Phase = svnv1alpha1.BackupPhaseSucceeded
+	status.Message = ""
+	status.Repositories = repos
+	return nil
+}
+
+// backupResultOf parses the result that `svn-backup` writes to its termination log.
+func backupResultOf(message string) ([]svnv1alpha1.BackupRepositoryStatus, error) {
+	var result backup.Result
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		return nil, err
+	}
+	repos := make([]svnv1alpha1.BackupRepositoryStatus, 0, len(result.Repositories))
+	for _, repo := range result.Repositories {
+		repos = append(repos, svnv1alpha1.BackupRepositoryStatus{
+			Name:         repo.Name,
+			FromRevision: repo.From,
+			ToRevision:   repo.To,
+			File:         repo.File,
+		})
+	}
+	return repos, nil
+}
+
+// jobFinished returns whether the Job has finished and whether it has succeeded.
+func jobFinished(job *batchv1.Job) (finished bool, succeeded bool) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, true
+		case batchv1.JobFailed:
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// terminationMessageOf returns the termination message of the container in the Pod of the Job that has succeeded,
+// or of the last Pod that has failed.
+func terminationMessageOf(ctx context.Context, c client.Reader, job *batchv1.Job, containerName string, succeeded bool) (string, error) {
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return "", err
+	}
+	var message string
+	var last time.Time
+	for i := range pods.Items {
+		for _, s := range pods.Items[i].Status.ContainerStatuses {
+			t := s.State.Terminated
+			if s.Name != containerName || t == nil || (t.ExitCode == 0) != succeeded {
+				continue
+			}
+			if message == "" || t.FinishedAt.Time.After(last) {
+				message, last = t.Message, t.FinishedAt.Time
+			}
+		}
+	}
+	return message, nil
+}
+
+// backupMethodOf returns the method of the backup.
+func backupMethodOf(b *svnv1alpha1.SVNBackup) string {
+	if b.Spec.Method == "" {
+		return svnv1alpha1.BackupMethodDump
+	}
+	return b.Spec.Method
+}
+
+// backupPathOf returns the directory of the backup relative to the root of the target,
+// or the prefix of the keys of its objects.
+func backupPathOf(b *svnv1alpha1.SVNBackup) string {
+	var prefix string
+	if t := b.Spec.Target.PersistentVolumeClaim; t != nil {
+		prefix = t.Path
+	} else if t := b.Spec.Target.S3; t != nil {
+		prefix = t.Prefix
+	}
+	return path.Join(prefix, b.Name)
+}
+
+// repositoryClaimNameOf returns the name of the PersistentVolumeClaim that the StatefulSet of the server creates.
+func repositoryClaimNameOf(s *svnv1alpha1.SVNServer) string {
+	return fmt.Sprintf("%s-%s-0", VolumeNameRepos, s.Name)
+}
+
+// backupJobFor returns a Job that backs up the repositories of the server.
+// The Job runs on the same node as the server, since the volume of the server may only be mounted by a single node.
+func (r *SVNBackupReconciler) backupJobFor(s *svnv1alpha1.SVNServer, b *svnv1alpha1.SVNBackup, since map[string]int64) (*batchv1.Job, error) {
+	args := []string{
+		BackupCommand,
+		"-repos-dir", path.Join(VolumePathRepos, "repos"),
+	}
+	if t := b.Spec.Target.S3; t != nil {
+		args = append(args, s3ArgsOf(t)...)
+		args = append(args, "-s3-prefix", backupPathOf(b))
+	} else {
+		args = append(args, "-dest", path.Join(VolumePathBackup, backupPathOf(b)))
+	}
+	args = append(args, "-method", backupMethodOf(b))
+	if b.Spec.Repository != "" {
+		args = append(args, "-repository", b.Spec.Repository)
+	}
+	if b.Spec.IncrementalFrom != "" {
+		args = append(args, "-incremental", "-since", backup.FormatSince(since))
+	}
+	container := corev1.Container{
+		Name:         ContainerNameBackup,
+		Command:      args,
+		VolumeMounts: []corev1.VolumeMount{{Name: VolumeNameRepos, MountPath: VolumePathRepos}},
+	}
+	volumes := append([]corev1.Volume{repositoryVolumeOf(s)}, mountBackupTarget(b, &container, false)...)
+	podSpec := backupPodSpecFor(r.DefaultSVNServerImage, s, container, volumes...)
+	podSpec.Affinity = serverAffinityOf(s)
+	return r.jobFor(b, backupJobNameOf(b), podSpec)
+}
+
+func repositoryVolumeOf(s *svnv1alpha1.SVNServer) corev1.Volume {
+	return corev1.Volume{
+		Name: VolumeNameRepos,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: repositoryClaimNameOf(s)},
+		},
+	}
+}
+
+// serverAffinityOf returns an affinity that puts Pods on the same node as the server,
+// since the volume of the server may only be mounted by a single node.
+func serverAffinityOf(s *svnv1alpha1.SVNServer) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
+					LabelAppKey:          LabelAppValue,
+					LabelInstanceNameKey: s.Name,
+				}},
+				TopologyKey: corev1.LabelHostname,
+			}},
+		},
+	}
+}
+
+// cleanupJobFor returns a Job that deletes the files of the backup.
+func (r *SVNBackupReconciler) cleanupJobFor(s *svnv1alpha1.SVNServer, b *svnv1alpha1.SVNBackup) (*batchv1.Job, error) {
+	container := corev1.Container{Name: ContainerNameBackup}
+	if t := b.Spec.Target.S3; t != nil {
+		container.Command = append([]string{BackupCommand, "-cleanup"}, s3ArgsOf(t)...)
+		container.Command = append(container.Command, "-s3-prefix", b.Status.Path)
+	} else {
+		container.Command = []string{"rm", "-rf", path.Join(VolumePathBackup, b.Status.Path)}
+	}
+	volumes := mountBackupTarget(b, &container, false)
+	podSpec := backupPodSpecFor(r.DefaultSVNServerImage, s, container, volumes...)
+	return r.jobFor(b, cleanupJobNameOf(b), podSpec)
+}
+
+// mountBackupTarget gives the container access to the target of the backup, and returns the volumes that it needs.
+func mountBackupTarget(b *svnv1alpha1.SVNBackup, c *corev1.Container, readOnly bool) []corev1.Volume {
+	if t := b.Spec.Target.S3; t != nil {
+		c.Env = append(c.Env, s3CredentialsEnvOf(t)...)
+		return nil
+	}
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: VolumeNameBackup, MountPath: VolumePathBackup, ReadOnly: readOnly})
+	return []corev1.Volume{backupTargetVolumeOf(b)}
+}
+
+// s3ArgsOf returns the arguments of `svn-backup` and `svn-restore` to access the bucket.
+func s3ArgsOf(t *svnv1alpha1.S3BackupTarget) []string {
+	args := []string{"-s3-endpoint", t.Endpoint, "-s3-bucket", t.Bucket}
+	if t.Region != "" {
+		args = append(args, "-s3-region", t.Region)
+	}
+	return args
+}
+
+// s3CredentialsEnvOf returns the environment variables that `svn-backup` and `svn-restore` read the credentials from.
+func s3CredentialsEnvOf(t *svnv1alpha1.S3BackupTarget) []corev1.EnvVar {
+	optional := true
+	envOf := func(key string, optional *bool) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: key,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: t.CredentialsSecret,
+					Key:                  key,
+					Optional:             optional,
+				},
+			},
+		}
+	}
+	return []corev1.EnvVar{
+		envOf(backup.EnvAccessKeyID, nil),
+		envOf(backup.EnvSecretAccessKey, nil),
+		envOf(backup.EnvSessionToken, &optional),
+	}
+}
+
+func backupTargetVolumeOf(b *svnv1alpha1.SVNBackup) corev1.Volume {
+	return corev1.Volume{
+		Name: VolumeNameBackup,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: b.Spec.Target.PersistentVolumeClaim.ClaimName,
+			},
+		},
+	}
+}
+
+// backupPodSpecFor returns a spec of Pods that run the container with the SVN server image.
+// If s is nil, defaultImage is used.
+func backupPodSpecFor(defaultImage string, s *svnv1alpha1.SVNServer, container corev1.Container, volumes ...corev1.Volume) corev1.PodSpec {
+	userID := int64(BackupUserID)
+	container.Image = defaultImage
+	container.TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+	spec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Containers:    []corev1.Container{container},
+		Volumes:       volumes,
+		SecurityContext: &corev1.PodSecurityContext{
+			RunAsUser:  &userID,
+			RunAsGroup: &userID,
+			FSGroup:    &userID,
+		},
+	}
+	if s == nil {
+		return spec
+	}
+	if s.Spec.PodTemplate.Image != "" {
+		spec.Containers[0].Image = s.Spec.PodTemplate.Image
+	}
+	spec.ServiceAccountName = s.Spec.PodTemplate.ServiceAccountName
+	spec.ImagePullSecrets = append([]corev1.LocalObjectReference(nil), s.Spec.PodTemplate.ImagePullSecrets...)
+	spec.Tolerations = append([]corev1.Toleration(nil), s.Spec.PodTemplate.Tolerations...)
+	return spec
+}
+
+// backupJobNameOf returns the name of the Job that takes the backup.
+func backupJobNameOf(b *svnv1alpha1.SVNBackup) string {
+	// Jobs label their Pods with their names, which must be valid label values.
+	return shortNameOf(b.Name, validation.LabelValueMaxLength)
+}
+
+// cleanupJobNameOf returns the name of the Job that deletes the files of the backup.
+func cleanupJobNameOf(b *svnv1alpha1.SVNBackup) string {
+	return shortNameOf(b.Name, maxBackupNameLength) + cleanupJobSuffix
+}
+
+// shortNameOf returns name as is if it is at most maxLen characters long.
+// Otherwise, it returns the name truncated and followed by its hash, so that different names remain different.
+func shortNameOf(name string, maxLen int) string {
+	if len(name) <= maxLen {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	return name[:maxLen-len(hash)-1] + "-" + hash
+}
+
+func (r *SVNBackupReconciler) jobFor(b *svnv1alpha1.SVNBackup, name string, podSpec corev1.PodSpec) (*batchv1.Job, error) {
+	backoffLimit := int32(2)
+	labels := map[string]string{LabelBackupKey: shortNameOf(b.Name, validation.LabelValueMaxLength)}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: b.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       podSpec,
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(b, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func needsBackupFinalizer(b *svnv1alpha1.SVNBackup) bool {
+	return b.Spec.DeletionPolicy == svnv1alpha1.DeletionPolicyDelete
+}
+
+// finalize deletes the files of the backup with a Job and then removes FinalizerBackup.
+func (r *SVNBackupReconciler) finalize(ctx context.Context, log logr.Logger, b *svnv1alpha1.SVNBackup) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(b, FinalizerBackup) {
+		return ctrl.Result{}, nil
+	}
+	done, err := r.deleteBackupFiles(ctx, log, b)
+	if err != nil || !done {
+		return ctrl.Result{RequeueAfter: BackupPollInterval}, err
+	}
+	log.Info("deleted the files of the backup", "path", b.Status.Path)
+	controllerutil.RemoveFinalizer(b, FinalizerBackup)
+	if err := r.Update(ctx, b); err != nil {
+		log.Error(err, "Failed to remove finalizer from SVNBackup")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteBackupFiles runs the cleanup Job and returns true once it succeeds.
+func (r *SVNBackupReconciler) deleteBackupFiles(ctx context.Context, log logr.Logger, b *svnv1alpha1.SVNBackup) (bool, error) {
+	if b.Status.Path == "" || (b.Spec.Target.PersistentVolumeClaim == nil && b.Spec.Target.S3 == nil) {
+		// The backup has never started.
+		return true, nil
+	}
+	backupJob := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Status.JobName}, backupJob)
+	if err == nil {
+		if finished, _ := jobFinished(backupJob); !finished {
+			// The files would be written again after they are deleted.
+			log.Info("stopping the backup Job before deleting the files")
+			return false, r.Delete(ctx, backupJob, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		}
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	cleanupJob := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: cleanupJobNameOf(b)}, cleanupJob)
+	if errors.IsNotFound(err) {
+		server := &svnv1alpha1.SVNServer{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.SVNServer.Name}, server); err != nil {
+			if !errors.IsNotFound(err) {
+				return false, err
+			}
+			server = nil
+		}
+		job, err := r.cleanupJobFor(server, b)
+		if err != nil {
+			return false, err
+		}
+		log.Info("Creating a new Job", "Job.Name", job.Name)
+		return false, r.Create(ctx, job)
+	} else if err != nil {
+		return false, err
+	}
+	finished, succeeded := jobFinished(cleanupJob)
+	if finished && !succeeded {
+		log.Info("failed to delete the files of the backup; remove the finalizer by hand to give up", "finalizer", FinalizerBackup)
+	}
+	return succeeded, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SVNBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&svnv1alpha1.SVNBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+)
+
+var _ = Describe("SVNBackupReconciler", func() {
+	var r *SVNBackupReconciler
+	var server *svnv1alpha1.SVNServer
+	var b *svnv1alpha1.SVNBackup
+	BeforeEach(func() {
+		Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+		r = &SVNBackupReconciler{Scheme: scheme.Scheme, DefaultSVNServerImage: "svn:latest"}
+		server = &svnv1alpha1.SVNServer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svn"},
+			Spec: svnv1alpha1.SVNServerSpec{
+				PodTemplate: svnv1alpha1.PodTemplate{ServiceAccountName: "svn"},
+			},
+		}
+		b = &svnv1alpha1.SVNBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly"},
+			Spec: svnv1alpha1.SVNBackupSpec{
+				SVNServer: svnv1alpha1.SVNServerRef{Name: "svn"},
+				Target: svnv1alpha1.BackupTarget{
+					PersistentVolumeClaim: &svnv1alpha1.PersistentVolumeClaimBackupTarget{ClaimName: "backups", Path: "svn"},
+				},
+			},
+		}
+	})
+
+	Describe("backupJobFor", func() {
+		It("mounts the volume of the server on the same node", func() {
+			job, err := r.backupJobFor(server, b, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name).To(Equal("nightly"))
+			Expect(job.OwnerReferences).To(HaveLen(1))
+			pod := job.Spec.Template.Spec
+			Expect(pod.ServiceAccountName).To(Equal("svn"))
+			Expect(pod.Volumes).To(HaveLen(2))
+			Expect(pod.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("repos-svn-0"))
+			Expect(pod.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+			term := pod.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0]
+			Expect(term.TopologyKey).To(Equal(corev1.LabelHostname))
+			Expect(term.LabelSelector.MatchLabels).To(HaveKeyWithValue(LabelInstanceNameKey, "svn"))
+			Expect(pod.Containers[0].Image).To(Equal("svn:latest"))
+			Expect(pod.Containers[0].Command).To(Equal([]string{
+				BackupCommand,
+				"-repos-dir", "/svn/repos",
+				"-dest", "/svn-backup/svn/nightly",
+				"-method", "Dump",
+			}))
+		})
+
+		It("passes the revisions of the base backup to incremental backups", func() {
+			b.Spec.Repository = "hoge"
+			b.Spec.IncrementalFrom = "base"
+			job, err := r.backupJobFor(server, b, map[string]int64{"hoge": 3})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("-repository", "hoge", "-incremental", "-since", "hoge=3"))
+		})
+
+		It("streams dumps to S3-compatible buckets", func() {
+			b.Spec.Target = svnv1alpha1.BackupTarget{S3: &svnv1alpha1.S3BackupTarget{
+				Endpoint:          "http://minio:9000",
+				Bucket:            "backups",
+				Prefix:            "svn",
+				CredentialsSecret: corev1.LocalObjectReference{Name: "minio"},
+			}}
+			job, err := r.backupJobFor(server, b, nil)
+			Expect(err).NotTo(HaveOccurred())
+			pod := job.Spec.Template.Spec
+			Expect(pod.Volumes).To(HaveLen(1))
+			Expect(pod.Containers[0].Command).To(Equal([]string{
+				BackupCommand,
+				"-repos-dir", "/svn/repos",
+				"-s3-endpoint", "http://minio:9000",
+				"-s3-bucket", "backups",
+				"-s3-prefix", "svn/nightly",
+				"-method", "Dump",
+			}))
+			env := pod.Containers[0].Env
+			Expect(env).To(HaveLen(3))
+			Expect(env[0].Name).To(Equal("AWS_ACCESS_KEY_ID"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Name).To(Equal("minio"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Key).To(Equal("AWS_ACCESS_KEY_ID"))
+			Expect(*env[2].ValueFrom.SecretKeyRef.Optional).To(BeTrue())
+		})
+	})
+
+	Describe("cleanupJobFor", func() {
+		It("deletes objects in S3-compatible buckets", func() {
+			b.Spec.Target = svnv1alpha1.BackupTarget{S3: &svnv1alpha1.S3BackupTarget{
+				Endpoint: "http://minio:9000",
+				Region:   "ap-northeast-1",
+				Bucket:   "backups",
+			}}
+			b.Status.Path = "nightly"
+			job, err := r.cleanupJobFor(nil, b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name).To(Equal("nightly-cleanup"))
+			Expect(job.Spec.Template.Spec.Volumes).To(BeEmpty())
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(Equal([]string{
+				BackupCommand, "-cleanup",
+				"-s3-endpoint", "http://minio:9000",
+				"-s3-bucket", "backups",
+				"-s3-region", "ap-northeast-1",
+				"-s3-prefix", "nightly",
+			}))
+		})
+		It("shortens the name of the Job for long names of backups", func() {
+			b.Name = strings.Repeat("a", 60)
+			job, err := r.cleanupJobFor(nil, b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(job.Name)).To(BeNumerically("<=", validation.DNS1123LabelMaxLength))
+			Expect(job.Name).To(HaveSuffix("-cleanup"))
+			Expect(job.Labels[LabelBackupKey]).To(Equal(b.Name))
+
+			other := b.DeepCopy()
+			other.Name = strings.Repeat("a", 59) + "b"
+			Expect(cleanupJobNameOf(other)).NotTo(Equal(job.Name))
+		})
+	})
+
+	Describe("validateBackup", func() {
+		It("accepts valid backups", func() {
+			Expect(validateBackup(b)).To(Succeed())
+		})
+
+		It("rejects servers in other namespaces", func() {
+			b.Spec.SVNServer.Namespace = "other"
+			Expect(validateBackup(b)).NotTo(Succeed())
+		})
+
+		It("rejects backups with no target or more than one target", func() {
+			b.Spec.Target.S3 = &svnv1alpha1.S3BackupTarget{Endpoint: "http://minio:9000", Bucket: "backups"}
+			Expect(validateBackup(b)).NotTo(Succeed())
+			b.Spec.Target = svnv1alpha1.BackupTarget{}
+			Expect(validateBackup(b)).NotTo(Succeed())
+		})
+
+		It("rejects hotcopies to S3-compatible buckets", func() {
+			b.Spec.Method = svnv1alpha1.BackupMethodHotcopy
+			b.Spec.Target = svnv1alpha1.BackupTarget{S3: &svnv1alpha1.S3BackupTarget{Endpoint: "http://minio:9000", Bucket: "backups"}}
+			Expect(validateBackup(b)).NotTo(Succeed())
+		})
+
+		It("rejects incremental hotcopies", func() {
+			b.Spec.Method = svnv1alpha1.BackupMethodHotcopy
+			b.Spec.IncrementalFrom = "base"
+			Expect(validateBackup(b)).NotTo(Succeed())
+		})
+	})
+
+	Describe("sinceOf", func() {
+		var base *svnv1alpha1.SVNBackup
+		BeforeEach(func() {
+			base = b.DeepCopy()
+			base.Name = "base"
+			base.Status = svnv1alpha1.SVNBackupStatus{
+				Phase: svnv1alpha1.BackupPhaseSucceeded,
+				Repositories: []svnv1alpha1.BackupRepositoryStatus{
+					{Name: "hoge", FromRevision: 0, ToRevision: 3, File: "hoge.dump.gz"},
+					{Name: "fuga", FromRevision: 6, ToRevision: 5},
+				},
+			}
+		})
+
+		It("returns the last captured revisions", func() {
+			Expect(sinceOf(b, base)).To(Equal(map[string]int64{"hoge": 3, "fuga": 5}))
+		})
+
+		It("rejects failed backups", func() {
+			base.Status.Phase = svnv1alpha1.BackupPhaseFailed
+			_, err := sinceOf(b, base)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects backups that do not contain the repository", func() {
+			b.Spec.Repository = "piyo"
+			_, err := sinceOf(b, base)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("backupResultOf", func() {
+		It("parses the result of svn-backup", func() {
+			Expect(backupResultOf(`{"repositories":[{"name":"hoge","from":2,"to":3,"file":"hoge.dump.gz"}]}`)).To(Equal([]svnv1alpha1.BackupRepositoryStatus{
+				{Name: "hoge", FromRevision: 2, ToRevision: 3, File: "hoge.dump.gz"},
+			}))
+		})
+
+		It("rejects other messages", func() {
+			_, err := backupResultOf("exit status 1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
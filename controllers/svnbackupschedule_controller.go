@@ -0,0 +1,336 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/cron"
+)
+
+const (
+	// LabelBackupScheduleKey is a label of SVNBackups created by SVNBackupSchedules.
+	LabelBackupScheduleKey = "svn.k8s.oyasumi.club/backup-schedule"
+
+	// DefaultBackupRetention is the default of SVNBackupScheduleSpec.Retention.
+	DefaultBackupRetention = 7
+
+	// maxMissedSchedules bounds the search for the last missed schedule, e.g. after the controller has been stopped for long.
+	maxMissedSchedules = 10000
+)
+
+// SVNBackupScheduleReconciler reconciles a SVNBackupSchedule object
+type SVNBackupScheduleReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackupschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackupschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnbackupschedules/finalizers,verbs=update
+
+// Reconcile creates SVNBackups on schedule and deletes old ones beyond the retention.
+func (r *SVNBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("svnbackupschedule", req.NamespacedName)
+
+	schedule := &svnv1alpha1.SVNBackupSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("SVNBackupSchedule not found; ignoring.")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SVNBackupSchedule")
+		return ctrl.Result{}, err
+	}
+	now := time.Now()
+	if r.Now != nil {
+		now = r.Now()
+	}
+
+	status := schedule.Status.DeepCopy()
+	result, err := r.reconcileSchedule(ctx, log, schedule, status, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if scheduleStatusEqual(status, &schedule.Status) {
+		return result, nil
+	}
+	schedule.Status = *status
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		log.Error(err, "Failed to update SVNBackupSchedule status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+func (r *SVNBackupScheduleReconciler) reconcileSchedule(ctx context.Context, log logr.Logger, schedule *svnv1alpha1.SVNBackupSchedule, status *svnv1alpha1.SVNBackupScheduleStatus, now time.Time) (ctrl.Result, error) {
+	cronSchedule, err := cron.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		reason := fmt.Sprintf("invalid schedule: %s", err.Error())
+		if n := len(status.Conditions); n == 0 || status.Conditions[n-1].Reason != reason {
+			status.Conditions = addCondition(status.Conditions, svnv1alpha1.Condition{
+				Type:           svnv1alpha1.ConditionTypeFailed,
+				Reason:         reason,
+				TransitionTime: now.Format(time.RFC3339),
+			})
+		}
+		return ctrl.Result{}, nil
+	}
+
+	backups := &svnv1alpha1.SVNBackupList{}
+	err = r.List(ctx, backups, client.InNamespace(schedule.Namespace), client.MatchingLabels{LabelBackupScheduleKey: scheduleLabelOf(schedule)})
+	if err != nil {
+		log.Error(err, "Failed to list SVNBackup")
+		return ctrl.Result{}, err
+	}
+	sortBackups(backups.Items)
+	status.LastSuccessfulBackupName = ""
+	for i := range backups.Items {
+		if backups.Items[i].Status.Phase == svnv1alpha1.BackupPhaseSucceeded {
+			status.LastSuccessfulBackupName = backups.Items[i].Name
+			break
+		}
+	}
+
+	for _, b := range backupsToDelete(backups.Items, retentionOf(schedule)) {
+		log.Info("deleting SVNBackup beyond the retention", "SVNBackup.Name", b.Name)
+		if err := r.Delete(ctx, b); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete SVNBackup")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if schedule.Spec.Suspend {
+		return ctrl.Result{}, nil
+	}
+	last := schedule.CreationTimestamp.Time
+	if status.LastScheduleTime != nil {
+		last = status.LastScheduleTime.Time
+	}
+	scheduled := lastMissedSchedule(cronSchedule, last, now)
+	if !scheduled.IsZero() {
+		if running := runningBackupOf(backups.Items); running != "" {
+			// Backups of the same schedule never run at the same time; this one runs after the running one.
+			log.Info("postponing the backup until the previous one finishes", "SVNBackup.Name", running)
+			return ctrl.Result{RequeueAfter: BackupPollInterval}, nil
+		}
+		b, err := r.backupFor(schedule, backups.Items, scheduled)
+		if err != nil {
+			log.Error(err, "Failed to compute desired SVNBackup")
+			return ctrl.Result{}, err
+		}
+		log.Info("Creating a new SVNBackup", "SVNBackup.Name", b.Name, "incrementalFrom", b.Spec.IncrementalFrom)
+		if err := r.Create(ctx, b); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "Failed to create new SVNBackup")
+			return ctrl.Result{}, err
+		}
+		t := metav1.NewTime(scheduled)
+		status.LastScheduleTime = &t
+		status.LastBackupName = b.Name
+	}
+
+	next := cronSchedule.Next(now.UTC())
+	if next.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// retentionOf returns the number of succeeded SVNBackups to keep.
+func retentionOf(schedule *svnv1alpha1.SVNBackupSchedule) int {
+	if schedule.Spec.Retention <= 0 {
+		return DefaultBackupRetention
+	}
+	return int(schedule.Spec.Retention)
+}
+
+// lastMissedSchedule returns the last scheduled time in (last, now], or the zero time if there is none.
+func lastMissedSchedule(s *cron.Schedule, last, now time.Time) time.Time {
+	var missed time.Time
+	t := last.UTC()
+	for i := 0; i < maxMissedSchedules; i++ {
+		t = s.Next(t)
+		if t.IsZero() || t.After(now) {
+			break
+		}
+		missed = t
+	}
+	return missed
+}
+
+// backupFor returns an SVNBackup scheduled at the given time.
+func (r *SVNBackupScheduleReconciler) backupFor(schedule *svnv1alpha1.SVNBackupSchedule, backups []svnv1alpha1.SVNBackup, scheduled time.Time) (*svnv1alpha1.SVNBackup, error) {
+	// The name is unique for each scheduled time, so that the same backup is never created twice.
+	// It is short enough for the names of the Jobs of the backup.
+	suffix := fmt.Sprintf("-%d", scheduled.Unix()/60)
+	b := &svnv1alpha1.SVNBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shortNameOf(schedule.Name, maxBackupNameLength-len(suffix)) + suffix,
+			Namespace: schedule.Namespace,
+			Labels:    map[string]string{LabelBackupScheduleKey: scheduleLabelOf(schedule)},
+		},
+		Spec: *schedule.Spec.BackupTemplate.DeepCopy(),
+	}
+	b.Spec.IncrementalFrom = incrementalBaseOf(schedule, backups)
+	if err := ctrl.SetControllerReference(schedule, b, r.Scheme); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// scheduleLabelOf returns the value of LabelBackupScheduleKey of SVNBackups created by the schedule.
+func scheduleLabelOf(schedule *svnv1alpha1.SVNBackupSchedule) string {
+	return shortNameOf(schedule.Name, validation.LabelValueMaxLength)
+}
+
+// incrementalBaseOf returns the name of the SVNBackup that the next backup is based on,
+// or an empty string if the next backup should be a full backup.
+// backups must be sorted by sortBackups.
+func incrementalBaseOf(schedule *svnv1alpha1.SVNBackupSchedule, backups []svnv1alpha1.SVNBackup) string {
+	method := schedule.Spec.BackupTemplate.Method
+	if method != "" && method != svnv1alpha1.BackupMethodDump {
+		return ""
+	}
+	for i := range backups {
+		if backups[i].Status.Phase != svnv1alpha1.BackupPhaseSucceeded {
+			continue
+		}
+		if int32(chainLengthOf(&backups[i], backups))+1 >= schedule.Spec.FullBackupInterval {
+			return ""
+		}
+		return backups[i].Name
+	}
+	return ""
+}
+
+// chainLengthOf returns the number of incremental backups since the last full backup, including b itself.
+func chainLengthOf(b *svnv1alpha1.SVNBackup, backups []svnv1alpha1.SVNBackup) int {
+	byName := map[string]*svnv1alpha1.SVNBackup{}
+	for i := range backups {
+		byName[backups[i].Name] = &backups[i]
+	}
+	n := 0
+	for b.Spec.IncrementalFrom != "" && n < len(backups) {
+		n++
+		base, ok := byName[b.Spec.IncrementalFrom]
+		if !ok {
+			break
+		}
+		b = base
+	}
+	return n
+}
+
+// backupsToDelete returns SVNBackups beyond the retention.
+// It keeps the given number of the latest succeeded backups, the backups that they are based on,
+// backups that have not finished yet, and the latest failed backup for troubleshooting.
+// backups must be sorted by sortBackups.
+func backupsToDelete(backups []svnv1alpha1.SVNBackup, retention int) []*svnv1alpha1.SVNBackup {
+	byName := map[string]*svnv1alpha1.SVNBackup{}
+	for i := range backups {
+		byName[backups[i].Name] = &backups[i]
+	}
+	keep := map[string]bool{}
+	succeeded, failed := 0, 0
+	for i := range backups {
+		b := &backups[i]
+		switch b.Status.Phase {
+		case svnv1alpha1.BackupPhaseSucceeded:
+			if succeeded >= retention {
+				continue
+			}
+			succeeded++
+			for ; b != nil && !keep[b.Name]; b = byName[b.Spec.IncrementalFrom] {
+				keep[b.Name] = true
+			}
+		case svnv1alpha1.BackupPhaseFailed:
+			if failed == 0 {
+				keep[b.Name] = true
+			}
+			failed++
+		default:
+			keep[b.Name] = true
+		}
+	}
+	var deletions []*svnv1alpha1.SVNBackup
+	for i := range backups {
+		if !keep[backups[i].Name] && backups[i].DeletionTimestamp.IsZero() {
+			deletions = append(deletions, &backups[i])
+		}
+	}
+	return deletions
+}
+
+// runningBackupOf returns the name of a backup that has not finished yet, or an empty string if there is none.
+func runningBackupOf(backups []svnv1alpha1.SVNBackup) string {
+	for i := range backups {
+		phase := backups[i].Status.Phase
+		if phase != svnv1alpha1.BackupPhaseSucceeded && phase != svnv1alpha1.BackupPhaseFailed && backups[i].DeletionTimestamp.IsZero() {
+			return backups[i].Name
+		}
+	}
+	return ""
+}
+
+// sortBackups sorts SVNBackups from the newest to the oldest.
+func sortBackups(backups []svnv1alpha1.SVNBackup) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		ti, tj := backups[i].CreationTimestamp.Time, backups[j].CreationTimestamp.Time
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+}
+
+func scheduleStatusEqual(a, b *svnv1alpha1.SVNBackupScheduleStatus) bool {
+	return len(a.Conditions) == len(b.Conditions) &&
+		timeEqual(a.LastScheduleTime, b.LastScheduleTime) &&
+		a.LastBackupName == b.LastBackupName &&
+		a.LastSuccessfulBackupName == b.LastSuccessfulBackupName
+}
+
+func timeEqual(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SVNBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&svnv1alpha1.SVNBackupSchedule{}).
+		Owns(&svnv1alpha1.SVNBackup{}).
+		Complete(r)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/cron"
+)
+
+var _ = Describe("SVNBackupScheduleReconciler", func() {
+	backupOf := func(name, phase, base string) svnv1alpha1.SVNBackup {
+		return svnv1alpha1.SVNBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       svnv1alpha1.SVNBackupSpec{IncrementalFrom: base},
+			Status:     svnv1alpha1.SVNBackupStatus{Phase: phase},
+		}
+	}
+	namesOf := func(backups []*svnv1alpha1.SVNBackup) []string {
+		names := []string{}
+		for _, b := range backups {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+	const (
+		succeeded = svnv1alpha1.BackupPhaseSucceeded
+		failed    = svnv1alpha1.BackupPhaseFailed
+		running   = svnv1alpha1.BackupPhaseRunning
+	)
+
+	Describe("backupsToDelete", func() {
+		It("keeps the latest succeeded backups", func() {
+			backups := []svnv1alpha1.SVNBackup{
+				backupOf("b5", running, ""),
+				backupOf("b4", succeeded, ""),
+				backupOf("b3", failed, ""),
+				backupOf("b2", succeeded, ""),
+				backupOf("b1", failed, ""),
+				backupOf("b0", succeeded, ""),
+			}
+			Expect(namesOf(backupsToDelete(backups, 2))).To(Equal([]string{"b1", "b0"}))
+		})
+
+		It("keeps the backups that the kept incremental backups are based on", func() {
+			backups := []svnv1alpha1.SVNBackup{
+				backupOf("b3", succeeded, "b2"),
+				backupOf("b2", succeeded, "b1"),
+				backupOf("b1", succeeded, ""),
+				backupOf("b0", succeeded, ""),
+			}
+			Expect(namesOf(backupsToDelete(backups, 1))).To(Equal([]string{"b0"}))
+		})
+	})
+
+	Describe("incrementalBaseOf", func() {
+		var schedule *svnv1alpha1.SVNBackupSchedule
+		BeforeEach(func() {
+			schedule = &svnv1alpha1.SVNBackupSchedule{Spec: svnv1alpha1.SVNBackupScheduleSpec{FullBackupInterval: 3}}
+		})
+
+		It("starts with a full backup", func() {
+			Expect(incrementalBaseOf(schedule, nil)).To(BeEmpty())
+		})
+
+		It("is based on the latest succeeded backup", func() {
+			backups := []svnv1alpha1.SVNBackup{
+				backupOf("b2", failed, "b1"),
+				backupOf("b1", succeeded, "b0"),
+				backupOf("b0", succeeded, ""),
+			}
+			Expect(incrementalBaseOf(schedule, backups[1:])).To(Equal("b1"))
+			Expect(incrementalBaseOf(schedule, backups[2:])).To(Equal("b0"))
+		})
+
+		It("takes a full backup every FullBackupInterval", func() {
+			backups := []svnv1alpha1.SVNBackup{
+				backupOf("b2", succeeded, "b1"),
+				backupOf("b1", succeeded, "b0"),
+				backupOf("b0", succeeded, ""),
+			}
+			Expect(incrementalBaseOf(schedule, backups)).To(BeEmpty())
+		})
+
+		It("always takes full backups with hotcopy", func() {
+			schedule.Spec.BackupTemplate.Method = svnv1alpha1.BackupMethodHotcopy
+			Expect(incrementalBaseOf(schedule, []svnv1alpha1.SVNBackup{backupOf("b0", succeeded, "")})).To(BeEmpty())
+		})
+	})
+
+	Describe("backupFor", func() {
+		It("names backups so that the names of their Jobs are valid", func() {
+			Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+			r := &SVNBackupScheduleReconciler{Scheme: scheme.Scheme}
+			schedule := &svnv1alpha1.SVNBackupSchedule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly"}}
+			scheduled := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+			b, err := r.backupFor(schedule, nil, scheduled)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Name).To(Equal("nightly-26997120"))
+
+			schedule.Name = strings.Repeat("a", validation.DNS1123SubdomainMaxLength)
+			b, err = r.backupFor(schedule, nil, scheduled)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Name).To(HaveSuffix("-26997120"))
+			Expect(len(cleanupJobNameOf(b))).To(BeNumerically("<=", validation.DNS1123LabelMaxLength))
+			Expect(len(b.Labels[LabelBackupScheduleKey])).To(BeNumerically("<=", validation.LabelValueMaxLength))
+		})
+	})
+
+	Describe("lastMissedSchedule", func() {
+		It("returns the last schedule before now", func() {
+			s, err := cron.Parse("0 * * * *")
+			Expect(err).NotTo(HaveOccurred())
+			last := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+			Expect(lastMissedSchedule(s, last, last.Add(30*time.Minute))).To(BeZero())
+			Expect(lastMissedSchedule(s, last, last.Add(150*time.Minute))).To(Equal(last.Add(2 * time.Hour)))
+		})
+	})
+})
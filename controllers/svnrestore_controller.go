@@ -0,0 +1,416 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/backup"
+)
+
+const (
+	ContainerNameRestore = "restore"
+
+	// RestoreCommand is a path to the `svn-restore` command in the SVN server image.
+	RestoreCommand = "/work/svn-restore"
+
+	// LabelRestoreKey is a label of Jobs and Pods that run SVNRestores.
+	LabelRestoreKey = "svn.k8s.oyasumi.club/restore"
+
+	// RestoreMaintenanceTimeout is how long a restore Job waits for the repository to become read-only
+	// before it replaces the repository.
+	RestoreMaintenanceTimeout = 5 * time.Minute
+
+	// maxBackupChainLength limits the number of backups to follow, in case IncrementalFrom forms a cycle.
+	maxBackupChainLength = 1000
+)
+
+// SVNRestoreReconciler reconciles a SVNRestore object
+type SVNRestoreReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// DefaultSVNServerImage is a Docker image name to run SVN server, which contains `svn-restore` as well.
+	DefaultSVNServerImage string
+}
+
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnrestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnrestores/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+// Reconcile runs a Job that restores a repository from an SVNBackup and the backups it is based on,
+// and records the result that the Job reports in its termination message.
+// While the restore is running, SVNServerReconciler makes the repository read-only.
+func (r *SVNRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("svnrestore", req.NamespacedName)
+
+	rs := &svnv1alpha1.SVNRestore{}
+	if err := r.Get(ctx, req.NamespacedName, rs); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("SVNRestore not found; ignoring.")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get SVNRestore")
+		return ctrl.Result{}, err
+	}
+
+	if restoreFinished(rs) {
+		return ctrl.Result{}, nil
+	}
+
+	status := rs.Status.DeepCopy()
+	result, err := r.runRestore(ctx, log, rs, status)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if reflect.DeepEqual(status, &rs.Status) {
+		return result, nil
+	}
+	if status.Phase == svnv1alpha1.RestorePhaseSucceeded || status.Phase == svnv1alpha1.RestorePhaseFailed {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		log.Info("restore finished", "phase", status.Phase, "message", status.Message)
+	}
+	rs.Status = *status
+	if err := r.Status().Update(ctx, rs); err != nil {
+		log.Error(err, "Failed to update SVNRestore status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// runRestore creates the restore Job if it does not exist, and updates status according to the Job.
+func (r *SVNRestoreReconciler) runRestore(ctx context.Context, log logr.Logger, rs *svnv1alpha1.SVNRestore, status *svnv1alpha1.SVNRestoreStatus) (ctrl.Result, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: restoreJobNameOf(rs)}, job)
+	if err == nil {
+		return ctrl.Result{}, r.updateRestoreStatus(ctx, job, status)
+	}
+	if !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Job")
+		return ctrl.Result{}, err
+	}
+
+	pending := func(format string, args ...interface{}) (ctrl.Result, error) {
+		status.Phase = svnv1alpha1.RestorePhasePending
+		status.Message = fmt.Sprintf(format, args...)
+		return ctrl.Result{RequeueAfter: BackupPollInterval}, nil
+	}
+	failed := func(err error) (ctrl.Result, error) {
+		status.Phase = svnv1alpha1.RestorePhaseFailed
+		status.Message = err.Error()
+		return ctrl.Result{}, nil
+	}
+
+	if rs.Spec.SVNServer.NamespaceOr(rs.Namespace) != rs.Namespace {
+		return failed(fmt.Errorf("SVNServer must be in the same namespace as the SVNRestore"))
+	}
+	server := &svnv1alpha1.SVNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: rs.Spec.SVNServer.Name}, server); err != nil {
+		if errors.IsNotFound(err) {
+			return pending("SVNServer %q not found", rs.Spec.SVNServer.Name)
+		}
+		log.Error(err, "Failed to get SVNServer")
+		return ctrl.Result{}, err
+	}
+
+	// chain is ordered from the full backup to the one to restore.
+	var chain []*svnv1alpha1.SVNBackup
+	for name := rs.Spec.BackupName; name != ""; {
+		if len(chain) >= maxBackupChainLength {
+			return failed(fmt.Errorf("SVNBackup %q is based on too many backups", rs.Spec.BackupName))
+		}
+		b := &svnv1alpha1.SVNBackup{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: name}, b); err != nil {
+			if errors.IsNotFound(err) {
+				return pending("SVNBackup %q not found", name)
+			}
+			log.Error(err, "Failed to get SVNBackup")
+			return ctrl.Result{}, err
+		}
+		if b.Status.Phase != svnv1alpha1.BackupPhaseSucceeded && b.Status.Phase != svnv1alpha1.BackupPhaseFailed {
+			return pending("waiting for SVNBackup %q to finish", b.Name)
+		}
+		chain = append([]*svnv1alpha1.SVNBackup{b}, chain...)
+		name = b.Spec.IncrementalFrom
+	}
+	chain = restoreChainOf(rs, chain)
+	steps, err := restoreStepsOf(rs, chain)
+	if err != nil {
+		return failed(err)
+	}
+
+	repos := &svnv1alpha1.SVNRepositoryList{}
+	if err := r.List(ctx, repos, client.MatchingFields{IndexKeySVNServer: svnServerKey(server.Namespace, server.Name)}); err != nil {
+		log.Error(err, "Failed to list SVNRepository")
+		return ctrl.Result{}, err
+	}
+
+	job, err = r.restoreJobFor(server, rs, chain[0], steps, servedRepositoryOf(server, repos, rs.Spec.Repository))
+	if err != nil {
+		log.Error(err, "Failed to compute desired Job")
+		return ctrl.Result{}, err
+	}
+	log.Info("Creating a new Job", "Job.Name", job.Name)
+	if err := r.Create(ctx, job); err != nil {
+		log.Error(err, "Failed to create new Job")
+		return ctrl.Result{}, err
+	}
+	now := metav1.Now()
+	status.Phase = svnv1alpha1.RestorePhaseRunning
+	status.Message = ""
+	status.JobName = job.Name
+	status.StartTime = &now
+	status.Backups = make([]string, 0, len(chain))
+	for _, b := range chain {
+		status.Backups = append(status.Backups, b.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// restoreFinished returns true if the restore has succeeded or failed.
+func restoreFinished(rs *svnv1alpha1.SVNRestore) bool {
+	return rs.Status.Phase == svnv1alpha1.RestorePhaseSucceeded || rs.Status.Phase == svnv1alpha1.RestorePhaseFailed
+}
+
+// sourceRepositoryOf returns the name of the repository in the backup to restore.
+func sourceRepositoryOf(rs *svnv1alpha1.SVNRestore) string {
+	if rs.Spec.SourceRepository == "" {
+		return rs.Spec.Repository
+	}
+	return rs.Spec.SourceRepository
+}
+
+// restoreChainOf returns the backups in chain from the last full backup of the source repository.
+// A repository created after the full backup of the chain is fully dumped in the first incremental backup after it,
+// and the backups before that do not contain the repository at all.
+func restoreChainOf(rs *svnv1alpha1.SVNRestore, chain []*svnv1alpha1.SVNBackup) []*svnv1alpha1.SVNBackup {
+	source := sourceRepositoryOf(rs)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, repo := range chain[i].Status.Repositories {
+			if repo.Name == source && repo.FromRevision == 0 {
+				return chain[i:]
+			}
+		}
+	}
+	return chain
+}
+
+// restoreStepsOf returns the backups of the source repository to apply in order.
+// chain is a list of finished backups ordered from the full backup to the one to restore.
+func restoreStepsOf(rs *svnv1alpha1.SVNRestore, chain []*svnv1alpha1.SVNBackup) ([]backup.Step, error) {
+	source := sourceRepositoryOf(rs)
+	var steps []backup.Step
+	for _, b := range chain {
+		if b.Status.Phase != svnv1alpha1.BackupPhaseSucceeded {
+			return nil, fmt.Errorf("SVNBackup %q has failed", b.Name)
+		}
+		if !sameBackupStore(b, chain[0]) {
+			return nil, fmt.Errorf("SVNBackup %q is not stored in the same place as %q", b.Name, chain[0].Name)
+		}
+		var repo *svnv1alpha1.BackupRepositoryStatus
+		for i := range b.Status.Repositories {
+			if b.Status.Repositories[i].Name == source {
+				repo = &b.Status.Repositories[i]
+			}
+		}
+		if repo == nil {
+			return nil, fmt.Errorf("SVNBackup %q does not contain %q", b.Name, source)
+		}
+		if repo.File == "" {
+			// No new revisions have been found.
+			continue
+		}
+		// Files in buckets are specified by their keys.
+		p := path.Join(b.Status.Path, repo.File)
+		if b.Spec.Target.S3 == nil {
+			p = path.Join(VolumePathBackup, p)
+		}
+		steps = append(steps, backup.Step{Method: backupMethodOf(b), Path: p})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("SVNBackup %q contains no revisions of %q", rs.Spec.BackupName, source)
+	}
+	return steps, nil
+}
+
+// sameBackupStore returns true if both backups are stored in the same volume or the same bucket.
+func sameBackupStore(a, b *svnv1alpha1.SVNBackup) bool {
+	ta, tb := &a.Spec.Target, &b.Spec.Target
+	if ta.PersistentVolumeClaim != nil && tb.PersistentVolumeClaim != nil {
+		return ta.PersistentVolumeClaim.ClaimName == tb.PersistentVolumeClaim.ClaimName
+	}
+	if ta.S3 != nil && tb.S3 != nil {
+		return ta.S3.Endpoint == tb.S3.Endpoint && ta.S3.Region == tb.S3.Region &&
+			ta.S3.Bucket == tb.S3.Bucket && ta.S3.CredentialsSecret == tb.S3.CredentialsSecret
+	}
+	return false
+}
+
+// servedRepositoryOf returns the SVNRepository that the server serves with the given name, or nil if there is none.
+// The repository is made read-only while it is restored.
+func servedRepositoryOf(s *svnv1alpha1.SVNServer, repos *svnv1alpha1.SVNRepositoryList, name string) *svnv1alpha1.SVNRepository {
+	f := &GeneratorFactory{server: s}
+	for i := range repos.Items {
+		if repos.Items[i].DeletionTimestamp.IsZero() && f.nameOf(&repos.Items[i]) == name {
+			return &repos.Items[i]
+		}
+	}
+	return nil
+}
+
+// updateRestoreStatus updates status according to the state of the restore Job.
+func (r *SVNRestoreReconciler) updateRestoreStatus(ctx context.Context, job *batchv1.Job, status *svnv1alpha1.SVNRestoreStatus) error {
+	finished, succeeded := jobFinished(job)
+	if !finished {
+		status.Phase = svnv1alpha1.RestorePhaseRunning
+		return nil
+	}
+	message, err := terminationMessageOf(ctx, r, job, ContainerNameRestore, succeeded)
+	if err != nil {
+		return err
+	}
+	result, err := restoreResultOf(message)
+	if err != nil {
+		status.Phase = svnv1alpha1.RestorePhaseFailed
+		if succeeded {
+			status.Message = fmt.Sprintf("failed to read the result of the restore: %s", err.Error())
+		} else if status.Message = message; status.Message == "" {
+			status.Message = "the restore Job has failed"
+		}
+		return nil
+	}
+	if result.Verified || result.VerifyMessage != "" {
+		revision := result.Revision
+		status.RestoredRevision = &revision
+		status.Verify = &svnv1alpha1.RestoreVerifyStatus{Passed: result.Verified, Message: result.VerifyMessage}
+	}
+	if result.Archived != "" {
+		status.ArchivedPath = strings.TrimPrefix(result.Archived, VolumePathRepos+"/")
+	}
+	if !succeeded || result.Error != "" {
+		status.Phase = svnv1alpha1.RestorePhaseFailed
+		status.Message = result.Error
+		if status.Message == "" {
+			status.Message = "the restore Job has failed"
+		}
+		return nil
+	}
+	status.Phase = svnv1alpha1.RestorePhaseSucceeded
+	status.Message = ""
+	return nil
+}
+
+// restoreResultOf parses the result that `svn-restore` writes to its termination log.
+func restoreResultOf(message string) (*backup.RestoreResult, error) {
+	result := &backup.RestoreResult{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func restoreJobNameOf(rs *svnv1alpha1.SVNRestore) string {
+	return rs.Name + "-restore"
+}
+
+// restoreJobFor returns a Job that restores the repository on the server from the backups.
+// base is the first backup of the chain, whose target is where the backups are read from.
+// If the server serves repo, the Job waits for the existing repository to become read-only before replacing it,
+// and creates the repository with the same options as the server does.
+func (r *SVNRestoreReconciler) restoreJobFor(s *svnv1alpha1.SVNServer, rs *svnv1alpha1.SVNRestore, base *svnv1alpha1.SVNBackup, steps []backup.Step, repo *svnv1alpha1.SVNRepository) (*batchv1.Job, error) {
+	args := []string{
+		RestoreCommand,
+		"-repos-dir", path.Join(VolumePathRepos, "repos"),
+		"-trash-dir", path.Join(VolumePathRepos, "trash"),
+		"-staging-dir", path.Join(VolumePathRepos, "restoring"),
+		"-repository", rs.Spec.Repository,
+	}
+	if t := base.Spec.Target.S3; t != nil {
+		args = append(args, s3ArgsOf(t)...)
+	}
+	for _, step := range steps {
+		args = append(args, "-step", step.Method+":"+step.Path)
+	}
+	if repo != nil {
+		args = append(args, "-maintenance-timeout", RestoreMaintenanceTimeout.String())
+		if repo.Spec.FSType != "" {
+			args = append(args, "-fs-type", repo.Spec.FSType)
+		}
+		if repo.Spec.CompatibleVersion != "" {
+			args = append(args, "-compatible-version", repo.Spec.CompatibleVersion)
+		}
+	}
+	container := corev1.Container{
+		Name:         ContainerNameRestore,
+		Command:      args,
+		VolumeMounts: []corev1.VolumeMount{{Name: VolumeNameRepos, MountPath: VolumePathRepos}},
+	}
+	volumes := append([]corev1.Volume{repositoryVolumeOf(s)}, mountBackupTarget(base, &container, true)...)
+	podSpec := backupPodSpecFor(r.DefaultSVNServerImage, s, container, volumes...)
+	podSpec.Affinity = serverAffinityOf(s)
+
+	// The restore is not retried, since a failed restore may have replaced the repository.
+	backoffLimit := int32(0)
+	labels := map[string]string{LabelRestoreKey: rs.Name}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restoreJobNameOf(rs),
+			Namespace: rs.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       podSpec,
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(rs, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SVNRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&svnv1alpha1.SVNRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/backup"
+)
+
+var _ = Describe("SVNRestoreReconciler", func() {
+	var r *SVNRestoreReconciler
+	var server *svnv1alpha1.SVNServer
+	var rs *svnv1alpha1.SVNRestore
+	var full, incremental *svnv1alpha1.SVNBackup
+	BeforeEach(func() {
+		Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+		r = &SVNRestoreReconciler{Scheme: scheme.Scheme, DefaultSVNServerImage: "svn:latest"}
+		server = &svnv1alpha1.SVNServer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svn"},
+		}
+		rs = &svnv1alpha1.SVNRestore{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rollback"},
+			Spec: svnv1alpha1.SVNRestoreSpec{
+				SVNServer:  svnv1alpha1.SVNServerRef{Name: "svn"},
+				BackupName: "incremental",
+				Repository: "hoge",
+			},
+		}
+		newBackup := func(name string, repos ...svnv1alpha1.BackupRepositoryStatus) *svnv1alpha1.SVNBackup {
+			return &svnv1alpha1.SVNBackup{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				Spec: svnv1alpha1.SVNBackupSpec{
+					SVNServer: svnv1alpha1.SVNServerRef{Name: "svn"},
+					Target: svnv1alpha1.BackupTarget{
+						PersistentVolumeClaim: &svnv1alpha1.PersistentVolumeClaimBackupTarget{ClaimName: "backups"},
+					},
+				},
+				Status: svnv1alpha1.SVNBackupStatus{
+					Phase:        svnv1alpha1.BackupPhaseSucceeded,
+					Path:         "svn/" + name,
+					Repositories: repos,
+				},
+			}
+		}
+		full = newBackup("full",
+			svnv1alpha1.BackupRepositoryStatus{Name: "hoge", FromRevision: 0, ToRevision: 3, File: "hoge.dump.gz"},
+			svnv1alpha1.BackupRepositoryStatus{Name: "fuga", FromRevision: 0, ToRevision: 5, File: "fuga.dump.gz"},
+		)
+		incremental = newBackup("incremental",
+			svnv1alpha1.BackupRepositoryStatus{Name: "hoge", FromRevision: 4, ToRevision: 6, File: "hoge.dump.gz"},
+			svnv1alpha1.BackupRepositoryStatus{Name: "fuga", FromRevision: 6, ToRevision: 5},
+		)
+		incremental.Spec.IncrementalFrom = "full"
+	})
+
+	Describe("restoreChainOf", func() {
+		It("starts at the full backup", func() {
+			Expect(restoreChainOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})).To(Equal([]*svnv1alpha1.SVNBackup{full, incremental}))
+		})
+
+		It("starts at the backup that fully dumps repositories created after the full backup", func() {
+			rs.Spec.Repository = "piyo"
+			incremental.Status.Repositories = append(incremental.Status.Repositories,
+				svnv1alpha1.BackupRepositoryStatus{Name: "piyo", FromRevision: 0, ToRevision: 2, File: "piyo.dump.gz"})
+			latest := incremental.DeepCopy()
+			latest.Name = "latest"
+			latest.Status.Path = "svn/latest"
+			latest.Status.Repositories = []svnv1alpha1.BackupRepositoryStatus{
+				{Name: "piyo", FromRevision: 3, ToRevision: 4, File: "piyo.dump.gz"},
+			}
+			chain := restoreChainOf(rs, []*svnv1alpha1.SVNBackup{full, incremental, latest})
+			Expect(chain).To(Equal([]*svnv1alpha1.SVNBackup{incremental, latest}))
+			Expect(restoreStepsOf(rs, chain)).To(Equal([]backup.Step{
+				{Method: backup.MethodDump, Path: "/svn-backup/svn/incremental/piyo.dump.gz"},
+				{Method: backup.MethodDump, Path: "/svn-backup/svn/latest/piyo.dump.gz"},
+			}))
+		})
+	})
+
+	Describe("restoreStepsOf", func() {
+		It("applies the backups in order", func() {
+			Expect(restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})).To(Equal([]backup.Step{
+				{Method: backup.MethodDump, Path: "/svn-backup/svn/full/hoge.dump.gz"},
+				{Method: backup.MethodDump, Path: "/svn-backup/svn/incremental/hoge.dump.gz"},
+			}))
+		})
+
+		It("skips backups with no new revisions", func() {
+			rs.Spec.SourceRepository = "fuga"
+			Expect(restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})).To(Equal([]backup.Step{
+				{Method: backup.MethodDump, Path: "/svn-backup/svn/full/fuga.dump.gz"},
+			}))
+		})
+
+		It("restores hotcopies", func() {
+			full.Spec.Method = svnv1alpha1.BackupMethodHotcopy
+			full.Status.Repositories[0].File = "hoge"
+			Expect(restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full})).To(Equal([]backup.Step{
+				{Method: backup.MethodHotcopy, Path: "/svn-backup/svn/full/hoge"},
+			}))
+		})
+
+		It("rejects failed backups", func() {
+			full.Status.Phase = svnv1alpha1.BackupPhaseFailed
+			_, err := restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects backups that do not contain the repository", func() {
+			rs.Spec.Repository = "piyo"
+			_, err := restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("reads dump files in S3-compatible buckets by their keys", func() {
+			for _, b := range []*svnv1alpha1.SVNBackup{full, incremental} {
+				b.Spec.Target = svnv1alpha1.BackupTarget{S3: &svnv1alpha1.S3BackupTarget{Endpoint: "http://minio:9000", Bucket: "backups"}}
+			}
+			Expect(restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})).To(Equal([]backup.Step{
+				{Method: backup.MethodDump, Path: "svn/full/hoge.dump.gz"},
+				{Method: backup.MethodDump, Path: "svn/incremental/hoge.dump.gz"},
+			}))
+			incremental.Spec.Target.S3.Bucket = "other"
+			_, err := restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects backups stored in different volumes", func() {
+			incremental.Spec.Target.PersistentVolumeClaim.ClaimName = "other"
+			_, err := restoreStepsOf(rs, []*svnv1alpha1.SVNBackup{full, incremental})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("restoreJobFor", func() {
+		steps := []backup.Step{{Method: backup.MethodDump, Path: "/svn-backup/svn/full/hoge.dump.gz"}}
+
+		It("mounts the volume of the server on the same node", func() {
+			job, err := r.restoreJobFor(server, rs, full, steps, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Name).To(Equal("rollback-restore"))
+			Expect(*job.Spec.BackoffLimit).To(BeZero())
+			pod := job.Spec.Template.Spec
+			Expect(pod.Volumes).To(HaveLen(2))
+			Expect(pod.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("repos-svn-0"))
+			Expect(pod.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+			Expect(pod.Affinity.PodAffinity).NotTo(BeNil())
+			Expect(pod.Containers[0].Command).To(Equal([]string{
+				RestoreCommand,
+				"-repos-dir", "/svn/repos",
+				"-trash-dir", "/svn/trash",
+				"-staging-dir", "/svn/restoring",
+				"-repository", "hoge",
+				"-step", "Dump:/svn-backup/svn/full/hoge.dump.gz",
+			}))
+		})
+
+		It("reads dump files from S3-compatible buckets", func() {
+			full.Spec.Target = svnv1alpha1.BackupTarget{S3: &svnv1alpha1.S3BackupTarget{
+				Endpoint:          "http://minio:9000",
+				Bucket:            "backups",
+				CredentialsSecret: corev1.LocalObjectReference{Name: "minio"},
+			}}
+			job, err := r.restoreJobFor(server, rs, full, []backup.Step{{Method: backup.MethodDump, Path: "svn/full/hoge.dump.gz"}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			pod := job.Spec.Template.Spec
+			Expect(pod.Volumes).To(HaveLen(1))
+			Expect(pod.Containers[0].Command).To(ContainElements("-s3-endpoint", "http://minio:9000", "-s3-bucket", "backups", "-step", "Dump:svn/full/hoge.dump.gz"))
+			Expect(pod.Containers[0].Env).To(HaveLen(3))
+		})
+
+		It("waits for the maintenance of served repositories", func() {
+			repo := &svnv1alpha1.SVNRepository{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hoge"}}
+			job, err := r.restoreJobFor(server, rs, full, steps, repo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("-maintenance-timeout", "5m0s"))
+		})
+
+		It("creates the repository with the options of the served repository", func() {
+			repo := &svnv1alpha1.SVNRepository{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hoge"},
+				Spec:       svnv1alpha1.SVNRepositorySpec{FSType: svnv1alpha1.FSTypeFSX, CompatibleVersion: "1.9"},
+			}
+			job, err := r.restoreJobFor(server, rs, full, steps, repo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("-fs-type", "fsx", "-compatible-version", "1.9"))
+		})
+	})
+
+	Describe("servedRepositoryOf", func() {
+		It("finds repositories by their names on the server", func() {
+			repos := &svnv1alpha1.SVNRepositoryList{Items: []svnv1alpha1.SVNRepository{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hoge"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "fuga"}},
+			}}
+			Expect(servedRepositoryOf(server, repos, "hoge")).To(Equal(&repos.Items[0]))
+			Expect(servedRepositoryOf(server, repos, "other_fuga")).To(Equal(&repos.Items[1]))
+			Expect(servedRepositoryOf(server, repos, "fuga")).To(BeNil())
+		})
+	})
+
+	Describe("restoreResultOf", func() {
+		It("parses the result of svn-restore", func() {
+			result, err := restoreResultOf(`{"revision":6,"verified":true,"archived":"/svn/trash/hoge-20210501T000000Z"}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(&backup.RestoreResult{Revision: 6, Verified: true, Archived: "/svn/trash/hoge-20210501T000000Z"}))
+		})
+
+		It("rejects other messages", func() {
+			_, err := restoreResultOf("panic: something went wrong")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
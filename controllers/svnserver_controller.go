@@ -18,25 +18,41 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	"golang.org/x/crypto/bcrypt"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/commitpolicy"
+	"github.com/genkami/svn-operator/pkg/serverupdater"
 	svnconfig "github.com/genkami/svn-operator/pkg/svnconfig"
 )
 
@@ -45,18 +61,64 @@ const (
 	VolumePathRepos  = "/svn"
 	VolumeNameConfig = "config"
 	VolumePathConfig = "/etc/svn-config/"
+	// VolumePathAuth contains the files that have credentials, which are kept in a Secret rather than the ConfigMap.
+	VolumeNameAuth = "auth"
+	VolumePathAuth = "/etc/svn-auth/"
+
+	// Volumes that contain sources of repositories are named with VolumeNamePrefixSource,
+	// and mounted under VolumePathSources.
+	VolumeNamePrefixSource = "source-"
+	VolumePathSources      = "/svn-sources"
+
+	// VolumePathReplication is where replicas find the credentials to read repositories on the primary.
+	VolumeNameReplication = "replication"
+	VolumePathReplication = "/etc/svn-replication"
+
+	// VolumePathUpdater is where server-updater finds the token that clients must send to read its status.
+	VolumeNameUpdater = "updater"
+	VolumePathUpdater = "/etc/svn-updater"
+
+	// EnvPrimaryHost is the host name of the primary Pod. Every other Pod runs as a read-only replica of it.
+	EnvPrimaryHost = "SVN_PRIMARY_HOST"
 
 	ContainerNameSVN = "svn"
 
+	// UpdaterPort is a port that server-updater serves its status on.
+	UpdaterPort = 8081
+
 	LabelAppKey          = "app"
 	LabelAppValue        = "subversion"
 	LabelInstanceNameKey = "svn.k8s.oyasumi.club/name"
 
-	ConfigMapKeyAuthUserFile       = "AuthUserFile"
-	ConfigMapKeyAuthzSVNAccessFile = "AuthzSVNAccessFile"
-	ConfigMapKeyRepos              = "Repos"
+	ConfigMapKeyRepos        = "Repos"
+	ConfigMapKeyApacheConfig = "ApacheConfig"
+
+	SecretKeyAuthUserFile       = "AuthUserFile"
+	SecretKeyAuthzSVNAccessFile = "AuthzSVNAccessFile"
+
+	// SecretKeyUpdaterToken is a key of the updater Secret that holds the token to read the status of server-updater.
+	SecretKeyUpdaterToken = "token"
+
+	// SecretKeyEncryptedPassword is a key of the replication Secret that holds the hash of the password.
+	// It is computed once so that the ConfigMap does not change on every reconciliation.
+	SecretKeyEncryptedPassword = "encryptedPassword"
 
 	IndexKeySVNServer = ".spec.svnServer"
+	// IndexKeyConfigMap indexes SVNRepositories by ConfigMaps that they refer to as their seed files or hooks.
+	IndexKeyConfigMap = ".spec.configMaps"
+	// IndexKeySecret indexes SVNUsers by Secrets that they refer to as their passwords.
+	IndexKeySecret = ".spec.passwordSecretRef"
+
+	// FinalizerRepository is a finalizer that keeps SVNRepositories until actual repositories are archived or deleted.
+	FinalizerRepository = "svn.k8s.oyasumi.club/repository"
+
+	// UpdaterPollInterval is an interval to check the status of server-updater while waiting for it.
+	UpdaterPollInterval = 10 * time.Second
+	// StatusPollInterval is an interval to check the state of repositories reported by server-updater.
+	StatusPollInterval = time.Minute
+
+	// DefaultMirrorInterval is the default interval between synchronizations of mirrors.
+	DefaultMirrorInterval = 5 * time.Minute
 
 	ConditionHistoryLimit = 10
 )
@@ -69,6 +131,31 @@ type SVNServerReconciler struct {
 
 	// DefaultSVNServerImage is a Docker image name to run SVN server.
 	DefaultSVNServerImage string
+
+	// UpdaterClient fetches the status of SVN servers from server-updater.
+	UpdaterClient UpdaterClient
+
+	// passwordHashes caches the hashes of passwords in Secrets, since bcrypt is slow and
+	// generates a different hash every time, which would rewrite AuthUserFile on every reconciliation.
+	passwordHashes   map[passwordKey]passwordHash
+	passwordHashesMu sync.Mutex
+}
+
+// passwordKey is a key of a Secret that holds a password.
+type passwordKey struct {
+	types.NamespacedName
+	Key string
+}
+
+// passwordHash is a hash of a password in a Secret at the given resourceVersion.
+type passwordHash struct {
+	ResourceVersion string
+	Hash            string
+}
+
+// UpdaterClient fetches the status of SVN servers from server-updater.
+type UpdaterClient interface {
+	Status(ctx context.Context, baseURL, token string) (*serverupdater.Status, error)
 }
 
 type GeneratorFactory struct {
@@ -76,6 +163,26 @@ type GeneratorFactory struct {
 	repos  *svnv1alpha1.SVNRepositoryList
 	groups *svnv1alpha1.SVNGroupList
 	users  *svnv1alpha1.SVNUserList
+
+	// restores is a list of SVNRestores that restore repositories on the server.
+	restores *svnv1alpha1.SVNRestoreList
+
+	// configMaps is a set of ConfigMaps that SVNRepositories refer to as their seed files or hooks.
+	configMaps map[types.NamespacedName]*corev1.ConfigMap
+
+	// replication is a Secret that has the credentials of replicas, or nil if the server has no replicas.
+	replication *corev1.Secret
+
+	// passwords is a set of hashes of passwords in Secrets that SVNUsers refer to.
+	// Missing Secrets and keys are not contained.
+	passwords map[passwordKey]string
+
+	// now is the time to determine whether SVNUsers have expired.
+	now time.Time
+
+	// legacyAuth is true while servers created before the credentials were moved to the Secret
+	// still have Pods that do not mount it. The credentials are kept in the ConfigMap in the meantime.
+	legacyAuth bool
 }
 
 // +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnservers,verbs=get;list;watch;create;update;patch;delete
@@ -90,14 +197,21 @@ type GeneratorFactory struct {
 // +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnusers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnusers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnusers/finalizers,verbs=update
+// +kubebuilder:rbac:groups=svn.k8s.oyasumi.club,resources=svnrestores,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 // Reconcile does the following things:
 //   + Creates StatefulSets for the SVN server.
 //   + Creates Headless Services for the StatefulSets.
+//   + Creates Services that balance requests between the primary and replicas.
 //   + Creates ConfigMaps that contain configuration files for Apache2 inside SVN server.
+//     This includes the configuration of the location that serves SVN repositories.
+//   + Creates Secrets that contain the users and their permissions.
+//   + Creates Secrets that contain the token to read the status of server-updater.
 func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("svnserver", req.NamespacedName)
 
@@ -107,7 +221,7 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		if errors.IsNotFound(err) {
 			// The object cloud have been deleted asynchronously.
 			log.Info("SVNServer not found; ignoring.")
-			return ctrl.Result{}, nil
+			return ctrl.Result{}, r.releaseOrphanedRepositories(ctx, log, req.NamespacedName)
 		}
 		log.Error(err, "Failed to get SVNServer")
 		return ctrl.Result{}, err
@@ -124,6 +238,50 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
+	lbSvc := &corev1.Service{}
+	err = r.Get(ctx, types.NamespacedName{Name: loadBalancerServiceNameOf(svnServer), Namespace: svnServer.Namespace}, lbSvc)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.createLoadBalancerService(ctx, log, svnServer); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil
+		}
+		log.Error(err, "Failed to get Service")
+		return ctrl.Result{}, err
+	}
+
+	var replication *corev1.Secret
+	if replicasOf(svnServer) > 1 {
+		replication = &corev1.Secret{}
+		err = r.Get(ctx, types.NamespacedName{Name: replicationSecretNameOf(svnServer), Namespace: svnServer.Namespace}, replication)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				if err = r.createReplicationSecret(ctx, log, svnServer); err != nil {
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{Requeue: true}, nil
+			}
+			log.Error(err, "Failed to get Secret")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The Secret is created before the StatefulSet starts mounting it.
+	updaterSecret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: updaterSecretNameOf(svnServer), Namespace: svnServer.Namespace}, updaterSecret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.createUpdaterSecret(ctx, log, svnServer); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil
+		}
+		log.Error(err, "Failed to get Secret")
+		return ctrl.Result{}, err
+	}
+	updaterToken := string(updaterSecret.Data[SecretKeyUpdaterToken])
+
 	ss := &appsv1.StatefulSet{}
 	err = r.Get(ctx, types.NamespacedName{Name: svnServer.Name, Namespace: svnServer.Namespace}, ss)
 	if err != nil {
@@ -137,34 +295,85 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
+	serverKey := svnServerKey(svnServer.Namespace, svnServer.Name)
+
 	repos := &svnv1alpha1.SVNRepositoryList{}
-	err = r.List(ctx, repos, client.InNamespace(svnServer.Namespace), client.MatchingFields{IndexKeySVNServer: svnServer.Name})
+	err = r.List(ctx, repos, client.MatchingFields{IndexKeySVNServer: serverKey})
 	if err != nil {
 		log.Error(err, "Failed to list SVNRepository")
 		return ctrl.Result{}, err
 	}
 
 	groups := &svnv1alpha1.SVNGroupList{}
-	err = r.List(ctx, groups, client.InNamespace(svnServer.Namespace), client.MatchingFields{IndexKeySVNServer: svnServer.Name})
+	err = r.List(ctx, groups, client.MatchingFields{IndexKeySVNServer: serverKey})
 	if err != nil {
 		log.Error(err, "Failed to list SVNGroup")
 		return ctrl.Result{}, err
 	}
 
 	users := &svnv1alpha1.SVNUserList{}
-	err = r.List(ctx, users, client.InNamespace(svnServer.Namespace), client.MatchingFields{IndexKeySVNServer: svnServer.Name})
+	err = r.List(ctx, users, client.MatchingFields{IndexKeySVNServer: serverKey})
 	if err != nil {
 		log.Error(err, "Failed to list SVNUser")
 		return ctrl.Result{}, err
 	}
 
+	restores := &svnv1alpha1.SVNRestoreList{}
+	err = r.List(ctx, restores, client.MatchingFields{IndexKeySVNServer: serverKey})
+	if err != nil {
+		log.Error(err, "Failed to list SVNRestore")
+		return ctrl.Result{}, err
+	}
+
+	allowedNamespaces, err := r.allowedNamespacesFor(ctx, svnServer)
+	if err != nil {
+		log.Error(err, "Failed to list allowed namespaces")
+		return ctrl.Result{}, err
+	}
+	excludeDisallowedNamespaces(log, allowedNamespaces, repos, groups, users)
+
+	if err := r.updateFinalizers(ctx, log, repos); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	configMaps, err := r.configMapsFor(ctx, repos)
+	if err != nil {
+		log.Error(err, "Failed to get ConfigMaps referred to by SVNRepositories")
+		return ctrl.Result{}, err
+	}
+
+	passwords, err := r.passwordsFor(ctx, users)
+	if err != nil {
+		log.Error(err, "Failed to get passwords referred to by SVNUsers")
+		return ctrl.Result{}, err
+	}
+
 	log.Info("reconciling SVNServer")
 
 	factory := &GeneratorFactory{
-		server: svnServer,
-		repos:  repos,
-		groups: groups,
-		users:  users,
+		server:      svnServer,
+		repos:       repos,
+		groups:      groups,
+		users:       users,
+		restores:    restores,
+		configMaps:  configMaps,
+		replication: replication,
+		passwords:   passwords,
+		now:         time.Now(),
+	}
+
+	// The StatefulSet created above may already mount the Secret, and its Pods wait until the Secret is created here.
+	authSecret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: authSecretNameOf(svnServer), Namespace: svnServer.Namespace}, authSecret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.createAuthSecret(ctx, log, factory); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true}, nil
+		}
+		log.Error(err, "Failed to get Secret")
+		return ctrl.Result{}, err
 	}
 
 	cm := &corev1.ConfigMap{}
@@ -179,11 +388,17 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		log.Error(err, "Failed to get ConfigMap")
 		return ctrl.Result{}, err
 	}
+	// The ConfigMap keeps the credentials until every Pod mounts the Secret, since Pods that do not
+	// would otherwise lose them as soon as the ConfigMap points to the Secret.
+	if _, ok := cm.Data[SecretKeyAuthUserFile]; ok && !authSecretMounted(ss) {
+		factory.legacyAuth = true
+	}
 
 	changed := false
 
 	desiredSS := ss.DeepCopy()
 	r.overrideWithPodTemplate(svnServer, desiredSS)
+	setSourceVolumes(desiredSS, factory.SourceVolumes())
 	if !reflect.DeepEqual(desiredSS, ss) {
 		changed = true
 		if err := r.Update(ctx, desiredSS); err != nil {
@@ -197,6 +412,8 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		log.Error(err, "Failed to compute desired configmap")
 		return ctrl.Result{}, err
 	}
+	// This also removes the credentials from ConfigMaps created before they were moved to Secrets
+	// once every Pod mounts the Secret.
 	if !reflect.DeepEqual(desiredCM.Data, cm.Data) {
 		changed = true
 		if err := r.Update(ctx, desiredCM); err != nil {
@@ -205,168 +422,980 @@ func (r *SVNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
-	if !changed {
-		return ctrl.Result{}, nil
+	desiredSecret, err := r.authSecretFor(factory)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Secret")
+		return ctrl.Result{}, err
+	}
+	if !reflect.DeepEqual(desiredSecret.Data, authSecret.Data) {
+		changed = true
+		if err := r.Update(ctx, desiredSecret); err != nil {
+			log.Error(err, "Failed to update Secret")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.updateGroupStatuses(ctx, log, factory); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateUserStatuses(ctx, log, factory); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	updaterStatus, err := r.UpdaterClient.Status(ctx, updaterURLFor(svnServer), updaterToken)
+	if err != nil {
+		log.Info("server-updater is not available; waiting for it", "error", err.Error())
+		updaterStatus = nil
 	}
 
-	svnServer.Status.Conditions = addCondition(svnServer.Status.Conditions, svnv1alpha1.Condition{
-		Type:           svnv1alpha1.ConditionTypeSynced,
-		Reason:         "successfully synced",
-		TransitionTime: time.Now().Format(time.RFC3339),
-	})
+	if err := r.updateRepositoryStatuses(ctx, log, factory, updaterStatus); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	if err := r.finalizeRepositories(ctx, log, factory, updaterStatus, desiredCM.Data[ConfigMapKeyRepos]); err != nil {
+		return ctrl.Result{}, err
+	}
+	if factory.NeedsUpdaterStatus() {
+		result.RequeueAfter = UpdaterPollInterval
+	} else {
+		// The repositories change without any changes in Kubernetes, e.g. by commits.
+		result.RequeueAfter = StatusPollInterval
+	}
+	if expiry := factory.NextExpiry(); expiry != nil {
+		// Users must stop logging in on time.
+		if d := expiry.Sub(factory.now); d < result.RequeueAfter {
+			result.RequeueAfter = d
+		}
+	}
+
+	usage := serverUsageOf(svnServer, updaterStatus)
+	usageChanged := !usageEqual(usage, svnServer.Status.Usage)
+	replicaStatuses := r.replicaStatusesOf(ctx, svnServer, updaterStatus, updaterToken)
+	replicasChanged := !replicaStatusesEqual(replicaStatuses, svnServer.Status.Replicas)
+	if !changed && !usageChanged && !replicasChanged {
+		return result, nil
+	}
+
+	svnServer.Status.Usage = usage
+	svnServer.Status.Replicas = replicaStatuses
+	if changed {
+		svnServer.Status.Conditions = addCondition(svnServer.Status.Conditions, svnv1alpha1.Condition{
+			Type:           svnv1alpha1.ConditionTypeSynced,
+			Reason:         "successfully synced",
+			TransitionTime: time.Now().Format(time.RFC3339),
+		})
+	}
 
 	if err := r.Status().Update(ctx, svnServer); err != nil {
 		log.Error(err, "Failed to update SVNServer status")
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
-// Creates a StatefulSet and is corresponding Service
-func (r *SVNServerReconciler) createStatefulSet(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
-	ss, err := r.statefulSetFor(svn)
-	if err != nil {
-		log.Error(err, "Failed to compute desired StatefulSet")
-		return err
-	}
-	log = log.WithValues("StatefulSet.Namespace", ss.Namespace, "StatefulSet.Name", ss.Name)
-	log.Info("Creating a new StatefulSet")
-	if err := r.Create(ctx, ss); err != nil {
-		log.Error(err, "Failed to create new StatefulSet")
-		return err
+// updateFinalizers adds FinalizerRepository to SVNRepositories that need to be archived or deleted on deletion,
+// and removes it from ones that do not.
+func (r *SVNServerReconciler) updateFinalizers(ctx context.Context, log logr.Logger, repos *svnv1alpha1.SVNRepositoryList) error {
+	for i := range repos.Items {
+		repo := &repos.Items[i]
+		has := controllerutil.ContainsFinalizer(repo, FinalizerRepository)
+		needs := needsFinalizer(repo)
+		if has == needs {
+			continue
+		}
+		if needs {
+			controllerutil.AddFinalizer(repo, FinalizerRepository)
+		} else {
+			controllerutil.RemoveFinalizer(repo, FinalizerRepository)
+		}
+		if err := r.Update(ctx, repo); err != nil {
+			log.Error(err, "Failed to update finalizers of SVNRepository", "SVNRepository.Namespace", repo.Namespace, "SVNRepository.Name", repo.Name)
+			return err
+		}
 	}
 	return nil
 }
 
-func (r *SVNServerReconciler) createService(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
-	svc, err := r.serviceFor(svn)
-	if err != nil {
-		log.Error(err, "Failed to compute desired Service")
-		return err
+// needsFinalizer returns true if the SVNRepository should be kept until server-updater removes the actual repository.
+func needsFinalizer(repo *svnv1alpha1.SVNRepository) bool {
+	if !repo.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(repo, FinalizerRepository) {
+		// Finalizers cannot be added to objects being deleted.
+		return false
 	}
-	log = log.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
-	log.Info("Creating a new Service")
-	if err := r.Create(ctx, svc); err != nil {
-		log.Error(err, "Failed to create new Service")
-		return err
+	return deletionPolicyOf(repo) != svnv1alpha1.DeletionPolicyRetain
+}
+
+func deletionPolicyOf(repo *svnv1alpha1.SVNRepository) string {
+	if repo.Spec.DeletionPolicy == "" {
+		return svnv1alpha1.DeletionPolicyRetain
 	}
-	return nil
+	return repo.Spec.DeletionPolicy
 }
 
-func (r *SVNServerReconciler) createConfigMap(ctx context.Context, log logr.Logger, f *GeneratorFactory) error {
-	cm, err := r.configMapFor(f)
-	if err != nil {
-		log.Error(err, "Failed to compute desired ConfigMap")
-		return err
+// finalizeRepositories removes FinalizerRepository from SVNRepositories being deleted once server-updater confirms
+// that the actual repositories are archived or deleted.
+// status is the status of server-updater, which is nil if it is not available.
+// reposConfig is the content of ConfigMapKeyRepos that contains the deletions.
+func (r *SVNServerReconciler) finalizeRepositories(ctx context.Context, log logr.Logger, f *GeneratorFactory, status *serverupdater.Status, reposConfig string) error {
+	deleting := f.deletingRepositories()
+	if len(deleting) == 0 || status == nil {
+		return nil
 	}
-	log = log.WithValues("ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
-	log.Info("Creating a new ConfigMap")
-	if err := r.Create(ctx, cm); err != nil {
-		log.Error(err, "Failed to create new ConfigMap")
-		return err
+	if status.ReposConfigHash != serverupdater.HashReposConfig(reposConfig) {
+		log.Info("server-updater has not applied the latest configuration yet; waiting for it")
+		return nil
+	}
+	for _, repo := range deleting {
+		if status.HasRepository(f.nameOf(repo)) {
+			continue
+		}
+		controllerutil.RemoveFinalizer(repo, FinalizerRepository)
+		if err := r.Update(ctx, repo); err != nil {
+			log.Error(err, "Failed to remove finalizer from SVNRepository", "SVNRepository.Namespace", repo.Namespace, "SVNRepository.Name", repo.Name)
+			return err
+		}
 	}
 	return nil
 }
 
-func (r *SVNServerReconciler) statefulSetFor(s *svnv1alpha1.SVNServer) (*appsv1.StatefulSet, error) {
-	labels := r.labelsFor(s)
-	replicas := int32(1)
-	ss := &appsv1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      s.Name,
-			Namespace: s.Namespace,
-		},
-		Spec: appsv1.StatefulSetSpec{
-			Replicas: &replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: labels,
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-			},
-			ServiceName: s.Name,
-		},
-	}
-	r.overrideWithPodTemplate(s, ss)
-	err := ctrl.SetControllerReference(s, ss, r.Scheme)
+// releaseOrphanedRepositories removes FinalizerRepository from SVNRepositories being deleted
+// that belong to the missing SVNServer, since there is nothing to archive or delete.
+func (r *SVNServerReconciler) releaseOrphanedRepositories(ctx context.Context, log logr.Logger, server types.NamespacedName) error {
+	repos := &svnv1alpha1.SVNRepositoryList{}
+	err := r.List(ctx, repos, client.MatchingFields{IndexKeySVNServer: svnServerKey(server.Namespace, server.Name)})
 	if err != nil {
-		return nil, err
+		log.Error(err, "Failed to list SVNRepository")
+		return err
 	}
-	return ss, nil
+	for i := range repos.Items {
+		repo := &repos.Items[i]
+		if repo.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(repo, FinalizerRepository) {
+			continue
+		}
+		controllerutil.RemoveFinalizer(repo, FinalizerRepository)
+		if err := r.Update(ctx, repo); err != nil {
+			log.Error(err, "Failed to remove finalizer from SVNRepository", "SVNRepository.Namespace", repo.Namespace, "SVNRepository.Name", repo.Name)
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *SVNServerReconciler) overrideWithPodTemplate(s *svnv1alpha1.SVNServer, ss *appsv1.StatefulSet) {
-	var volumeClaimIndex int = -1
-	for i := range ss.Spec.VolumeClaimTemplates {
-		pvc := &ss.Spec.VolumeClaimTemplates[i]
-		if pvc.Name == VolumeNameRepos {
-			volumeClaimIndex = i
-			break
+// setSourceVolumes replaces volumes that contain sources of repositories in the StatefulSet with the given volumes.
+// Existing volumes are kept as is, since the names of volumes are derived from their sources
+// and the API server may fill default values in them.
+func setSourceVolumes(ss *appsv1.StatefulSet, volumes []corev1.Volume) {
+	podSpec := &ss.Spec.Template.Spec
+	existing := map[string]corev1.Volume{}
+	newVolumes := make([]corev1.Volume, 0, len(podSpec.Volumes)+len(volumes))
+	for _, v := range podSpec.Volumes {
+		if strings.HasPrefix(v.Name, VolumeNamePrefixSource) {
+			existing[v.Name] = v
+			continue
 		}
+		newVolumes = append(newVolumes, v)
 	}
-	if volumeClaimIndex < 0 {
-		pvc := corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: VolumeNameRepos,
-			},
+	for _, v := range volumes {
+		if old, ok := existing[v.Name]; ok {
+			v = old
 		}
-		ss.Spec.VolumeClaimTemplates = append(ss.Spec.VolumeClaimTemplates, pvc)
-		volumeClaimIndex = len(ss.Spec.VolumeClaimTemplates) - 1
+		newVolumes = append(newVolumes, v)
 	}
-	ss.Spec.VolumeClaimTemplates[volumeClaimIndex] = *s.Spec.VolumeClaimTemplate.DeepCopy()
-	ss.Spec.VolumeClaimTemplates[volumeClaimIndex].Name = VolumeNameRepos
+	if len(newVolumes) == 0 {
+		newVolumes = nil
+	}
+	podSpec.Volumes = newVolumes
 
-	var volume *corev1.Volume
-	for i := range ss.Spec.Template.Spec.Volumes {
-		v := &ss.Spec.Template.Spec.Volumes[i]
-		if v.Name == VolumeNameConfig {
-			volume = v
-			break
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != ContainerNameSVN {
+			continue
 		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+len(volumes))
+		for _, m := range c.VolumeMounts {
+			if !strings.HasPrefix(m.Name, VolumeNamePrefixSource) {
+				mounts = append(mounts, m)
+			}
+		}
+		for _, v := range volumes {
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      v.Name,
+				MountPath: path.Join(VolumePathSources, v.Name),
+				ReadOnly:  true,
+			})
+		}
+		if len(mounts) == 0 {
+			mounts = nil
+		}
+		c.VolumeMounts = mounts
 	}
-	if volume == nil {
-		v := &corev1.Volume{Name: VolumeNameConfig}
-		ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, *v)
-		volume = &ss.Spec.Template.Spec.Volumes[len(ss.Spec.Template.Spec.Volumes)-1]
-	}
-	volume.VolumeSource = corev1.VolumeSource{
-		ConfigMap: &corev1.ConfigMapVolumeSource{
-			LocalObjectReference: corev1.LocalObjectReference{
-				Name: s.Name,
-			},
-		},
+}
+
+// updaterURLFor returns the base URL of server-updater running in the primary of the SVN server.
+func updaterURLFor(s *svnv1alpha1.SVNServer) string {
+	return podUpdaterURLOf(s, 0)
+}
+
+// podUpdaterURLOf returns the base URL of server-updater running in the given Pod of the SVN server.
+func podUpdaterURLOf(s *svnv1alpha1.SVNServer, ordinal int32) string {
+	return fmt.Sprintf("http://%s:%d", podHostOf(s, ordinal), UpdaterPort)
+}
+
+// podHostOf returns the host name of the given Pod of the SVN server.
+func podHostOf(s *svnv1alpha1.SVNServer, ordinal int32) string {
+	// Pods in StatefulSets can be resolved through their headless Services.
+	return fmt.Sprintf("%s-%d.%s.%s.svc", s.Name, ordinal, s.Name, s.Namespace)
+}
+
+// replicasOf returns the number of Pods of the SVN server, including the primary.
+func replicasOf(s *svnv1alpha1.SVNServer) int32 {
+	if s.Spec.Replicas == nil {
+		return 1
 	}
+	return *s.Spec.Replicas
+}
 
-	var container *corev1.Container
-	for i := range ss.Spec.Template.Spec.Containers {
-		c := &ss.Spec.Template.Spec.Containers[i]
-		if c.Name == ContainerNameSVN {
-			container = c
-			break
+// replicaStatusesOf fetches the status of each replica from its server-updater and compares it with the primary.
+// primary is the status of the primary, which is nil if it is not available.
+// token is the token to read the status of server-updater.
+func (r *SVNServerReconciler) replicaStatusesOf(ctx context.Context, s *svnv1alpha1.SVNServer, primary *serverupdater.Status, token string) []svnv1alpha1.ReplicaStatus {
+	var statuses []svnv1alpha1.ReplicaStatus
+	for i := int32(1); i < replicasOf(s); i++ {
+		name := fmt.Sprintf("%s-%d", s.Name, i)
+		replica, err := r.UpdaterClient.Status(ctx, podUpdaterURLOf(s, i), token)
+		if err != nil {
+			r.Log.Info("server-updater of the replica is not available", "svnserver", types.NamespacedName{Name: s.Name, Namespace: s.Namespace}, "pod", name, "error", err.Error())
+			replica = nil
 		}
+		statuses = append(statuses, replicaStatusOf(name, primary, replica))
 	}
-	if container == nil {
-		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers, r.svnContainerFor(s))
-		container = &ss.Spec.Template.Spec.Containers[len(ss.Spec.Template.Spec.Containers)-1]
+	return statuses
+}
+
+// replicaStatusOf computes how far the replica is behind the primary.
+// primary and replica are nil if they are not available.
+func replicaStatusOf(name string, primary, replica *serverupdater.Status) svnv1alpha1.ReplicaStatus {
+	status := svnv1alpha1.ReplicaStatus{Name: name}
+	if primary == nil {
+		status.Message = "the primary is not available"
+		return status
 	}
-	if s.Spec.PodTemplate.Image != "" {
-		container.Image = s.Spec.PodTemplate.Image
-	} else {
-		container.Image = r.DefaultSVNServerImage
+	if replica == nil {
+		status.Message = "the replica is not available"
+		return status
 	}
-
-	if len(s.Spec.PodTemplate.NodeSelector) > 0 {
-		ss.Spec.Template.Spec.NodeSelector = map[string]string{}
-		for k, v := range s.Spec.PodTemplate.NodeSelector {
-			ss.Spec.Template.Spec.NodeSelector[k] = v
+	var lag int64
+	for _, p := range primary.Repositories {
+		behind := p.Revision
+		if r := replica.RepositoryStatusOf(p.Name); r != nil {
+			behind = p.Revision - r.Revision
+		}
+		if behind > 0 {
+			status.LaggingRepositories++
+		}
+		if behind > lag {
+			lag = behind
+		}
+		m := replica.MirrorStatusOf(p.Name)
+		if m == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, m.LastSyncTime)
+		if err != nil {
+			continue
+		}
+		if status.LastSyncTime == nil || t.Before(status.LastSyncTime.Time) {
+			status.LastSyncTime = &metav1.Time{Time: t}
 		}
 	}
-	if s.Spec.PodTemplate.ServiceAccountName != "" {
-		ss.Spec.Template.Spec.ServiceAccountName = s.Spec.PodTemplate.ServiceAccountName
+	status.RevisionLag = &lag
+	return status
+}
+
+// replicaStatusesEqual compares the times in ReplicaStatuses by their instants,
+// since the ones read from the API server are in the local time zone.
+func replicaStatusesEqual(a, b []svnv1alpha1.ReplicaStatus) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	if len(s.Spec.PodTemplate.ImagePullSecrets) > 0 {
+	for i := range a {
+		x, y := &a[i], &b[i]
+		if (x.LastSyncTime == nil) != (y.LastSyncTime == nil) ||
+			(x.LastSyncTime != nil && !x.LastSyncTime.Equal(y.LastSyncTime)) {
+			return false
+		}
+		if (x.RevisionLag == nil) != (y.RevisionLag == nil) ||
+			(x.RevisionLag != nil && *x.RevisionLag != *y.RevisionLag) {
+			return false
+		}
+		if x.Name != y.Name || x.LaggingRepositories != y.LaggingRepositories || x.Message != y.Message {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedNamespacesFor returns a set of namespaces whose objects can belong to the given SVNServer.
+func (r *SVNServerReconciler) allowedNamespacesFor(ctx context.Context, s *svnv1alpha1.SVNServer) (map[string]bool, error) {
+	allowed := map[string]bool{s.Namespace: true}
+	if s.Spec.NamespaceSelector == nil {
+		return allowed, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(s.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	for i := range namespaces.Items {
+		allowed[namespaces.Items[i].Name] = true
+	}
+	return allowed, nil
+}
+
+// excludeDisallowedNamespaces removes objects that reside in namespaces not allowed to attach to the SVNServer.
+func excludeDisallowedNamespaces(log logr.Logger, allowed map[string]bool, repos *svnv1alpha1.SVNRepositoryList, groups *svnv1alpha1.SVNGroupList, users *svnv1alpha1.SVNUserList) {
+	filteredRepos := repos.Items[:0]
+	for _, obj := range repos.Items {
+		if allowed[obj.Namespace] {
+			filteredRepos = append(filteredRepos, obj)
+		} else {
+			log.Info("SVNRepository in a disallowed namespace; ignoring.", "SVNRepository.Namespace", obj.Namespace, "SVNRepository.Name", obj.Name)
+		}
+	}
+	repos.Items = filteredRepos
+
+	filteredGroups := groups.Items[:0]
+	for _, obj := range groups.Items {
+		if allowed[obj.Namespace] {
+			filteredGroups = append(filteredGroups, obj)
+		} else {
+			log.Info("SVNGroup in a disallowed namespace; ignoring.", "SVNGroup.Namespace", obj.Namespace, "SVNGroup.Name", obj.Name)
+		}
+	}
+	groups.Items = filteredGroups
+
+	filteredUsers := users.Items[:0]
+	for _, obj := range users.Items {
+		if allowed[obj.Namespace] {
+			filteredUsers = append(filteredUsers, obj)
+		} else {
+			log.Info("SVNUser in a disallowed namespace; ignoring.", "SVNUser.Namespace", obj.Namespace, "SVNUser.Name", obj.Name)
+		}
+	}
+	users.Items = filteredUsers
+}
+
+// configMapsFor returns ConfigMaps that the given SVNRepositories refer to as their seed files or hooks.
+// Missing ConfigMaps are not contained in the result.
+func (r *SVNServerReconciler) configMapsFor(ctx context.Context, repos *svnv1alpha1.SVNRepositoryList) (map[types.NamespacedName]*corev1.ConfigMap, error) {
+	configMaps := map[types.NamespacedName]*corev1.ConfigMap{}
+	for i := range repos.Items {
+		repo := &repos.Items[i]
+		for _, name := range configMapNamesOf(repo) {
+			key := types.NamespacedName{Namespace: repo.Namespace, Name: name}
+			if _, ok := configMaps[key]; ok {
+				continue
+			}
+			cm := &corev1.ConfigMap{}
+			if err := r.Get(ctx, key, cm); err != nil {
+				if errors.IsNotFound(err) {
+					// The error is reported by RepositoryErrors.
+					continue
+				}
+				return nil, err
+			}
+			configMaps[key] = cm
+		}
+	}
+	return configMaps, nil
+}
+
+// configMapNamesOf returns the names of ConfigMaps that the SVNRepository refers to as its seed files or hooks.
+func configMapNamesOf(repo *svnv1alpha1.SVNRepository) []string {
+	var names []string
+	if repo.Spec.Seed != nil && repo.Status.Repository == nil {
+		names = append(names, repo.Spec.Seed.ConfigMapName)
+	}
+	if repo.Spec.Hooks != nil {
+		names = append(names, repo.Spec.Hooks.ConfigMapName)
+	}
+	return names
+}
+
+// passwordsFor returns the hashes of passwords in Secrets that the given SVNUsers refer to.
+// Missing Secrets and keys are not contained in the result.
+func (r *SVNServerReconciler) passwordsFor(ctx context.Context, users *svnv1alpha1.SVNUserList) (map[passwordKey]string, error) {
+	passwords := map[passwordKey]string{}
+	for i := range users.Items {
+		u := &users.Items[i]
+		key, ok := passwordKeyOf(u)
+		if !ok {
+			continue
+		}
+		if _, ok := passwords[key]; ok {
+			continue
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, key.NamespacedName, secret); err != nil {
+			if errors.IsNotFound(err) {
+				// The error is reported by UserErrors.
+				continue
+			}
+			return nil, err
+		}
+		password, ok := secret.Data[key.Key]
+		if !ok {
+			continue
+		}
+		hash, err := r.passwordHashOf(key, secret.ResourceVersion, password)
+		if err != nil {
+			return nil, err
+		}
+		passwords[key] = hash
+	}
+	return passwords, nil
+}
+
+// passwordHashOf returns the hash of the password, which is cached until the Secret changes.
+func (r *SVNServerReconciler) passwordHashOf(key passwordKey, resourceVersion string, password []byte) (string, error) {
+	r.passwordHashesMu.Lock()
+	defer r.passwordHashesMu.Unlock()
+	if cached, ok := r.passwordHashes[key]; ok && cached.ResourceVersion == resourceVersion {
+		return cached.Hash, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	if r.passwordHashes == nil {
+		r.passwordHashes = map[passwordKey]passwordHash{}
+	}
+	r.passwordHashes[key] = passwordHash{ResourceVersion: resourceVersion, Hash: string(hash)}
+	return string(hash), nil
+}
+
+// passwordKeyOf returns the key of the Secret that holds the password of the SVNUser.
+// It returns false if the SVNUser does not refer to a Secret.
+func passwordKeyOf(u *svnv1alpha1.SVNUser) (passwordKey, bool) {
+	ref := u.Spec.PasswordSecretRef
+	if ref == nil {
+		return passwordKey{}, false
+	}
+	return passwordKey{
+		NamespacedName: types.NamespacedName{Namespace: u.Namespace, Name: ref.Name},
+		Key:            ref.KeyOrDefault(),
+	}, true
+}
+
+// updateRepositoryStatuses reports whether each SVNRepository is successfully written to the configuration files,
+// as well as the progress of loading dump files reported by server-updater.
+// status is the status of server-updater, which is nil if it is not available.
+func (r *SVNServerReconciler) updateRepositoryStatuses(ctx context.Context, log logr.Logger, f *GeneratorFactory, status *serverupdater.Status) error {
+	repoErrors := f.RepositoryErrors()
+	for i := range f.repos.Items {
+		repo := &f.repos.Items[i]
+		if !repo.DeletionTimestamp.IsZero() {
+			continue
+		}
+		load, mirror := repo.Status.Load, repo.Status.Mirror
+		if status != nil && repo.Spec.Source != nil {
+			if s := status.LoadStatusOf(f.nameOf(repo)); s != nil && repo.Spec.Source.Dump != nil {
+				load = &svnv1alpha1.LoadStatus{
+					Phase:              s.Phase,
+					LastLoadedRevision: s.LastLoadedRevision,
+					Message:            s.Message,
+				}
+			}
+			if s := status.MirrorStatusOf(f.nameOf(repo)); s != nil && repo.Spec.Source.Mirror != nil {
+				mirror = mirrorStatusOf(s)
+			}
+		}
+		usage, info := repo.Status.Usage, repo.Status.Repository
+		if status != nil {
+			usage = f.repositoryUsageOf(repo, status)
+			info = repositoryInfoOf(status.RepositoryStatusOf(f.nameOf(repo)))
+		}
+		cond := svnv1alpha1.Condition{
+			Type:   svnv1alpha1.ConditionTypeSynced,
+			Reason: "successfully synced",
+		}
+		if err, ok := repoErrors[f.nameOf(repo)]; ok {
+			cond.Type = svnv1alpha1.ConditionTypeFailed
+			cond.Reason = err.Error()
+		} else if load != nil && load.Phase == serverupdater.LoadPhaseFailed {
+			cond.Type = svnv1alpha1.ConditionTypeFailed
+			cond.Reason = fmt.Sprintf("failed to load dump file: %s", load.Message)
+		} else if mirror != nil && mirror.Message != "" {
+			cond.Type = svnv1alpha1.ConditionTypeFailed
+			cond.Reason = fmt.Sprintf("failed to synchronize mirror: %s", mirror.Message)
+		}
+		condChanged := true
+		if l := len(repo.Status.Conditions); l > 0 {
+			last := repo.Status.Conditions[l-1]
+			condChanged = last.Type != cond.Type || last.Reason != cond.Reason
+		}
+		if !condChanged && reflect.DeepEqual(load, repo.Status.Load) && mirrorStatusEqual(mirror, repo.Status.Mirror) &&
+			usageEqual(usage, repo.Status.Usage) && repositoryInfoEqual(info, repo.Status.Repository) {
+			continue
+		}
+		repo.Status.Load = load
+		repo.Status.Mirror = mirror
+		repo.Status.Usage = usage
+		repo.Status.Repository = info
+		if condChanged {
+			cond.TransitionTime = time.Now().Format(time.RFC3339)
+			repo.Status.Conditions = addCondition(repo.Status.Conditions, cond)
+		}
+		if err := r.Status().Update(ctx, repo); err != nil {
+			log.Error(err, "Failed to update SVNRepository status", "SVNRepository.Namespace", repo.Namespace, "SVNRepository.Name", repo.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// repositoryInfoOf converts the state of a repository reported by server-updater,
+// which is nil if the repository does not exist on the server.
+func repositoryInfoOf(s *serverupdater.RepositoryStatus) *svnv1alpha1.RepositoryInfo {
+	if s == nil {
+		return nil
+	}
+	info := &svnv1alpha1.RepositoryInfo{
+		Revision:          s.Revision,
+		UUID:              s.UUID,
+		Size:              resource.NewQuantity(s.Size, resource.BinarySI),
+		FSType:            s.FSType,
+		FSFormat:          int32(s.FSFormat),
+		LastChangedAuthor: s.LastChangedAuthor,
+	}
+	if t, err := time.Parse(time.RFC3339, s.LastChangedTime); err == nil {
+		lastChanged := metav1.NewTime(t)
+		info.LastChangedTime = &lastChanged
+	}
+	return info
+}
+
+// mirrorStatusOf converts the progress of synchronizing a mirror reported by server-updater.
+func mirrorStatusOf(s *serverupdater.MirrorStatus) *svnv1alpha1.MirrorStatus {
+	mirror := &svnv1alpha1.MirrorStatus{
+		LastSyncedRevision: s.LastSyncedRevision,
+		Message:            s.Message,
+	}
+	if t, err := time.Parse(time.RFC3339, s.LastSyncTime); err == nil {
+		mirror.LastSyncTime = &metav1.Time{Time: t}
+	}
+	return mirror
+}
+
+// mirrorStatusEqual compares the times in MirrorStatus by their instants,
+// since the ones read from the API server are in the local time zone.
+func mirrorStatusEqual(a, b *svnv1alpha1.MirrorStatus) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if (a.LastSyncTime == nil) != (b.LastSyncTime == nil) ||
+		(a.LastSyncTime != nil && !a.LastSyncTime.Equal(b.LastSyncTime)) {
+		return false
+	}
+	return a.LastSyncedRevision == b.LastSyncedRevision && a.Message == b.Message
+}
+
+// repositoryInfoEqual returns true if a and b are the same state.
+func repositoryInfoEqual(a, b *svnv1alpha1.RepositoryInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if (a.Size == nil) != (b.Size == nil) || (a.Size != nil && a.Size.Cmp(*b.Size) != 0) {
+		return false
+	}
+	if (a.LastChangedTime == nil) != (b.LastChangedTime == nil) ||
+		(a.LastChangedTime != nil && !a.LastChangedTime.Equal(b.LastChangedTime)) {
+		return false
+	}
+	a, b = a.DeepCopy(), b.DeepCopy()
+	a.Size, b.Size = nil, nil
+	a.LastChangedTime, b.LastChangedTime = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// serverUsageOf returns the total size of the repositories on the server reported by server-updater.
+// It returns the current status if server-updater is not available.
+func serverUsageOf(server *svnv1alpha1.SVNServer, status *serverupdater.Status) *svnv1alpha1.Usage {
+	if server.Spec.Quota == nil {
+		return nil
+	}
+	if status == nil {
+		return server.Status.Usage
+	}
+	return usageOf(status.TotalSize, &server.Spec.Quota.MaxSize)
+}
+
+// usageEqual returns true if a and b are the same usage.
+// Quantities are compared by their values since they may have different representations.
+func usageEqual(a, b *svnv1alpha1.Usage) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if (a.MaxSize == nil) != (b.MaxSize == nil) {
+		return false
+	}
+	if a.MaxSize != nil && a.MaxSize.Cmp(*b.MaxSize) != 0 {
+		return false
+	}
+	return a.Size.Cmp(b.Size) == 0 && a.Exceeded == b.Exceeded
+}
+
+func usageOf(size int64, maxSize *resource.Quantity) *svnv1alpha1.Usage {
+	usage := &svnv1alpha1.Usage{
+		Size: *resource.NewQuantity(size, resource.BinarySI),
+	}
+	if maxSize != nil {
+		q := maxSize.DeepCopy()
+		usage.MaxSize = &q
+		usage.Exceeded = size >= maxSize.Value()
+	}
+	return usage
+}
+
+// updateGroupStatuses reports whether each SVNGroup is successfully written to the configuration files.
+func (r *SVNServerReconciler) updateGroupStatuses(ctx context.Context, log logr.Logger, f *GeneratorFactory) error {
+	groupErrors := f.GroupErrors()
+	for i := range f.groups.Items {
+		g := &f.groups.Items[i]
+		cond := svnv1alpha1.Condition{
+			Type:   svnv1alpha1.ConditionTypeSynced,
+			Reason: "successfully synced",
+		}
+		if err, ok := groupErrors[f.nameOf(g)]; ok {
+			cond.Type = svnv1alpha1.ConditionTypeFailed
+			cond.Reason = err.Error()
+		}
+		if l := len(g.Status.Conditions); l > 0 {
+			last := g.Status.Conditions[l-1]
+			if last.Type == cond.Type && last.Reason == cond.Reason {
+				continue
+			}
+		}
+		cond.TransitionTime = time.Now().Format(time.RFC3339)
+		g.Status.Conditions = addCondition(g.Status.Conditions, cond)
+		if err := r.Status().Update(ctx, g); err != nil {
+			log.Error(err, "Failed to update SVNGroup status", "SVNGroup.Namespace", g.Namespace, "SVNGroup.Name", g.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// updateUserStatuses reports whether each SVNUser is successfully written to the configuration files.
+func (r *SVNServerReconciler) updateUserStatuses(ctx context.Context, log logr.Logger, f *GeneratorFactory) error {
+	userErrors := f.UserErrors()
+	for i := range f.users.Items {
+		u := &f.users.Items[i]
+		cond := svnv1alpha1.Condition{
+			Type:   svnv1alpha1.ConditionTypeSynced,
+			Reason: "successfully synced",
+		}
+		if reason := f.inactiveReasonOf(u); reason != "" {
+			// Inactive users are left out regardless of their passwords.
+			cond.Type = svnv1alpha1.ConditionTypeInactive
+			cond.Reason = reason
+		} else if err, ok := userErrors[f.nameOf(u)]; ok {
+			cond.Type = svnv1alpha1.ConditionTypeFailed
+			cond.Reason = err.Error()
+		}
+		if l := len(u.Status.Conditions); l > 0 {
+			last := u.Status.Conditions[l-1]
+			if last.Type == cond.Type && last.Reason == cond.Reason {
+				continue
+			}
+		}
+		cond.TransitionTime = time.Now().Format(time.RFC3339)
+		u.Status.Conditions = addCondition(u.Status.Conditions, cond)
+		if err := r.Status().Update(ctx, u); err != nil {
+			log.Error(err, "Failed to update SVNUser status", "SVNUser.Namespace", u.Namespace, "SVNUser.Name", u.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// Creates a StatefulSet and is corresponding Service
+func (r *SVNServerReconciler) createStatefulSet(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
+	ss, err := r.statefulSetFor(svn)
+	if err != nil {
+		log.Error(err, "Failed to compute desired StatefulSet")
+		return err
+	}
+	log = log.WithValues("StatefulSet.Namespace", ss.Namespace, "StatefulSet.Name", ss.Name)
+	log.Info("Creating a new StatefulSet")
+	if err := r.Create(ctx, ss); err != nil {
+		log.Error(err, "Failed to create new StatefulSet")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createService(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
+	svc, err := r.serviceFor(svn)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Service")
+		return err
+	}
+	log = log.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+	log.Info("Creating a new Service")
+	if err := r.Create(ctx, svc); err != nil {
+		log.Error(err, "Failed to create new Service")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createAuthSecret(ctx context.Context, log logr.Logger, f *GeneratorFactory) error {
+	secret, err := r.authSecretFor(f)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Secret")
+		return err
+	}
+	log = log.WithValues("Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	log.Info("Creating a new Secret")
+	if err := r.Create(ctx, secret); err != nil {
+		log.Error(err, "Failed to create new Secret")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createLoadBalancerService(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
+	svc, err := r.loadBalancerServiceFor(svn)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Service")
+		return err
+	}
+	log = log.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+	log.Info("Creating a new Service")
+	if err := r.Create(ctx, svc); err != nil {
+		log.Error(err, "Failed to create new Service")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createReplicationSecret(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
+	secret, err := r.replicationSecretFor(svn)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Secret")
+		return err
+	}
+	log = log.WithValues("Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	log.Info("Creating a new Secret")
+	if err := r.Create(ctx, secret); err != nil {
+		log.Error(err, "Failed to create new Secret")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createUpdaterSecret(ctx context.Context, log logr.Logger, svn *svnv1alpha1.SVNServer) error {
+	secret, err := r.updaterSecretFor(svn)
+	if err != nil {
+		log.Error(err, "Failed to compute desired Secret")
+		return err
+	}
+	log = log.WithValues("Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	log.Info("Creating a new Secret")
+	if err := r.Create(ctx, secret); err != nil {
+		log.Error(err, "Failed to create new Secret")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) createConfigMap(ctx context.Context, log logr.Logger, f *GeneratorFactory) error {
+	cm, err := r.configMapFor(f)
+	if err != nil {
+		log.Error(err, "Failed to compute desired ConfigMap")
+		return err
+	}
+	log = log.WithValues("ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
+	log.Info("Creating a new ConfigMap")
+	if err := r.Create(ctx, cm); err != nil {
+		log.Error(err, "Failed to create new ConfigMap")
+		return err
+	}
+	return nil
+}
+
+func (r *SVNServerReconciler) statefulSetFor(s *svnv1alpha1.SVNServer) (*appsv1.StatefulSet, error) {
+	labels := r.labelsFor(s)
+	ss := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.Name,
+			Namespace: s.Namespace,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+			},
+			ServiceName: s.Name,
+		},
+	}
+	r.overrideWithPodTemplate(s, ss)
+	err := ctrl.SetControllerReference(s, ss, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (r *SVNServerReconciler) overrideWithPodTemplate(s *svnv1alpha1.SVNServer, ss *appsv1.StatefulSet) {
+	replicas := replicasOf(s)
+	ss.Spec.Replicas = &replicas
+
+	var volumeClaimIndex int = -1
+	for i := range ss.Spec.VolumeClaimTemplates {
+		pvc := &ss.Spec.VolumeClaimTemplates[i]
+		if pvc.Name == VolumeNameRepos {
+			volumeClaimIndex = i
+			break
+		}
+	}
+	if volumeClaimIndex < 0 {
+		pvc := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: VolumeNameRepos,
+			},
+		}
+		ss.Spec.VolumeClaimTemplates = append(ss.Spec.VolumeClaimTemplates, pvc)
+		volumeClaimIndex = len(ss.Spec.VolumeClaimTemplates) - 1
+	}
+	ss.Spec.VolumeClaimTemplates[volumeClaimIndex] = *s.Spec.VolumeClaimTemplate.DeepCopy()
+	ss.Spec.VolumeClaimTemplates[volumeClaimIndex].Name = VolumeNameRepos
+
+	var volume *corev1.Volume
+	for i := range ss.Spec.Template.Spec.Volumes {
+		v := &ss.Spec.Template.Spec.Volumes[i]
+		if v.Name == VolumeNameConfig {
+			volume = v
+			break
+		}
+	}
+	if volume == nil {
+		v := &corev1.Volume{Name: VolumeNameConfig}
+		ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, *v)
+		volume = &ss.Spec.Template.Spec.Volumes[len(ss.Spec.Template.Spec.Volumes)-1]
+	}
+	volume.VolumeSource = corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: s.Name,
+			},
+		},
+	}
+
+	// The existing volumes are kept as is, since the API server fills default values in them.
+	hasAuthVolume := false
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Name == VolumeNameAuth {
+			hasAuthVolume = true
+			break
+		}
+	}
+	if !hasAuthVolume {
+		ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: VolumeNameAuth,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: authSecretNameOf(s),
+				},
+			},
+		})
+	}
+
+	hasUpdaterVolume := false
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Name == VolumeNameUpdater {
+			hasUpdaterVolume = true
+			break
+		}
+	}
+	if !hasUpdaterVolume {
+		ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: VolumeNameUpdater,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: updaterSecretNameOf(s),
+				},
+			},
+		})
+	}
+
+	var container *corev1.Container
+	for i := range ss.Spec.Template.Spec.Containers {
+		c := &ss.Spec.Template.Spec.Containers[i]
+		if c.Name == ContainerNameSVN {
+			container = c
+			break
+		}
+	}
+	if container == nil {
+		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers, r.svnContainerFor(s))
+		container = &ss.Spec.Template.Spec.Containers[len(ss.Spec.Template.Spec.Containers)-1]
+	}
+	// StatefulSets created before the credentials were moved to Secrets do not mount them yet.
+	hasAuthMount := false
+	for _, m := range container.VolumeMounts {
+		if m.Name == VolumeNameAuth {
+			hasAuthMount = true
+			break
+		}
+	}
+	if !hasAuthMount {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      VolumeNameAuth,
+			MountPath: VolumePathAuth,
+			ReadOnly:  true,
+		})
+	}
+	// Neither do StatefulSets created before the status of server-updater was protected by the token.
+	hasUpdaterMount := false
+	for _, m := range container.VolumeMounts {
+		if m.Name == VolumeNameUpdater {
+			hasUpdaterMount = true
+			break
+		}
+	}
+	if !hasUpdaterMount {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      VolumeNameUpdater,
+			MountPath: VolumePathUpdater,
+			ReadOnly:  true,
+		})
+	}
+	if s.Spec.PodTemplate.Image != "" {
+		container.Image = s.Spec.PodTemplate.Image
+	} else {
+		container.Image = r.DefaultSVNServerImage
+	}
+
+	if len(s.Spec.PodTemplate.NodeSelector) > 0 {
+		ss.Spec.Template.Spec.NodeSelector = map[string]string{}
+		for k, v := range s.Spec.PodTemplate.NodeSelector {
+			ss.Spec.Template.Spec.NodeSelector[k] = v
+		}
+	}
+	if s.Spec.PodTemplate.ServiceAccountName != "" {
+		ss.Spec.Template.Spec.ServiceAccountName = s.Spec.PodTemplate.ServiceAccountName
+	}
+	if len(s.Spec.PodTemplate.ImagePullSecrets) > 0 {
 		ss.Spec.Template.Spec.ImagePullSecrets = make([]corev1.LocalObjectReference, len(s.Spec.PodTemplate.ImagePullSecrets))
 		copy(ss.Spec.Template.Spec.ImagePullSecrets, s.Spec.PodTemplate.ImagePullSecrets)
 	}
@@ -378,16 +1407,101 @@ func (r *SVNServerReconciler) overrideWithPodTemplate(s *svnv1alpha1.SVNServer,
 		ss.Spec.Template.Spec.Tolerations = make([]corev1.Toleration, len(s.Spec.PodTemplate.Tolerations))
 		copy(ss.Spec.Template.Spec.Tolerations, s.Spec.PodTemplate.Tolerations)
 	}
+
+	setReplication(s, ss)
+}
+
+// setReplication makes Pods other than the primary run as read-only replicas if the SVNServer has replicas,
+// and removes the settings otherwise.
+// The existing volume is kept as is, since the API server may fill default values in it.
+func setReplication(s *svnv1alpha1.SVNServer, ss *appsv1.StatefulSet) {
+	replicated := replicasOf(s) > 1
+	podSpec := &ss.Spec.Template.Spec
+	volumes := make([]corev1.Volume, 0, len(podSpec.Volumes)+1)
+	volume := corev1.Volume{
+		Name: VolumeNameReplication,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: replicationSecretNameOf(s),
+			},
+		},
+	}
+	for _, v := range podSpec.Volumes {
+		if v.Name == VolumeNameReplication {
+			volume = v
+			continue
+		}
+		volumes = append(volumes, v)
+	}
+	if replicated {
+		volumes = append(volumes, volume)
+	}
+	if len(volumes) == 0 {
+		volumes = nil
+	}
+	podSpec.Volumes = volumes
+
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != ContainerNameSVN {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(c.VolumeMounts)+1)
+		for _, m := range c.VolumeMounts {
+			if m.Name != VolumeNameReplication {
+				mounts = append(mounts, m)
+			}
+		}
+		env := make([]corev1.EnvVar, 0, len(c.Env)+1)
+		for _, e := range c.Env {
+			if e.Name != EnvPrimaryHost {
+				env = append(env, e)
+			}
+		}
+		if replicated {
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      VolumeNameReplication,
+				MountPath: VolumePathReplication,
+				ReadOnly:  true,
+			})
+			env = append(env, corev1.EnvVar{Name: EnvPrimaryHost, Value: podHostOf(s, 0)})
+		}
+		if len(mounts) == 0 {
+			mounts = nil
+		}
+		if len(env) == 0 {
+			env = nil
+		}
+		c.VolumeMounts = mounts
+		c.Env = env
+
+		// Replicas do not receive requests until they have caught up with the primary.
+		// Only the handler is replaced, since the API server fills default values in the rest.
+		readiness := &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80), Scheme: corev1.URISchemeHTTP}
+		if replicated {
+			readiness = &corev1.HTTPGetAction{Path: serverupdater.ReadyPath, Port: intstr.FromInt(UpdaterPort), Scheme: corev1.URISchemeHTTP}
+		}
+		if c.ReadinessProbe == nil {
+			c.ReadinessProbe = &corev1.Probe{}
+		}
+		c.ReadinessProbe.Handler = corev1.Handler{HTTPGet: readiness}
+	}
 }
 
 func (r *SVNServerReconciler) svnContainerFor(s *svnv1alpha1.SVNServer) corev1.Container {
 	return corev1.Container{
 		Name:  ContainerNameSVN,
 		Image: r.DefaultSVNServerImage,
-		Ports: []corev1.ContainerPort{{
-			ContainerPort: 80,
-			Name:          "http",
-		}},
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: 80,
+				Name:          "http",
+			},
+			{
+				ContainerPort: UpdaterPort,
+				Name:          "updater",
+			},
+		},
 		ReadinessProbe: &corev1.Probe{
 			Handler: corev1.Handler{
 				HTTPGet: &corev1.HTTPGetAction{
@@ -413,144 +1527,1156 @@ func (r *SVNServerReconciler) svnContainerFor(s *svnv1alpha1.SVNServer) corev1.C
 				Name:      VolumeNameConfig,
 				MountPath: VolumePathConfig,
 			},
+			{
+				Name:      VolumeNameAuth,
+				MountPath: VolumePathAuth,
+				ReadOnly:  true,
+			},
+			{
+				Name:      VolumeNameUpdater,
+				MountPath: VolumePathUpdater,
+				ReadOnly:  true,
+			},
+		},
+	}
+}
+
+func (r *SVNServerReconciler) serviceFor(s *svnv1alpha1.SVNServer) (*corev1.Service, error) {
+	labels := r.labelsFor(s)
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.Name,
+			Namespace: s.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name: "http",
+				Port: 80,
+			}},
+			Selector:  labels,
+			ClusterIP: "None",
+		},
+	}
+	err := ctrl.SetControllerReference(s, svc, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// loadBalancerServiceNameOf returns the name of the Service that balances requests between the primary and replicas.
+func loadBalancerServiceNameOf(s *svnv1alpha1.SVNServer) string {
+	return s.Name + "-lb"
+}
+
+func (r *SVNServerReconciler) loadBalancerServiceFor(s *svnv1alpha1.SVNServer) (*corev1.Service, error) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      loadBalancerServiceNameOf(s),
+			Namespace: s.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name: "http",
+				Port: 80,
+			}},
+			Selector: r.labelsFor(s),
+			// Replicas may be behind each other, so a client keeps reaching the same Pod
+			// not to be told that a revision it has just seen does not exist.
+			SessionAffinity: corev1.ServiceAffinityClientIP,
+		},
+	}
+	err := ctrl.SetControllerReference(s, svc, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// replicationSecretNameOf returns the name of the Secret that has the credentials of replicas.
+func replicationSecretNameOf(s *svnv1alpha1.SVNServer) string {
+	return s.Name + "-replication"
+}
+
+func (r *SVNServerReconciler) replicationSecretFor(s *svnv1alpha1.SVNServer) (*corev1.Secret, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	password := hex.EncodeToString(buf)
+	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      replicationSecretNameOf(s),
+			Namespace: s.Namespace,
+		},
+		Data: map[string][]byte{
+			svnconfig.MirrorCredentialsUsername: []byte(svnconfig.ReplicationUser),
+			svnconfig.MirrorCredentialsPassword: []byte(password),
+			SecretKeyEncryptedPassword:          encryptedPassword,
+		},
+	}
+	err = ctrl.SetControllerReference(s, secret, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// updaterSecretNameOf returns the name of the Secret that has the token to read the status of server-updater.
+func updaterSecretNameOf(s *svnv1alpha1.SVNServer) string {
+	return s.Name + "-updater"
+}
+
+func (r *SVNServerReconciler) updaterSecretFor(s *svnv1alpha1.SVNServer) (*corev1.Secret, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      updaterSecretNameOf(s),
+			Namespace: s.Namespace,
+		},
+		Data: map[string][]byte{
+			SecretKeyUpdaterToken: []byte(hex.EncodeToString(buf)),
+		},
+	}
+	err := ctrl.SetControllerReference(s, secret, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (r *SVNServerReconciler) configMapFor(f *GeneratorFactory) (*corev1.ConfigMap, error) {
+	gen := f.BuildGenerator()
+	reposConfig, err := gen.ReposConfig()
+	if err != nil {
+		return nil, err
+	}
+	apacheConfig, err := gen.ApacheConfig()
+	if err != nil {
+		return nil, err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      f.server.Name,
+			Namespace: f.server.Namespace,
+		},
+		Data: map[string]string{
+			ConfigMapKeyRepos:        reposConfig,
+			ConfigMapKeyApacheConfig: apacheConfig,
+		},
+	}
+	if f.legacyAuth {
+		authUserFile, err := gen.AuthUserFile()
+		if err != nil {
+			return nil, err
+		}
+		authzSVNAccessFile, err := gen.AuthzSVNAccessFile()
+		if err != nil {
+			return nil, err
+		}
+		cm.Data[SecretKeyAuthUserFile] = authUserFile
+		cm.Data[SecretKeyAuthzSVNAccessFile] = authzSVNAccessFile
+	}
+	err = ctrl.SetControllerReference(f.server, cm, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// authSecretMounted returns true if every Pod of the StatefulSet mounts the Secret that contains the credentials.
+func authSecretMounted(ss *appsv1.StatefulSet) bool {
+	if ss.Status.ObservedGeneration < ss.Generation || ss.Status.CurrentRevision != ss.Status.UpdateRevision {
+		// Some Pods may still run with the previous template.
+		return false
+	}
+	for _, c := range ss.Spec.Template.Spec.Containers {
+		if c.Name != ContainerNameSVN {
+			continue
+		}
+		for _, m := range c.VolumeMounts {
+			if m.Name == VolumeNameAuth {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authSecretNameOf returns the name of the Secret that contains the users and their permissions.
+func authSecretNameOf(s *svnv1alpha1.SVNServer) string {
+	return s.Name + "-auth"
+}
+
+// authSecretFor returns the Secret that contains the hashes of passwords of the users,
+// as well as their permissions, which are not supposed to be visible to everyone who can read ConfigMaps.
+func (r *SVNServerReconciler) authSecretFor(f *GeneratorFactory) (*corev1.Secret, error) {
+	gen := f.BuildGenerator()
+	authUserFile, err := gen.AuthUserFile()
+	if err != nil {
+		return nil, err
+	}
+	authzSVNAccessFile, err := gen.AuthzSVNAccessFile()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      authSecretNameOf(f.server),
+			Namespace: f.server.Namespace,
+		},
+		Data: map[string][]byte{
+			SecretKeyAuthUserFile:       []byte(authUserFile),
+			SecretKeyAuthzSVNAccessFile: []byte(authzSVNAccessFile),
+		},
+	}
+	err = ctrl.SetControllerReference(f.server, secret, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (r *SVNServerReconciler) labelsFor(s *svnv1alpha1.SVNServer) map[string]string {
+	return map[string]string{
+		LabelAppKey:          LabelAppValue,
+		LabelInstanceNameKey: s.Name,
+	}
+}
+
+func (f *GeneratorFactory) BuildGenerator() *svnconfig.Generator {
+	repos := f.BuildRepositories()
+	groups := f.BuildGroups()
+	users := f.BuildUsers()
+	deletions := f.BuildDeletions()
+	var maxTotalSize int64
+	if f.server.Spec.Quota != nil {
+		maxTotalSize = f.server.Spec.Quota.MaxSize.Value()
+	}
+	authDir := VolumePathAuth
+	if f.legacyAuth {
+		authDir = VolumePathConfig
+	}
+	return &svnconfig.Generator{
+		Repositories: repos,
+		Groups:       groups,
+		Users:        users,
+		Deletions:    deletions,
+		MaxTotalSize: maxTotalSize,
+		Replication:  f.BuildReplication(),
+		Paths: svnconfig.Paths{
+			ReposDir:           filepath.Join(VolumePathRepos, "repos"),
+			AuthUserFile:       filepath.Join(authDir, SecretKeyAuthUserFile),
+			AuthzSVNAccessFile: filepath.Join(authDir, SecretKeyAuthzSVNAccessFile),
+		},
+	}
+}
+
+// BuildReplication returns the user that replicas read repositories on the primary as,
+// along with the server-updaters of the replicas, or nil if the server has no replicas.
+func (f *GeneratorFactory) BuildReplication() *svnconfig.Replication {
+	if f.replication == nil {
+		return nil
+	}
+	var urls []string
+	for i := int32(1); i < replicasOf(f.server); i++ {
+		urls = append(urls, podUpdaterURLOf(f.server, i))
+	}
+	return &svnconfig.Replication{
+		User:              string(f.replication.Data[svnconfig.MirrorCredentialsUsername]),
+		EncryptedPassword: string(f.replication.Data[SecretKeyEncryptedPassword]),
+		UpdaterURLs:       urls,
+	}
+}
+
+func (f *GeneratorFactory) BuildRepositories() []svnconfig.Repository {
+	repos := make([]svnconfig.Repository, 0, len(f.repos.Items))
+	for i := range f.repos.Items {
+		r := &f.repos.Items[i]
+		if !r.DeletionTimestamp.IsZero() {
+			// Repositories being deleted are no longer served.
+			continue
+		}
+		perms := f.buildPermissionsOf(r)
+		initial, initialErr := f.initialContentOf(r)
+		source, sourceErr := f.sourceOf(r)
+		hooks, hooksErr := f.hooksOf(r)
+		policy, policyErr := commitPolicyOf(r)
+		create, createErr := createOptionsOf(r)
+		repos = append(repos, svnconfig.Repository{
+			Name:                f.nameOf(r),
+			Permissions:         perms,
+			AnonymousAccess:     anonymousAccessOf(r),
+			AuthenticatedAccess: f.defaultPermissionsOf(r).Authenticated,
+			Initial:             initial,
+			Source:              source,
+			Hooks:               hooks,
+			CommitPolicy:        policy,
+			MaxSize:             maxSizeOf(r),
+			ReadOnly:            f.readOnlyOf(r),
+			Create:              create,
+			// The errors are reported by RepositoryErrors.
+			// Repositories with broken hooks are left untouched rather than losing their hooks,
+			// while hooks that are not allowed are removed since they must not run at all.
+			Pending: initialErr != nil || sourceErr != nil || (hooksErr != nil && hooksErr != errHooksNotAllowed) || policyErr != nil || createErr != nil,
+		})
+	}
+	return repos
+}
+
+// initialContentOf returns the content that is committed as revision 1 of the given repository.
+// It returns nil if there is nothing to commit, including when the repository already exists,
+// so that the seed files neither stay in the configuration files nor need their ConfigMap forever.
+func (f *GeneratorFactory) initialContentOf(r *svnv1alpha1.SVNRepository) (*svnconfig.InitialContent, error) {
+	if r.Status.Repository != nil {
+		return nil, nil
+	}
+	var dirs []string
+	if layout := r.Spec.InitialLayout; layout != nil {
+		if layout.Preset == svnv1alpha1.LayoutPresetStandard {
+			dirs = append(dirs, "trunk", "branches", "tags")
+		}
+		for _, dir := range layout.Directories {
+			if !svnconfig.IsValidRelativePath(dir) {
+				return nil, fmt.Errorf("invalid directory in initialLayout: %q", dir)
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	files, err := f.seedFilesOf(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 && len(files) == 0 {
+		return nil, nil
+	}
+	return &svnconfig.InitialContent{
+		Directories: dirs,
+		Files:       files,
+	}, nil
+}
+
+func (f *GeneratorFactory) seedFilesOf(r *svnv1alpha1.SVNRepository) ([]svnconfig.File, error) {
+	seed := r.Spec.Seed
+	if seed == nil {
+		return nil, nil
+	}
+	cm, ok := f.configMaps[types.NamespacedName{Namespace: r.Namespace, Name: seed.ConfigMapName}]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %q for seed files not found", seed.ConfigMapName)
+	}
+	items := seed.Items
+	if len(items) == 0 {
+		for key := range cm.Data {
+			items = append(items, svnv1alpha1.SeedItem{Key: key})
+		}
+		for key := range cm.BinaryData {
+			items = append(items, svnv1alpha1.SeedItem{Key: key})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	}
+	files := make([]svnconfig.File, 0, len(items))
+	for _, item := range items {
+		path := item.Path
+		if path == "" {
+			path = item.Key
+		}
+		if !svnconfig.IsValidRelativePath(path) {
+			return nil, fmt.Errorf("invalid path in seed: %q", path)
+		}
+		var content []byte
+		if data, ok := cm.Data[item.Key]; ok {
+			content = []byte(data)
+		} else if data, ok := cm.BinaryData[item.Key]; ok {
+			content = data
+		} else {
+			return nil, fmt.Errorf("key %q not found in ConfigMap %q", item.Key, seed.ConfigMapName)
+		}
+		files = append(files, svnconfig.File{Path: path, Content: content})
+	}
+	return files, nil
+}
+
+// errHooksNotAllowed is returned by hooksOf for SVNRepositories in other namespaces than the SVNServer.
+// Hooks run inside the SVN server, which has every repository and credential on it,
+// so only the namespace of the SVNServer is trusted to install them.
+var errHooksNotAllowed = fmt.Errorf("hooks are only supported for SVNRepositories in the same namespace as the SVNServer")
+
+// hooksOf returns the hook scripts of the given repository.
+func (f *GeneratorFactory) hooksOf(r *svnv1alpha1.SVNRepository) ([]svnconfig.Hook, error) {
+	spec := r.Spec.Hooks
+	if spec == nil {
+		return nil, nil
+	}
+	if r.Namespace != f.server.Namespace {
+		return nil, errHooksNotAllowed
+	}
+	cm, ok := f.configMaps[types.NamespacedName{Namespace: r.Namespace, Name: spec.ConfigMapName}]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %q for hooks not found", spec.ConfigMapName)
+	}
+	items := spec.Items
+	if len(items) == 0 {
+		for key := range cm.Data {
+			items = append(items, svnv1alpha1.HookItem{Name: key})
+		}
+		for key := range cm.BinaryData {
+			items = append(items, svnv1alpha1.HookItem{Name: key})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	}
+	hooks := make([]svnconfig.Hook, 0, len(items))
+	seen := map[string]bool{}
+	for _, item := range items {
+		if !svnconfig.IsValidHookName(item.Name) {
+			return nil, fmt.Errorf("invalid hook name: %q", item.Name)
+		}
+		if seen[item.Name] {
+			return nil, fmt.Errorf("hook %q is declared more than once", item.Name)
+		}
+		seen[item.Name] = true
+		key := item.Key
+		if key == "" {
+			key = item.Name
+		}
+		var script []byte
+		if data, ok := cm.Data[key]; ok {
+			script = []byte(data)
+		} else if data, ok := cm.BinaryData[key]; ok {
+			script = data
+		} else {
+			return nil, fmt.Errorf("key %q not found in ConfigMap %q", key, spec.ConfigMapName)
+		}
+		hooks = append(hooks, svnconfig.Hook{Name: item.Name, Script: script})
+	}
+	return hooks, nil
+}
+
+// readOnlyOf returns nil unless the given repository is read-only.
+// Repositories are also made read-only while they are restored from backups.
+func (f *GeneratorFactory) readOnlyOf(r *svnv1alpha1.SVNRepository) *svnconfig.ReadOnly {
+	name := f.nameOf(r)
+	for i := range f.restores.Items {
+		rs := &f.restores.Items[i]
+		if rs.Namespace == f.server.Namespace && rs.Spec.Repository == name && rs.Status.Phase == svnv1alpha1.RestorePhaseRunning {
+			return &svnconfig.ReadOnly{Message: fmt.Sprintf("Under maintenance: restoring from SVNBackup %q", rs.Spec.BackupName)}
+		}
+	}
+	if !r.Spec.ReadOnly {
+		return nil
+	}
+	return &svnconfig.ReadOnly{Message: r.Spec.ReadOnlyMessage}
+}
+
+// createOptionsOf returns the options to create the given repository.
+// It returns nil if the repository is created with the defaults.
+func createOptionsOf(r *svnv1alpha1.SVNRepository) (*svnconfig.CreateOptions, error) {
+	spec := &r.Spec
+	if spec.FSType == "" && spec.CompatibleVersion == "" && spec.UUID == "" && spec.FSFS == nil {
+		return nil, nil
+	}
+	opts := &svnconfig.CreateOptions{
+		FSType:            spec.FSType,
+		CompatibleVersion: spec.CompatibleVersion,
+		UUID:              strings.ToLower(spec.UUID),
+	}
+	if spec.FSFS == nil {
+		return opts, nil
+	}
+	if spec.FSType != "" && spec.FSType != svnv1alpha1.FSTypeFSFS {
+		return nil, fmt.Errorf("fsfs cannot be used with fsType %q", spec.FSType)
+	}
+	opts.FSFS = &svnconfig.FSFSOptions{EnableRepSharing: spec.FSFS.EnableRepSharing}
+	if packing := spec.FSFS.RevPropPacking; packing != nil {
+		if packing.PackSize != nil {
+			size := packing.PackSize.Value() / 1024
+			if size < 1 {
+				return nil, fmt.Errorf("revPropPacking.packSize must be at least 1Ki: %s", packing.PackSize.String())
+			}
+			opts.FSFS.RevPropPackSize = int(size)
+		}
+		opts.FSFS.CompressPackedRevProps = packing.Compress
+	}
+	return opts, nil
+}
+
+// maxSizeOf returns the quota of the given repository in bytes, which is 0 if there is no quota.
+func maxSizeOf(r *svnv1alpha1.SVNRepository) int64 {
+	if r.Spec.Quota == nil {
+		return 0
+	}
+	return r.Spec.Quota.MaxSize.Value()
+}
+
+// commitPolicyOf returns the checks that the built-in pre-commit hook applies to the given repository.
+func commitPolicyOf(r *svnv1alpha1.SVNRepository) (*svnconfig.CommitPolicy, error) {
+	spec := r.Spec.CommitPolicy
+	if spec == nil {
+		return nil, nil
+	}
+	if spec.LogMessagePattern != "" {
+		if _, err := regexp.Compile(spec.LogMessagePattern); err != nil {
+			return nil, fmt.Errorf("invalid logMessagePattern: %w", err)
+		}
+	}
+	for _, pattern := range spec.BlockedPaths {
+		if !commitpolicy.IsValidPattern(pattern) {
+			return nil, fmt.Errorf("invalid pattern in blockedPaths: %q", pattern)
+		}
+	}
+	policy := &svnconfig.CommitPolicy{
+		LogMessagePattern:    spec.LogMessagePattern,
+		MinLogMessageLength:  int(spec.MinLogMessageLength),
+		RejectCaseCollisions: spec.RejectCaseCollisions,
+		BlockedPaths:         spec.BlockedPaths,
+	}
+	if spec.MaxFileSize != nil {
+		policy.MaxFileSize = spec.MaxFileSize.Value()
+	}
+	return policy, nil
+}
+
+// sourceOf returns the history that the given repository is created from.
+// It returns nil if the repository starts from scratch.
+func (f *GeneratorFactory) sourceOf(r *svnv1alpha1.SVNRepository) (*svnconfig.Source, error) {
+	spec := r.Spec.Source
+	if spec == nil || (spec.Dump == nil && spec.Mirror == nil) {
+		return nil, nil
+	}
+	if r.Spec.InitialLayout != nil || r.Spec.Seed != nil {
+		return nil, fmt.Errorf("source cannot be used with initialLayout nor seed")
+	}
+	if spec.Dump != nil && spec.Mirror != nil {
+		return nil, fmt.Errorf("source.dump cannot be used with source.mirror")
+	}
+	if spec.Mirror != nil {
+		return f.mirrorSourceOf(r)
+	}
+	if r.Namespace != f.server.Namespace {
+		return nil, fmt.Errorf("source.dump is only supported for SVNRepositories in the same namespace as the SVNServer")
+	}
+	volume, relPath, err := sourceVolumeOf(r.Spec.Source.Dump)
+	if err != nil {
+		return nil, err
+	}
+	compression := r.Spec.Source.Dump.Compression
+	if compression == "" {
+		compression = svnconfig.CompressionAuto
+	}
+	return &svnconfig.Source{
+		Dump: &svnconfig.DumpSource{
+			Path:        path.Join(VolumePathSources, volume.Name, relPath),
+			Compression: compression,
 		},
+	}, nil
+}
+
+// mirrorSourceOf returns the upstream repository that the given repository replicates.
+func (f *GeneratorFactory) mirrorSourceOf(r *svnv1alpha1.SVNRepository) (*svnconfig.Source, error) {
+	spec := r.Spec.Source.Mirror
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "svn") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url in source.mirror: %q", spec.URL)
+	}
+	interval := DefaultMirrorInterval
+	if spec.Interval != nil {
+		interval = spec.Interval.Duration
+	}
+	if interval < time.Second {
+		return nil, fmt.Errorf("interval in source.mirror must be at least 1s: %s", interval)
+	}
+	mirror := &svnconfig.MirrorSource{
+		URL:             spec.URL,
+		IntervalSeconds: int64(interval / time.Second),
+	}
+	if spec.CredentialsSecret != nil {
+		if r.Namespace != f.server.Namespace {
+			return nil, fmt.Errorf("source.mirror.credentialsSecret is only supported for SVNRepositories in the same namespace as the SVNServer")
+		}
+		if u.Scheme == "svn" {
+			// The credentials are passed to svnsync through the cache of the authentication realm,
+			// which can only be found out over HTTP.
+			return nil, fmt.Errorf("source.mirror.credentialsSecret is only supported for http and https URLs")
+		}
+		mirror.CredentialsDir = path.Join(VolumePathSources, credentialsVolumeOf(spec).Name)
 	}
+	return &svnconfig.Source{Mirror: mirror}, nil
 }
 
-func (r *SVNServerReconciler) serviceFor(s *svnv1alpha1.SVNServer) (*corev1.Service, error) {
-	labels := r.labelsFor(s)
-	svc := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      s.Name,
-			Namespace: s.Namespace,
-		},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{{
-				Name: "http",
-				Port: 80,
-			}},
-			Selector:  labels,
-			ClusterIP: "None",
+// credentialsVolumeOf returns the volume that contains the credentials of the upstream repository.
+func credentialsVolumeOf(mirror *svnv1alpha1.MirrorSource) corev1.Volume {
+	name := mirror.CredentialsSecret.Name
+	return corev1.Volume{
+		Name: sourceVolumeName("secret", name),
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: name},
 		},
 	}
-	err := ctrl.SetControllerReference(s, svc, r.Scheme)
-	if err != nil {
-		return nil, err
+}
+
+// sourceVolumeOf returns the volume that contains the dump file and the path to the file inside the volume.
+func sourceVolumeOf(dump *svnv1alpha1.DumpSource) (corev1.Volume, string, error) {
+	pvc, cm := dump.PersistentVolumeClaim, dump.ConfigMap
+	switch {
+	case pvc != nil && cm == nil:
+		if !svnconfig.IsValidRelativePath(pvc.Path) {
+			return corev1.Volume{}, "", fmt.Errorf("invalid path in source.dump: %q", pvc.Path)
+		}
+		return corev1.Volume{
+			Name: sourceVolumeName("persistentVolumeClaim", pvc.ClaimName),
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvc.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		}, pvc.Path, nil
+	case pvc == nil && cm != nil:
+		if !svnconfig.IsValidRelativePath(cm.Key) {
+			return corev1.Volume{}, "", fmt.Errorf("invalid key in source.dump: %q", cm.Key)
+		}
+		return corev1.Volume{
+			Name: sourceVolumeName("configMap", cm.Name),
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+				},
+			},
+		}, cm.Key, nil
+	default:
+		return corev1.Volume{}, "", fmt.Errorf("exactly one of persistentVolumeClaim and configMap must be specified in source.dump")
 	}
-	return svc, nil
 }
 
-func (r *SVNServerReconciler) configMapFor(f *GeneratorFactory) (*corev1.ConfigMap, error) {
-	gen := f.BuildGenerator()
-	authUserFile, err := gen.AuthUserFile()
-	if err != nil {
-		return nil, err
+// sourceVolumeName returns a name of the volume that is unique to the source,
+// since names of PVCs and ConfigMaps can be too long for names of volumes.
+func sourceVolumeName(kind, name string) string {
+	sum := sha256.Sum256([]byte(kind + "/" + name))
+	return VolumeNamePrefixSource + hex.EncodeToString(sum[:])[:16]
+}
+
+// SourceVolumes returns volumes that contain sources of repositories that can be created.
+func (f *GeneratorFactory) SourceVolumes() []corev1.Volume {
+	volumes := []corev1.Volume{}
+	seen := map[string]bool{}
+	for i := range f.repos.Items {
+		r := &f.repos.Items[i]
+		if !r.DeletionTimestamp.IsZero() {
+			continue
+		}
+		source, err := f.sourceOf(r)
+		if source == nil || err != nil {
+			continue
+		}
+		var volume corev1.Volume
+		switch {
+		case source.Dump != nil:
+			if r.Status.Load != nil && r.Status.Load.Phase == serverupdater.LoadPhaseSucceeded {
+				// The dump file is no longer read, so the volume is released.
+				continue
+			}
+			volume, _, _ = sourceVolumeOf(r.Spec.Source.Dump)
+		case source.Mirror != nil && source.Mirror.CredentialsDir != "":
+			volume = credentialsVolumeOf(r.Spec.Source.Mirror)
+		default:
+			continue
+		}
+		if seen[volume.Name] {
+			continue
+		}
+		seen[volume.Name] = true
+		volumes = append(volumes, volume)
 	}
-	authzSVNAccessFile, err := gen.AuthzSVNAccessFile()
-	if err != nil {
-		return nil, err
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes
+}
+
+// NeedsUpdaterStatus returns true if some SVNRepositories are waiting for server-updater,
+// including mirrors that have never been synchronized.
+func (f *GeneratorFactory) NeedsUpdaterStatus() bool {
+	if len(f.deletingRepositories()) > 0 {
+		return true
 	}
-	reposConfig, err := gen.ReposConfig()
-	if err != nil {
-		return nil, err
+	for i := range f.repos.Items {
+		r := &f.repos.Items[i]
+		if !r.DeletionTimestamp.IsZero() {
+			continue
+		}
+		source, err := f.sourceOf(r)
+		if source == nil || err != nil {
+			continue
+		}
+		if source.Dump != nil && (r.Status.Load == nil || r.Status.Load.Phase != serverupdater.LoadPhaseSucceeded) {
+			return true
+		}
+		if source.Mirror != nil && r.Status.Mirror == nil {
+			return true
+		}
 	}
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      f.server.Name,
-			Namespace: f.server.Namespace,
-		},
-		Data: map[string]string{
-			ConfigMapKeyAuthUserFile:       authUserFile,
-			ConfigMapKeyAuthzSVNAccessFile: authzSVNAccessFile,
-			ConfigMapKeyRepos:              reposConfig,
-		},
+	return false
+}
+
+// repositoryUsageOf returns the size of the repository reported by server-updater,
+// or nil if neither the repository nor the SVNServer has a quota.
+func (f *GeneratorFactory) repositoryUsageOf(r *svnv1alpha1.SVNRepository, status *serverupdater.Status) *svnv1alpha1.Usage {
+	if r.Spec.Quota == nil && f.server.Spec.Quota == nil {
+		return nil
 	}
-	err = ctrl.SetControllerReference(f.server, cm, r.Scheme)
-	if err != nil {
-		return nil, err
+	s := status.RepositoryStatusOf(f.nameOf(r))
+	if s == nil {
+		// The repository has not been created yet.
+		return nil
 	}
-	return cm, nil
+	var maxSize *resource.Quantity
+	if r.Spec.Quota != nil {
+		maxSize = &r.Spec.Quota.MaxSize
+	}
+	return usageOf(s.Size, maxSize)
 }
 
-func (r *SVNServerReconciler) labelsFor(s *svnv1alpha1.SVNServer) map[string]string {
-	return map[string]string{
-		LabelAppKey:          LabelAppValue,
-		LabelInstanceNameKey: s.Name,
+// RepositoryErrors returns errors in SVNRepositories keyed by their names in the configuration files.
+func (f *GeneratorFactory) RepositoryErrors() map[string]error {
+	errs := map[string]error{}
+	for i := range f.repos.Items {
+		r := &f.repos.Items[i]
+		if _, err := f.initialContentOf(r); err != nil {
+			errs[f.nameOf(r)] = err
+			continue
+		}
+		if _, err := f.sourceOf(r); err != nil {
+			errs[f.nameOf(r)] = err
+			continue
+		}
+		if _, err := f.hooksOf(r); err != nil {
+			errs[f.nameOf(r)] = err
+			continue
+		}
+		if _, err := commitPolicyOf(r); err != nil {
+			errs[f.nameOf(r)] = err
+			continue
+		}
+		if _, err := createOptionsOf(r); err != nil {
+			errs[f.nameOf(r)] = err
+		}
 	}
+	return errs
 }
 
-func (f *GeneratorFactory) BuildGenerator() *svnconfig.Generator {
-	repos := f.BuildRepositories()
-	groups := f.BuildGroups()
-	users := f.BuildUsers()
-	return &svnconfig.Generator{
-		Repositories: repos,
-		Groups:       groups,
-		Users:        users,
+// BuildDeletions returns repositories that server-updater should archive or delete.
+func (f *GeneratorFactory) BuildDeletions() []svnconfig.Deletion {
+	repos := f.deletingRepositories()
+	deletions := make([]svnconfig.Deletion, 0, len(repos))
+	for _, r := range repos {
+		deletions = append(deletions, svnconfig.Deletion{
+			Name:   f.nameOf(r),
+			Policy: deletionPolicyOf(r),
+		})
 	}
+	return deletions
 }
 
-func (f *GeneratorFactory) BuildRepositories() []svnconfig.Repository {
-	repos := make([]svnconfig.Repository, 0, len(f.repos.Items))
+// deletingRepositories returns SVNRepositories being deleted that wait for server-updater.
+func (f *GeneratorFactory) deletingRepositories() []*svnv1alpha1.SVNRepository {
+	repos := []*svnv1alpha1.SVNRepository{}
 	for i := range f.repos.Items {
-		r := f.repos.Items[i]
-		perms := f.buildPermissionsOf(r.Name)
-		repos = append(repos, svnconfig.Repository{Name: r.Name, Permissions: perms})
+		r := &f.repos.Items[i]
+		if r.DeletionTimestamp.IsZero() || !controllerutil.ContainsFinalizer(r, FinalizerRepository) {
+			continue
+		}
+		if deletionPolicyOf(r) == svnv1alpha1.DeletionPolicyRetain {
+			continue
+		}
+		repos = append(repos, r)
 	}
 	return repos
 }
 
-func (f *GeneratorFactory) buildPermissionsOf(repoName string) []svnconfig.Permission {
+func (f *GeneratorFactory) buildPermissionsOf(repo *svnv1alpha1.SVNRepository) []svnconfig.Permission {
 	perms := make([]svnconfig.Permission, 0, len(f.groups.Items))
 	for i := range f.groups.Items {
 		g := &f.groups.Items[i]
 		for j := range g.Spec.Permissions {
-			p := g.Spec.Permissions[j]
-			if repoName == p.Repository {
-				perms = append(perms, svnconfig.Permission{
-					Group:      g.Name,
-					Permission: p.Permission,
-				})
+			if perm, ok := buildPermission(g.Namespace, repo, &g.Spec.Permissions[j]); ok {
+				perm.Group = f.nameOf(g)
+				perms = append(perms, perm)
+			}
+		}
+	}
+	for i := range f.users.Items {
+		u := &f.users.Items[i]
+		if f.inactiveReasonOf(u) != "" {
+			// Disabled and expired users are left out of the authz file as well as AuthUserFile.
+			continue
+		}
+		for j := range u.Spec.Permissions {
+			if perm, ok := buildPermission(u.Namespace, repo, &u.Spec.Permissions[j]); ok {
+				perm.User = f.nameOf(u)
+				perms = append(perms, perm)
 			}
 		}
 	}
 	return perms
 }
 
+func anonymousAccessOf(r *svnv1alpha1.SVNRepository) string {
+	if r.Spec.AnonymousAccess == svnv1alpha1.AnonymousAccessR {
+		return svnv1alpha1.PermissionR
+	}
+	return svnv1alpha1.PermissionNone
+}
+
+// defaultPermissionsOf returns DefaultPermissions that applies to the given repository.
+func (f *GeneratorFactory) defaultPermissionsOf(r *svnv1alpha1.SVNRepository) svnv1alpha1.DefaultPermissions {
+	if r.Spec.DefaultPermissions != nil {
+		return *r.Spec.DefaultPermissions
+	}
+	if f.server.Spec.DefaultPermissions != nil {
+		return *f.server.Spec.DefaultPermissions
+	}
+	return svnv1alpha1.DefaultPermissions{}
+}
+
+// buildPermission converts p into svnconfig.Permission if p is a permission to the given repository.
+// namespace is the namespace of the SVNGroup or SVNUser that p belongs to.
+func buildPermission(namespace string, repo *svnv1alpha1.SVNRepository, p *svnv1alpha1.Permission) (svnconfig.Permission, bool) {
+	if namespace != repo.Namespace {
+		// Permissions can only refer to repositories in the same namespace.
+		return svnconfig.Permission{}, false
+	}
+	if ok, err := appliesTo(p, repo); !ok || err != nil {
+		// The error is reported by GroupErrors or UserErrors.
+		return svnconfig.Permission{}, false
+	}
+	path := p.Path
+	if path == "" {
+		path = svnconfig.RootPath
+	}
+	if !svnconfig.IsValidPath(path) {
+		// Invalid paths should have been rejected by the API server.
+		// We drop them here to make sure that they never break the authz file.
+		return svnconfig.Permission{}, false
+	}
+	return svnconfig.Permission{
+		Permission: p.Permission,
+		Path:       path,
+	}, true
+}
+
+// appliesTo returns true if p is a permission to the given repository.
+func appliesTo(p *svnv1alpha1.Permission, repo *svnv1alpha1.SVNRepository) (bool, error) {
+	if err := validatePermission(p); err != nil {
+		return false, err
+	}
+	if p.AllRepositories || p.Repository == repo.Name {
+		return true, nil
+	}
+	if p.RepositorySelector == nil {
+		return false, nil
+	}
+	selector, err := repositorySelectorOf(p)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(repo.Labels)), nil
+}
+
+// validatePermission returns an error unless exactly one of repository, repositorySelector and allRepositories
+// is set, since it would be unclear which repositories the permission applies to.
+func validatePermission(p *svnv1alpha1.Permission) error {
+	targets := 0
+	if p.Repository != "" {
+		targets++
+	}
+	if p.RepositorySelector != nil {
+		targets++
+	}
+	if p.AllRepositories {
+		targets++
+	}
+	if targets != 1 {
+		return fmt.Errorf("exactly one of repository, repositorySelector and allRepositories must be set in a permission")
+	}
+	_, err := repositorySelectorOf(p)
+	return err
+}
+
+func repositorySelectorOf(p *svnv1alpha1.Permission) (labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(p.RepositorySelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repositorySelector: %w", err)
+	}
+	return selector, nil
+}
+
 func (f *GeneratorFactory) BuildGroups() []svnconfig.Group {
+	nestedGroups, _ := f.resolveNestedGroups()
 	groups := make([]svnconfig.Group, 0, len(f.groups.Items))
 	for i := range f.groups.Items {
 		g := &f.groups.Items[i]
+		selector, err := memberSelectorOf(g)
+		if err != nil {
+			// The error is reported by GroupErrors.
+			selector = labels.Nothing()
+		}
 		users := make([]string, 0, len(f.users.Items))
 		for j := range f.users.Items {
 			u := &f.users.Items[j]
-			for k := range u.Spec.Groups {
-				if g.Name == u.Spec.Groups[k].Name {
-					users = append(users, u.Name)
-					break
-				}
+			if u.Namespace != g.Namespace {
+				// Groups can only have members in the same namespace.
+				continue
+			}
+			if f.inactiveReasonOf(u) != "" {
+				// Inactive users keep their memberships in SVNUsers, which are still worth auditing.
+				continue
+			}
+			if selector.Matches(labels.Set(u.Labels)) || belongsTo(u, g.Name) {
+				users = append(users, f.nameOf(u))
 			}
 		}
+		name := f.nameOf(g)
 		groups = append(groups, svnconfig.Group{
-			Name:  g.Name,
-			Users: users,
+			Name:   name,
+			Users:  users,
+			Groups: nestedGroups[name],
 		})
 	}
 	return groups
 }
 
+func belongsTo(u *svnv1alpha1.SVNUser, groupName string) bool {
+	for i := range u.Spec.Groups {
+		if groupName == u.Spec.Groups[i].Name {
+			return true
+		}
+	}
+	return false
+}
+
+func memberSelectorOf(g *svnv1alpha1.SVNGroup) (labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(g.Spec.MemberSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memberSelector: %w", err)
+	}
+	return selector, nil
+}
+
+// GroupErrors returns errors in SVNGroups keyed by their names in the configuration files.
+func (f *GeneratorFactory) GroupErrors() map[string]error {
+	_, errs := f.resolveNestedGroups()
+	for i := range f.groups.Items {
+		g := &f.groups.Items[i]
+		name := f.nameOf(g)
+		if _, ok := errs[name]; ok {
+			continue
+		}
+		if _, err := memberSelectorOf(g); err != nil {
+			errs[name] = err
+			continue
+		}
+		for j := range g.Spec.Permissions {
+			if err := validatePermission(&g.Spec.Permissions[j]); err != nil {
+				errs[name] = err
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// resolveNestedGroups returns the names of member groups of each SVNGroup.
+// References to missing groups and circular references are excluded from the result
+// and reported as errors instead, because mod_authz_svn rejects such authz files.
+func (f *GeneratorFactory) resolveNestedGroups() (map[string][]string, map[string]error) {
+	names := make([]string, 0, len(f.groups.Items))
+	exists := map[string]bool{}
+	for i := range f.groups.Items {
+		name := f.nameOf(&f.groups.Items[i])
+		names = append(names, name)
+		exists[name] = true
+	}
+
+	nestedGroups := map[string][]string{}
+	errs := map[string]error{}
+	for i := range f.groups.Items {
+		g := &f.groups.Items[i]
+		groupName := f.nameOf(g)
+		members := make([]string, 0, len(g.Spec.Groups))
+		for j := range g.Spec.Groups {
+			// Nested groups can only refer to groups in the same namespace.
+			name := f.qualify(g.Namespace, g.Spec.Groups[j].Name)
+			if !exists[name] {
+				errs[groupName] = fmt.Errorf("SVNGroup %q not found", g.Spec.Groups[j].Name)
+				continue
+			}
+			members = append(members, name)
+		}
+		nestedGroups[groupName] = members
+	}
+
+	// Dropping all members of groups in cycles is enough to make the graph acyclic,
+	// since findCycles reports every group that has a back edge.
+	for name, cycle := range findCycles(names, nestedGroups) {
+		errs[name] = fmt.Errorf("circular reference: %s", strings.Join(cycle, " -> "))
+		nestedGroups[name] = []string{}
+	}
+	return nestedGroups, errs
+}
+
+// findCycles finds cycles in the given graph by depth-first search.
+// It returns a map from each node in the cycles to one of the cycles that the node belongs to.
+// The result contains all nodes that have back edges, though it may not contain all nodes in
+// strongly connected components.
+func findCycles(nodes []string, edges map[string][]string) map[string][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+	cycles := map[string][]string{}
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range edges[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != next {
+					start--
+				}
+				cycle := make([]string, 0, len(stack)-start+1)
+				cycle = append(cycle, stack[start:]...)
+				cycle = append(cycle, next)
+				for _, n := range stack[start:] {
+					if _, ok := cycles[n]; !ok {
+						cycles[n] = cycle
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+	}
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return cycles
+}
+
 func (f *GeneratorFactory) BuildUsers() []svnconfig.User {
 	users := make([]svnconfig.User, 0, len(f.users.Items))
 	for i := range f.users.Items {
 		u := &f.users.Items[i]
+		if f.inactiveReasonOf(u) != "" {
+			continue
+		}
+		password, err := f.encryptedPasswordOf(u)
+		if err != nil {
+			// The error is reported by UserErrors. The user cannot log in until the password is available.
+			continue
+		}
 		users = append(users, svnconfig.User{
-			Name:              u.Name,
-			EncryptedPassword: u.Spec.EncryptedPassword,
+			Name:              f.nameOf(u),
+			EncryptedPassword: password,
 		})
 	}
 	return users
 }
 
+// inactiveReasonOf returns why the SVNUser cannot log in, or an empty string if the user is active.
+func (f *GeneratorFactory) inactiveReasonOf(u *svnv1alpha1.SVNUser) string {
+	if u.Spec.Disabled {
+		return "disabled"
+	}
+	if u.Spec.ExpiresAt != nil && !f.now.Before(u.Spec.ExpiresAt.Time) {
+		return fmt.Sprintf("expired at %s", u.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return ""
+}
+
+// NextExpiry returns the earliest time when an active SVNUser expires, or nil if no one is going to expire.
+func (f *GeneratorFactory) NextExpiry() *time.Time {
+	var next *time.Time
+	for i := range f.users.Items {
+		u := &f.users.Items[i]
+		if u.Spec.ExpiresAt == nil || f.inactiveReasonOf(u) != "" {
+			continue
+		}
+		if next == nil || u.Spec.ExpiresAt.Time.Before(*next) {
+			t := u.Spec.ExpiresAt.Time
+			next = &t
+		}
+	}
+	return next
+}
+
+// encryptedPasswordOf returns the password of the SVNUser encrypted by bcrypt.
+func (f *GeneratorFactory) encryptedPasswordOf(u *svnv1alpha1.SVNUser) (string, error) {
+	key, ok := passwordKeyOf(u)
+	if !ok {
+		if u.Spec.EncryptedPassword == "" {
+			return "", fmt.Errorf("either encryptedPassword or passwordSecretRef must be specified")
+		}
+		return u.Spec.EncryptedPassword, nil
+	}
+	if u.Spec.EncryptedPassword != "" {
+		return "", fmt.Errorf("encryptedPassword and passwordSecretRef cannot be specified at the same time")
+	}
+	password, ok := f.passwords[key]
+	if !ok {
+		return "", fmt.Errorf("key %q of Secret %q not found", key.Key, key.Name)
+	}
+	return password, nil
+}
+
+// UserErrors returns errors in SVNUsers keyed by their names in the configuration files.
+func (f *GeneratorFactory) UserErrors() map[string]error {
+	errs := map[string]error{}
+	for i := range f.users.Items {
+		u := &f.users.Items[i]
+		if _, err := f.encryptedPasswordOf(u); err != nil {
+			errs[f.nameOf(u)] = err
+			continue
+		}
+		for j := range u.Spec.Permissions {
+			if err := validatePermission(&u.Spec.Permissions[j]); err != nil {
+				errs[f.nameOf(u)] = err
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// nameOf returns the name of obj in the configuration files.
+func (f *GeneratorFactory) nameOf(obj metav1.Object) string {
+	return f.qualify(obj.GetNamespace(), obj.GetName())
+}
+
+// qualify returns the name of an object in the configuration files.
+// Objects in the same namespace as the SVNServer keep their names, while objects in other namespaces
+// are prefixed with their namespaces, like `namespace_name`.
+// This never conflicts because object names cannot contain underscores.
+func (f *GeneratorFactory) qualify(namespace, name string) string {
+	if namespace == f.server.Namespace {
+		return name
+	}
+	return namespace + "_" + name
+}
+
 func addCondition(conds []svnv1alpha1.Condition, newCond svnv1alpha1.Condition) []svnv1alpha1.Condition {
 	conds = append(conds, newCond)
 	l := len(conds)
@@ -581,19 +2707,44 @@ func addCondition(conds []svnv1alpha1.Condition, newCond svnv1alpha1.Condition)
 func (r *SVNServerReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNRepository{}, IndexKeySVNServer, func(rawObj client.Object) []string {
 		obj := rawObj.(*svnv1alpha1.SVNRepository)
-		return []string{obj.Spec.SVNServer}
+		return []string{svnServerKey(obj.Spec.SVNServer.NamespaceOr(obj.Namespace), obj.Spec.SVNServer.Name)}
 	}); err != nil {
 		return err
 	}
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNGroup{}, IndexKeySVNServer, func(rawObj client.Object) []string {
 		obj := rawObj.(*svnv1alpha1.SVNGroup)
-		return []string{obj.Spec.SVNServer}
+		return []string{svnServerKey(obj.Spec.SVNServer.NamespaceOr(obj.Namespace), obj.Spec.SVNServer.Name)}
 	}); err != nil {
 		return err
 	}
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNUser{}, IndexKeySVNServer, func(rawObj client.Object) []string {
 		obj := rawObj.(*svnv1alpha1.SVNUser)
-		return []string{obj.Spec.SVNServer}
+		return []string{svnServerKey(obj.Spec.SVNServer.NamespaceOr(obj.Namespace), obj.Spec.SVNServer.Name)}
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNRestore{}, IndexKeySVNServer, func(rawObj client.Object) []string {
+		obj := rawObj.(*svnv1alpha1.SVNRestore)
+		return []string{svnServerKey(obj.Spec.SVNServer.NamespaceOr(obj.Namespace), obj.Spec.SVNServer.Name)}
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNRepository{}, IndexKeyConfigMap, func(rawObj client.Object) []string {
+		obj := rawObj.(*svnv1alpha1.SVNRepository)
+		var keys []string
+		for _, name := range configMapNamesOf(obj) {
+			keys = append(keys, obj.Namespace+"/"+name)
+		}
+		return keys
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &svnv1alpha1.SVNUser{}, IndexKeySecret, func(rawObj client.Object) []string {
+		obj := rawObj.(*svnv1alpha1.SVNUser)
+		if obj.Spec.PasswordSecretRef == nil {
+			return nil
+		}
+		return []string{obj.Namespace + "/" + obj.Spec.PasswordSecretRef.Name}
 	}); err != nil {
 		return err
 	}
@@ -602,11 +2753,22 @@ func (r *SVNServerReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Man
 		Watches(&source.Kind{Type: &svnv1alpha1.SVNRepository{}}, handler.EnqueueRequestsFromMapFunc(repositoryEnqueuer(mgr))).
 		Watches(&source.Kind{Type: &svnv1alpha1.SVNGroup{}}, handler.EnqueueRequestsFromMapFunc(groupEnqueuer(mgr))).
 		Watches(&source.Kind{Type: &svnv1alpha1.SVNUser{}}, handler.EnqueueRequestsFromMapFunc(userEnqueuer(mgr))).
+		Watches(&source.Kind{Type: &svnv1alpha1.SVNRestore{}}, handler.EnqueueRequestsFromMapFunc(restoreEnqueuer(mgr))).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(namespaceEnqueuer(mgr))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(configMapEnqueuer(mgr))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(secretEnqueuer(mgr))).
 		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.Secret{}).
 		Complete(r)
 }
 
+// svnServerKey returns the value of IndexKeySVNServer for objects that belong to the given SVNServer.
+func svnServerKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
 func repositoryEnqueuer(mgr ctrl.Manager) handler.MapFunc {
 	return func(obj client.Object) []reconcile.Request {
 		svn, ok := obj.(*svnv1alpha1.SVNRepository)
@@ -616,8 +2778,8 @@ func repositoryEnqueuer(mgr ctrl.Manager) handler.MapFunc {
 		}
 		return []reconcile.Request{{
 			NamespacedName: types.NamespacedName{
-				Namespace: svn.Namespace,
-				Name:      svn.Spec.SVNServer,
+				Namespace: svn.Spec.SVNServer.NamespaceOr(svn.Namespace),
+				Name:      svn.Spec.SVNServer.Name,
 			},
 		}}
 	}
@@ -632,8 +2794,8 @@ func groupEnqueuer(mgr ctrl.Manager) handler.MapFunc {
 		}
 		return []reconcile.Request{{
 			NamespacedName: types.NamespacedName{
-				Namespace: svn.Namespace,
-				Name:      svn.Spec.SVNServer,
+				Namespace: svn.Spec.SVNServer.NamespaceOr(svn.Namespace),
+				Name:      svn.Spec.SVNServer.Name,
 			},
 		}}
 	}
@@ -648,9 +2810,101 @@ func userEnqueuer(mgr ctrl.Manager) handler.MapFunc {
 		}
 		return []reconcile.Request{{
 			NamespacedName: types.NamespacedName{
-				Namespace: svn.Namespace,
-				Name:      svn.Spec.SVNServer,
+				Namespace: svn.Spec.SVNServer.NamespaceOr(svn.Namespace),
+				Name:      svn.Spec.SVNServer.Name,
+			},
+		}}
+	}
+}
+
+func restoreEnqueuer(mgr ctrl.Manager) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		svn, ok := obj.(*svnv1alpha1.SVNRestore)
+		if !ok {
+			mgr.GetLogger().Info("Not an SVNRestore", "object", obj)
+			return []reconcile.Request{}
+		}
+		return []reconcile.Request{{
+			NamespacedName: types.NamespacedName{
+				Namespace: svn.Spec.SVNServer.NamespaceOr(svn.Namespace),
+				Name:      svn.Spec.SVNServer.Name,
 			},
 		}}
 	}
 }
+
+// namespaceEnqueuer enqueues SVNServers that select namespaces by labels,
+// since changes to labels of namespaces may change the objects that belong to them.
+func namespaceEnqueuer(mgr ctrl.Manager) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*corev1.Namespace); !ok {
+			mgr.GetLogger().Info("Not a Namespace", "object", obj)
+			return []reconcile.Request{}
+		}
+		servers := &svnv1alpha1.SVNServerList{}
+		if err := mgr.GetClient().List(context.Background(), servers); err != nil {
+			mgr.GetLogger().Error(err, "Failed to list SVNServer")
+			return []reconcile.Request{}
+		}
+		reqs := []reconcile.Request{}
+		for i := range servers.Items {
+			s := &servers.Items[i]
+			if s.Spec.NamespaceSelector == nil {
+				continue
+			}
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: s.Namespace,
+					Name:      s.Name,
+				},
+			})
+		}
+		return reqs
+	}
+}
+
+// configMapEnqueuer enqueues SVNServers whose SVNRepositories refer to the ConfigMap as their seed files or hooks.
+func configMapEnqueuer(mgr ctrl.Manager) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		repos := &svnv1alpha1.SVNRepositoryList{}
+		err := mgr.GetClient().List(context.Background(), repos, client.MatchingFields{IndexKeyConfigMap: obj.GetNamespace() + "/" + obj.GetName()})
+		if err != nil {
+			mgr.GetLogger().Error(err, "Failed to list SVNRepository")
+			return []reconcile.Request{}
+		}
+		reqs := []reconcile.Request{}
+		for i := range repos.Items {
+			repo := &repos.Items[i]
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: repo.Spec.SVNServer.NamespaceOr(repo.Namespace),
+					Name:      repo.Spec.SVNServer.Name,
+				},
+			})
+		}
+		return reqs
+	}
+}
+
+// secretEnqueuer enqueues SVNServers whose SVNUsers refer to the Secret as their passwords.
+func secretEnqueuer(mgr ctrl.Manager) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		users := &svnv1alpha1.SVNUserList{}
+		err := mgr.GetClient().List(context.Background(), users, client.MatchingFields{IndexKeySecret: obj.GetNamespace() + "/" + obj.GetName()})
+		if err != nil {
+			mgr.GetLogger().Error(err, "Failed to list SVNUser")
+			return []reconcile.Request{}
+		}
+		reqs := []reconcile.Request{}
+		for i := range users.Items {
+			u := &users.Items[i]
+			reqs = append(reqs, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: u.Spec.SVNServer.NamespaceOr(u.Namespace),
+					Name:      u.Spec.SVNServer.Name,
+				},
+			})
+		}
+		return reqs
+	}
+}
@@ -22,13 +22,18 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
 
 	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
+	"github.com/genkami/svn-operator/pkg/serverupdater"
+	svnconfig "github.com/genkami/svn-operator/pkg/svnconfig"
 )
 
 var _ = Describe("SVNServer Controller", func() {
@@ -438,3 +443,1353 @@ var _ = Describe("SVNServer Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("GeneratorFactory", func() {
+	newGroup := func(name string, members ...string) svnv1alpha1.SVNGroup {
+		refs := make([]svnv1alpha1.GroupRef, 0, len(members))
+		for _, m := range members {
+			refs = append(refs, svnv1alpha1.GroupRef{Name: m})
+		}
+		return svnv1alpha1.SVNGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       svnv1alpha1.SVNGroupSpec{Groups: refs},
+		}
+	}
+	newFactory := func(groups ...svnv1alpha1.SVNGroup) *GeneratorFactory {
+		return &GeneratorFactory{
+			server: &svnv1alpha1.SVNServer{},
+			repos:  &svnv1alpha1.SVNRepositoryList{},
+			groups: &svnv1alpha1.SVNGroupList{Items: groups},
+			users:  &svnv1alpha1.SVNUserList{},
+
+			restores: &svnv1alpha1.SVNRestoreList{},
+		}
+	}
+	newUser := func(name string, labels map[string]string, groups ...string) svnv1alpha1.SVNUser {
+		refs := make([]svnv1alpha1.GroupRef, 0, len(groups))
+		for _, g := range groups {
+			refs = append(refs, svnv1alpha1.GroupRef{Name: g})
+		}
+		return svnv1alpha1.SVNUser{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec:       svnv1alpha1.SVNUserSpec{Groups: refs, EncryptedPassword: "$2a$10$hash"},
+		}
+	}
+	usersOf := func(f *GeneratorFactory) map[string][]string {
+		result := map[string][]string{}
+		for _, g := range f.BuildGroups() {
+			result[g.Name] = g.Users
+		}
+		return result
+	}
+	nestedGroupsOf := func(f *GeneratorFactory) map[string][]string {
+		result := map[string][]string{}
+		for _, g := range f.BuildGroups() {
+			result[g.Name] = g.Groups
+		}
+		return result
+	}
+
+	Describe("nested groups", func() {
+		Context("when groups are nested", func() {
+			It("resolves member groups", func() {
+				f := newFactory(
+					newGroup("company", "dept"),
+					newGroup("dept", "team1", "team2"),
+					newGroup("team1"),
+					newGroup("team2"),
+				)
+				Expect(nestedGroupsOf(f)).To(Equal(map[string][]string{
+					"company": {"dept"},
+					"dept":    {"team1", "team2"},
+					"team1":   {},
+					"team2":   {},
+				}))
+				Expect(f.GroupErrors()).To(BeEmpty())
+			})
+		})
+
+		Context("when a group refers to a missing group", func() {
+			It("drops the reference and reports an error", func() {
+				f := newFactory(
+					newGroup("dept", "team1", "missing"),
+					newGroup("team1"),
+				)
+				Expect(nestedGroupsOf(f)).To(Equal(map[string][]string{
+					"dept":  {"team1"},
+					"team1": {},
+				}))
+				errs := f.GroupErrors()
+				Expect(errs).To(HaveLen(1))
+				Expect(errs["dept"]).To(MatchError(`SVNGroup "missing" not found`))
+			})
+		})
+
+		Context("when a group refers to itself", func() {
+			It("drops the reference and reports an error", func() {
+				f := newFactory(newGroup("narcissus", "narcissus"))
+				Expect(nestedGroupsOf(f)).To(Equal(map[string][]string{
+					"narcissus": {},
+				}))
+				errs := f.GroupErrors()
+				Expect(errs).To(HaveLen(1))
+				Expect(errs["narcissus"]).To(MatchError("circular reference: narcissus -> narcissus"))
+			})
+		})
+
+		Context("when groups refer to each other", func() {
+			It("breaks the cycle and reports errors on the groups in the cycle", func() {
+				f := newFactory(
+					newGroup("outsider", "a"),
+					newGroup("a", "b"),
+					newGroup("b", "c"),
+					newGroup("c", "a"),
+				)
+				Expect(nestedGroupsOf(f)).To(Equal(map[string][]string{
+					"outsider": {"a"},
+					"a":        {},
+					"b":        {},
+					"c":        {},
+				}))
+				errs := f.GroupErrors()
+				Expect(errs).To(HaveLen(3))
+				Expect(errs["a"]).To(MatchError("circular reference: a -> b -> c -> a"))
+				Expect(errs["b"]).To(MatchError("circular reference: a -> b -> c -> a"))
+				Expect(errs["c"]).To(MatchError("circular reference: a -> b -> c -> a"))
+			})
+		})
+	})
+
+	Describe("member selector", func() {
+		var f *GeneratorFactory
+		BeforeEach(func() {
+			f = newFactory(newGroup("team"))
+			f.users.Items = []svnv1alpha1.SVNUser{
+				newUser("alice", map[string]string{"team": "a"}),
+				newUser("bob", map[string]string{"team": "b"}),
+				newUser("carol", nil, "team"),
+			}
+		})
+
+		Context("when the selector is not set", func() {
+			It("contains only users that refer to the group", func() {
+				Expect(usersOf(f)).To(Equal(map[string][]string{
+					"team": {"carol"},
+				}))
+			})
+		})
+
+		Context("when the selector is set", func() {
+			It("contains users that match the selector in addition to users that refer to the group", func() {
+				f.groups.Items[0].Spec.MemberSelector = &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "a"},
+				}
+				Expect(usersOf(f)).To(Equal(map[string][]string{
+					"team": {"alice", "carol"},
+				}))
+				Expect(f.GroupErrors()).To(BeEmpty())
+			})
+		})
+
+		Context("when the selector is empty", func() {
+			It("contains all users", func() {
+				f.groups.Items[0].Spec.MemberSelector = &metav1.LabelSelector{}
+				Expect(usersOf(f)).To(Equal(map[string][]string{
+					"team": {"alice", "bob", "carol"},
+				}))
+			})
+		})
+
+		Context("when the selector is invalid", func() {
+			It("ignores the selector and reports an error", func() {
+				f.groups.Items[0].Spec.MemberSelector = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "team", Operator: "Unknown"},
+					},
+				}
+				Expect(usersOf(f)).To(Equal(map[string][]string{
+					"team": {"carol"},
+				}))
+				Expect(f.GroupErrors()).To(HaveKey("team"))
+			})
+		})
+	})
+
+	Describe("repository selection", func() {
+		var f *GeneratorFactory
+		newRepo := func(name string, labels map[string]string) svnv1alpha1.SVNRepository {
+			return svnv1alpha1.SVNRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			}
+		}
+		permissionsOf := func(f *GeneratorFactory) map[string][]svnconfig.Permission {
+			result := map[string][]svnconfig.Permission{}
+			for _, r := range f.BuildRepositories() {
+				result[r.Name] = r.Permissions
+			}
+			return result
+		}
+		BeforeEach(func() {
+			f = newFactory(newGroup("readers"))
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				newRepo("app", map[string]string{"kind": "app"}),
+				newRepo("lib", map[string]string{"kind": "lib"}),
+			}
+		})
+
+		Context("when a permission selects repositories by labels", func() {
+			It("applies to the matching repositories", func() {
+				f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+					RepositorySelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"kind": "lib"},
+					},
+					Permission: svnv1alpha1.PermissionR,
+				}}
+				Expect(permissionsOf(f)).To(Equal(map[string][]svnconfig.Permission{
+					"app": {},
+					"lib": {{Group: "readers", Permission: "r", Path: "/"}},
+				}))
+			})
+		})
+
+		Context("when a permission applies to all repositories", func() {
+			It("applies to all repositories", func() {
+				f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+					AllRepositories: true,
+					Permission:      svnv1alpha1.PermissionR,
+				}}
+				Expect(permissionsOf(f)).To(Equal(map[string][]svnconfig.Permission{
+					"app": {{Group: "readers", Permission: "r", Path: "/"}},
+					"lib": {{Group: "readers", Permission: "r", Path: "/"}},
+				}))
+			})
+		})
+
+		Context("when a repository selector is invalid", func() {
+			It("ignores the permission and reports an error", func() {
+				f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+					RepositorySelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "kind", Operator: "Unknown"},
+						},
+					},
+					Permission: svnv1alpha1.PermissionR,
+				}}
+				Expect(permissionsOf(f)).To(Equal(map[string][]svnconfig.Permission{
+					"app": {},
+					"lib": {},
+				}))
+				Expect(f.GroupErrors()).To(HaveKey("readers"))
+			})
+		})
+
+		Context("when a permission has more than one way to select repositories", func() {
+			It("ignores the permission and reports an error", func() {
+				f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+					Repository:      "app",
+					AllRepositories: true,
+					Permission:      svnv1alpha1.PermissionR,
+				}}
+				Expect(permissionsOf(f)).To(Equal(map[string][]svnconfig.Permission{
+					"app": {},
+					"lib": {},
+				}))
+				Expect(f.GroupErrors()).To(HaveKey("readers"))
+			})
+		})
+
+		Context("when a permission selects no repositories", func() {
+			It("reports an error", func() {
+				f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+					Permission: svnv1alpha1.PermissionR,
+				}}
+				Expect(f.GroupErrors()).To(HaveKey("readers"))
+			})
+		})
+
+		Context("when a permission of a user is invalid", func() {
+			It("ignores the permission and reports an error", func() {
+				f.passwords = map[passwordKey]string{
+					{NamespacedName: types.NamespacedName{Name: "alice-password"}, Key: corev1.BasicAuthPasswordKey}: "$2a$10$hash",
+				}
+				f.users.Items = []svnv1alpha1.SVNUser{{
+					ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+					Spec: svnv1alpha1.SVNUserSpec{
+						PasswordSecretRef: &svnv1alpha1.PasswordSecretRef{Name: "alice-password"},
+						Permissions: []svnv1alpha1.Permission{{
+							RepositorySelector: &metav1.LabelSelector{
+								MatchExpressions: []metav1.LabelSelectorRequirement{
+									{Key: "kind", Operator: "Unknown"},
+								},
+							},
+							Permission: svnv1alpha1.PermissionR,
+						}},
+					},
+				}}
+				Expect(permissionsOf(f)).To(Equal(map[string][]svnconfig.Permission{
+					"app": {},
+					"lib": {},
+				}))
+				Expect(f.UserErrors()).To(HaveKey("alice"))
+			})
+		})
+	})
+
+	Describe("default permissions", func() {
+		var f *GeneratorFactory
+		authenticatedAccessOf := func(f *GeneratorFactory) map[string]string {
+			result := map[string]string{}
+			for _, r := range f.BuildRepositories() {
+				result[r.Name] = r.AuthenticatedAccess
+			}
+			return result
+		}
+		BeforeEach(func() {
+			f = newFactory()
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				{ObjectMeta: metav1.ObjectMeta{Name: "inherited"}},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "overridden"},
+					Spec: svnv1alpha1.SVNRepositorySpec{
+						DefaultPermissions: &svnv1alpha1.DefaultPermissions{},
+					},
+				},
+			}
+		})
+
+		Context("when the server does not have default permissions", func() {
+			It("grants nothing by default", func() {
+				Expect(authenticatedAccessOf(f)).To(Equal(map[string]string{
+					"inherited":  "",
+					"overridden": "",
+				}))
+			})
+		})
+
+		Context("when the server has default permissions", func() {
+			It("grants them unless repositories override them", func() {
+				f.server.Spec.DefaultPermissions = &svnv1alpha1.DefaultPermissions{
+					Authenticated: svnv1alpha1.PermissionR,
+				}
+				Expect(authenticatedAccessOf(f)).To(Equal(map[string]string{
+					"inherited":  "r",
+					"overridden": "",
+				}))
+			})
+		})
+	})
+
+	Describe("objects in other namespaces", func() {
+		var f *GeneratorFactory
+		BeforeEach(func() {
+			f = newFactory(newGroup("devs"), newGroup("admins", "devs"))
+			f.server.Namespace = "svn"
+			f.groups.Items[0].Namespace = "team"
+			f.groups.Items[0].Spec.Permissions = []svnv1alpha1.Permission{{
+				AllRepositories: true,
+				Permission:      svnv1alpha1.PermissionRW,
+			}}
+			f.groups.Items[1].Namespace = "svn"
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "svn", Name: "shared"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "app"}},
+			}
+			alice := newUser("alice", nil, "devs")
+			alice.Namespace = "team"
+			bob := newUser("bob", nil, "devs")
+			bob.Namespace = "svn"
+			f.users.Items = []svnv1alpha1.SVNUser{alice, bob}
+		})
+
+		It("prefixes their names with their namespaces", func() {
+			names := []string{}
+			for _, r := range f.BuildRepositories() {
+				names = append(names, r.Name)
+			}
+			Expect(names).To(Equal([]string{"shared", "team_app"}))
+			names = []string{}
+			for _, u := range f.BuildUsers() {
+				names = append(names, u.Name)
+			}
+			Expect(names).To(Equal([]string{"team_alice", "bob"}))
+		})
+
+		It("resolves references only within the same namespace", func() {
+			Expect(usersOf(f)).To(Equal(map[string][]string{
+				"team_devs": {"team_alice"},
+				"admins":    {},
+			}))
+			Expect(nestedGroupsOf(f)).To(Equal(map[string][]string{
+				"team_devs": {},
+				"admins":    {},
+			}))
+			errs := f.GroupErrors()
+			Expect(errs).To(HaveLen(1))
+			Expect(errs["admins"]).To(MatchError(`SVNGroup "devs" not found`))
+			perms := map[string][]svnconfig.Permission{}
+			for _, r := range f.BuildRepositories() {
+				perms[r.Name] = r.Permissions
+			}
+			Expect(perms).To(Equal(map[string][]svnconfig.Permission{
+				"shared":   {},
+				"team_app": {{Group: "team_devs", Permission: "rw", Path: "/"}},
+			}))
+		})
+	})
+
+	Describe("repositories being deleted", func() {
+		var f *GeneratorFactory
+		newRepo := func(name, policy string, deleting bool) svnv1alpha1.SVNRepository {
+			repo := svnv1alpha1.SVNRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       svnv1alpha1.SVNRepositorySpec{DeletionPolicy: policy},
+			}
+			if needsFinalizer(&repo) {
+				repo.Finalizers = []string{FinalizerRepository}
+			}
+			if deleting {
+				now := metav1.Now()
+				repo.DeletionTimestamp = &now
+			}
+			return repo
+		}
+		BeforeEach(func() {
+			f = newFactory()
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				newRepo("active", svnv1alpha1.DeletionPolicyDelete, false),
+				newRepo("retained", "", true),
+				newRepo("archived", svnv1alpha1.DeletionPolicyArchive, true),
+				newRepo("deleted", svnv1alpha1.DeletionPolicyDelete, true),
+			}
+		})
+
+		It("keeps finalizers only on repositories that are not retained", func() {
+			Expect(f.repos.Items[0].Finalizers).To(ConsistOf(FinalizerRepository))
+			Expect(f.repos.Items[1].Finalizers).To(BeEmpty())
+		})
+
+		It("no longer serves them", func() {
+			names := []string{}
+			for _, r := range f.BuildRepositories() {
+				names = append(names, r.Name)
+			}
+			Expect(names).To(Equal([]string{"active"}))
+		})
+
+		It("asks server-updater to archive or delete them unless they are retained", func() {
+			Expect(f.BuildDeletions()).To(Equal([]svnconfig.Deletion{
+				{Name: "archived", Policy: svnconfig.DeletionPolicyArchive},
+				{Name: "deleted", Policy: svnconfig.DeletionPolicyDelete},
+			}))
+		})
+	})
+
+	Describe("initial content", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		build := func() svnconfig.Repository {
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(1))
+			return repos[0]
+		}
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			}}
+			repo = &f.repos.Items[0]
+			f.configMaps = map[types.NamespacedName]*corev1.ConfigMap{
+				{Namespace: "default", Name: "seed"}: {
+					Data:       map[string]string{"README.md": "hello", "LICENSE": "MIT"},
+					BinaryData: map[string][]byte{"logo.png": {0x89, 0x50}},
+				},
+			}
+		})
+
+		Context("when nothing is specified", func() {
+			It("has no initial content", func() {
+				Expect(build().Initial).To(BeNil())
+				Expect(build().Pending).To(BeFalse())
+			})
+		})
+
+		Context("when the standard layout is specified with extra directories", func() {
+			It("creates trunk, branches, tags and the directories", func() {
+				repo.Spec.InitialLayout = &svnv1alpha1.InitialLayout{
+					Preset:      svnv1alpha1.LayoutPresetStandard,
+					Directories: []string{"trunk/docs"},
+				}
+				Expect(build().Initial).To(Equal(&svnconfig.InitialContent{
+					Directories: []string{"trunk", "branches", "tags", "trunk/docs"},
+				}))
+			})
+		})
+
+		Context("when seed files are specified without items", func() {
+			It("commits every key to the root", func() {
+				repo.Spec.Seed = &svnv1alpha1.Seed{ConfigMapName: "seed"}
+				Expect(build().Initial).To(Equal(&svnconfig.InitialContent{
+					Files: []svnconfig.File{
+						{Path: "LICENSE", Content: []byte("MIT")},
+						{Path: "README.md", Content: []byte("hello")},
+						{Path: "logo.png", Content: []byte{0x89, 0x50}},
+					},
+				}))
+			})
+		})
+
+		Context("when seed files are specified with items", func() {
+			It("commits the items to the given paths", func() {
+				repo.Spec.Seed = &svnv1alpha1.Seed{
+					ConfigMapName: "seed",
+					Items:         []svnv1alpha1.SeedItem{{Key: "README.md", Path: "trunk/README.md"}},
+				}
+				Expect(build().Initial.Files).To(Equal([]svnconfig.File{
+					{Path: "trunk/README.md", Content: []byte("hello")},
+				}))
+			})
+		})
+
+		Context("when the seed ConfigMap does not exist", func() {
+			It("postpones the creation and reports an error", func() {
+				repo.Spec.Seed = &svnv1alpha1.Seed{ConfigMapName: "missing"}
+				Expect(build().Pending).To(BeTrue())
+				errs := f.RepositoryErrors()
+				Expect(errs).To(HaveLen(1))
+				Expect(errs["app"]).To(MatchError(`ConfigMap "missing" for seed files not found`))
+			})
+		})
+
+		Context("when the repository already exists", func() {
+			It("drops the initial content even if the seed ConfigMap has been deleted", func() {
+				repo.Spec.InitialLayout = &svnv1alpha1.InitialLayout{Preset: svnv1alpha1.LayoutPresetStandard}
+				repo.Spec.Seed = &svnv1alpha1.Seed{ConfigMapName: "missing"}
+				repo.Status.Repository = &svnv1alpha1.RepositoryInfo{Revision: 1}
+				Expect(build().Initial).To(BeNil())
+				Expect(build().Pending).To(BeFalse())
+				Expect(f.RepositoryErrors()).To(BeEmpty())
+				Expect(configMapNamesOf(repo)).To(BeEmpty())
+			})
+		})
+
+		Context("when a path is invalid", func() {
+			It("postpones the creation and reports an error", func() {
+				repo.Spec.InitialLayout = &svnv1alpha1.InitialLayout{
+					Directories: []string{"../escape"},
+				}
+				Expect(build().Pending).To(BeTrue())
+				Expect(f.RepositoryErrors()).To(HaveKey("app"))
+			})
+		})
+	})
+
+	Describe("hooks", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		build := func() svnconfig.Repository {
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(1))
+			return repos[0]
+		}
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			}}
+			repo = &f.repos.Items[0]
+			f.configMaps = map[types.NamespacedName]*corev1.ConfigMap{
+				{Namespace: "default", Name: "hooks"}: {
+					Data: map[string]string{
+						"pre-commit":  "#!/bin/sh\nexit 0\n",
+						"post-commit": "#!/bin/sh\necho done\n",
+					},
+				},
+			}
+		})
+
+		Context("when hooks are specified without items", func() {
+			It("installs every key as a hook", func() {
+				repo.Spec.Hooks = &svnv1alpha1.Hooks{ConfigMapName: "hooks"}
+				Expect(build().Hooks).To(Equal([]svnconfig.Hook{
+					{Name: "post-commit", Script: []byte("#!/bin/sh\necho done\n")},
+					{Name: "pre-commit", Script: []byte("#!/bin/sh\nexit 0\n")},
+				}))
+				Expect(build().Pending).To(BeFalse())
+			})
+		})
+
+		Context("when hooks are specified with items", func() {
+			It("installs the items only", func() {
+				repo.Spec.Hooks = &svnv1alpha1.Hooks{
+					ConfigMapName: "hooks",
+					Items:         []svnv1alpha1.HookItem{{Name: "start-commit", Key: "pre-commit"}},
+				}
+				Expect(build().Hooks).To(Equal([]svnconfig.Hook{
+					{Name: "start-commit", Script: []byte("#!/bin/sh\nexit 0\n")},
+				}))
+			})
+		})
+
+		Context("when a key is not a hook name", func() {
+			It("leaves the repository untouched and reports an error", func() {
+				f.configMaps[types.NamespacedName{Namespace: "default", Name: "hooks"}].Data["pre_commit"] = "#!/bin/sh\n"
+				repo.Spec.Hooks = &svnv1alpha1.Hooks{ConfigMapName: "hooks"}
+				Expect(build().Pending).To(BeTrue())
+				Expect(f.RepositoryErrors()["app"]).To(MatchError(`invalid hook name: "pre_commit"`))
+			})
+		})
+
+		Context("when the ConfigMap does not exist", func() {
+			It("leaves the repository untouched and reports an error", func() {
+				repo.Spec.Hooks = &svnv1alpha1.Hooks{ConfigMapName: "missing"}
+				Expect(build().Pending).To(BeTrue())
+				Expect(f.RepositoryErrors()["app"]).To(MatchError(`ConfigMap "missing" for hooks not found`))
+			})
+		})
+
+		Context("when the repository is in another namespace than the server", func() {
+			It("removes the hooks and reports an error", func() {
+				repo.Namespace = "team"
+				f.configMaps = map[types.NamespacedName]*corev1.ConfigMap{
+					{Namespace: "team", Name: "hooks"}: {Data: map[string]string{"pre-commit": "#!/bin/sh\nexit 0\n"}},
+				}
+				repo.Spec.Hooks = &svnv1alpha1.Hooks{ConfigMapName: "hooks"}
+				Expect(build().Hooks).To(BeEmpty())
+				Expect(build().Pending).To(BeFalse())
+				Expect(f.RepositoryErrors()).To(HaveKey("team_app"))
+			})
+		})
+	})
+
+	Describe("commit policy", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			}}
+			repo = &f.repos.Items[0]
+		})
+
+		It("converts the policy into the one for the built-in hook", func() {
+			maxFileSize := resource.MustParse("10Mi")
+			repo.Spec.CommitPolicy = &svnv1alpha1.CommitPolicy{
+				LogMessagePattern:    `^[A-Z]+-[0-9]+: `,
+				MinLogMessageLength:  10,
+				MaxFileSize:          &maxFileSize,
+				RejectCaseCollisions: true,
+				BlockedPaths:         []string{"*.exe"},
+			}
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(1))
+			Expect(repos[0].Pending).To(BeFalse())
+			Expect(repos[0].CommitPolicy).To(Equal(&svnconfig.CommitPolicy{
+				LogMessagePattern:    `^[A-Z]+-[0-9]+: `,
+				MinLogMessageLength:  10,
+				MaxFileSize:          10 * 1024 * 1024,
+				RejectCaseCollisions: true,
+				BlockedPaths:         []string{"*.exe"},
+			}))
+		})
+
+		It("leaves the repository untouched if the policy is invalid", func() {
+			repo.Spec.CommitPolicy = &svnv1alpha1.CommitPolicy{LogMessagePattern: `(`}
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(1))
+			Expect(repos[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()["app"]).To(MatchError(ContainSubstring("invalid logMessagePattern")))
+		})
+	})
+
+	Describe("quotas", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			}}
+			repo = &f.repos.Items[0]
+		})
+
+		Context("when nothing has quotas", func() {
+			It("does not report usage", func() {
+				status := &serverupdater.Status{Repositories: []serverupdater.RepositoryStatus{{Name: "app", Size: 100}}}
+				Expect(f.repositoryUsageOf(repo, status)).To(BeNil())
+			})
+		})
+
+		Context("when the repository and the server have quotas", func() {
+			BeforeEach(func() {
+				repo.Spec.Quota = &svnv1alpha1.Quota{MaxSize: resource.MustParse("1Ki")}
+				f.server.Spec.Quota = &svnv1alpha1.Quota{MaxSize: resource.MustParse("1Mi")}
+			})
+
+			It("passes the quotas to server-updater", func() {
+				g := f.BuildGenerator()
+				Expect(g.MaxTotalSize).To(Equal(int64(1 << 20)))
+				Expect(g.Repositories).To(HaveLen(1))
+				Expect(g.Repositories[0].MaxSize).To(Equal(int64(1 << 10)))
+			})
+
+			It("reports the usage against the quota of the repository", func() {
+				status := &serverupdater.Status{Repositories: []serverupdater.RepositoryStatus{{Name: "app", Size: 2048}}}
+				usage := f.repositoryUsageOf(repo, status)
+				Expect(usage).NotTo(BeNil())
+				Expect(usage.Size.Value()).To(Equal(int64(2048)))
+				Expect(usage.MaxSize.Value()).To(Equal(int64(1024)))
+				Expect(usage.Exceeded).To(BeTrue())
+			})
+
+			It("does not report usage of repositories that do not exist yet", func() {
+				Expect(f.repositoryUsageOf(repo, &serverupdater.Status{})).To(BeNil())
+			})
+		})
+	})
+
+	Describe("repositoryInfoOf", func() {
+		It("converts the state reported by server-updater", func() {
+			info := repositoryInfoOf(&serverupdater.RepositoryStatus{
+				Name:              "app",
+				Size:              2048,
+				Revision:          42,
+				UUID:              "6b3c1e2a-0000-4000-8000-000000000000",
+				FSType:            "fsfs",
+				FSFormat:          8,
+				LastChangedAuthor: "alice",
+				LastChangedTime:   "2021-05-01T03:34:56Z",
+			})
+			Expect(info.Revision).To(Equal(int64(42)))
+			Expect(info.UUID).To(Equal("6b3c1e2a-0000-4000-8000-000000000000"))
+			Expect(info.Size.String()).To(Equal("2Ki"))
+			Expect(info.FSType).To(Equal("fsfs"))
+			Expect(info.FSFormat).To(Equal(int32(8)))
+			Expect(info.LastChangedAuthor).To(Equal("alice"))
+			Expect(info.LastChangedTime.UTC()).To(Equal(time.Date(2021, 5, 1, 3, 34, 56, 0, time.UTC)))
+		})
+
+		It("returns nil if the repository does not exist", func() {
+			Expect(repositoryInfoOf(nil)).To(BeNil())
+		})
+
+		It("ignores differences in representations", func() {
+			a := repositoryInfoOf(&serverupdater.RepositoryStatus{Size: 2048, LastChangedTime: "2021-05-01T03:34:56Z"})
+			b := a.DeepCopy()
+			size := resource.MustParse("2048")
+			b.Size = &size
+			Expect(repositoryInfoEqual(a, b)).To(BeTrue())
+			b.Revision = 1
+			Expect(repositoryInfoEqual(a, b)).To(BeFalse())
+		})
+	})
+
+	Describe("read-only repositories", func() {
+		It("passes the message to server-updater", func() {
+			f := newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "frozen"},
+					Spec:       svnv1alpha1.SVNRepositorySpec{ReadOnly: true, ReadOnlyMessage: "migrating"},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "active"},
+					Spec:       svnv1alpha1.SVNRepositorySpec{ReadOnlyMessage: "ignored"},
+				},
+			}
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(2))
+			Expect(repos[0].ReadOnly).To(Equal(&svnconfig.ReadOnly{Message: "migrating"}))
+			Expect(repos[1].ReadOnly).To(BeNil())
+		})
+
+		It("puts repositories being restored in maintenance", func() {
+			f := newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hoge"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "fuga"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "piyo"}},
+			}
+			restore := func(name, repo, phase string) svnv1alpha1.SVNRestore {
+				return svnv1alpha1.SVNRestore{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+					Spec:       svnv1alpha1.SVNRestoreSpec{BackupName: "nightly", Repository: repo},
+					Status:     svnv1alpha1.SVNRestoreStatus{Phase: phase},
+				}
+			}
+			f.restores.Items = []svnv1alpha1.SVNRestore{
+				restore("running", "hoge", svnv1alpha1.RestorePhaseRunning),
+				restore("finished", "fuga", svnv1alpha1.RestorePhaseSucceeded),
+				restore("qualified", "other_piyo", svnv1alpha1.RestorePhaseRunning),
+			}
+			repos := f.BuildRepositories()
+			Expect(repos).To(HaveLen(3))
+			Expect(repos[0].ReadOnly).To(Equal(&svnconfig.ReadOnly{Message: `Under maintenance: restoring from SVNBackup "nightly"`}))
+			Expect(repos[1].ReadOnly).To(BeNil())
+			Expect(repos[2].ReadOnly).NotTo(BeNil())
+		})
+	})
+
+	Describe("creation options", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "repo"}}}
+			repo = &f.repos.Items[0]
+		})
+
+		It("creates repositories with the defaults if nothing is specified", func() {
+			Expect(f.BuildRepositories()[0].Create).To(BeNil())
+		})
+
+		It("passes the options to server-updater", func() {
+			disabled, compress := false, true
+			packSize := resource.MustParse("64Ki")
+			repo.Spec.FSType = svnv1alpha1.FSTypeFSFS
+			repo.Spec.CompatibleVersion = "1.8"
+			repo.Spec.UUID = "6B3C1E2A-0000-4000-8000-000000000000"
+			repo.Spec.FSFS = &svnv1alpha1.FSFSOptions{
+				EnableRepSharing: &disabled,
+				RevPropPacking:   &svnv1alpha1.RevPropPacking{PackSize: &packSize, Compress: &compress},
+			}
+			repos := f.BuildRepositories()
+			Expect(repos[0].Pending).To(BeFalse())
+			Expect(repos[0].Create).To(Equal(&svnconfig.CreateOptions{
+				FSType:            "fsfs",
+				CompatibleVersion: "1.8",
+				UUID:              "6b3c1e2a-0000-4000-8000-000000000000",
+				FSFS: &svnconfig.FSFSOptions{
+					EnableRepSharing:       &disabled,
+					RevPropPackSize:        64,
+					CompressPackedRevProps: &compress,
+				},
+			}))
+		})
+
+		It("reports FSFS options for other filesystems", func() {
+			repo.Spec.FSType = svnv1alpha1.FSTypeFSX
+			repo.Spec.FSFS = &svnv1alpha1.FSFSOptions{}
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("repo"))
+		})
+
+		It("reports pack sizes smaller than 1Ki", func() {
+			packSize := resource.MustParse("512")
+			repo.Spec.FSFS = &svnv1alpha1.FSFSOptions{RevPropPacking: &svnv1alpha1.RevPropPacking{PackSize: &packSize}}
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("repo"))
+		})
+	})
+
+	Describe("dump sources", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "legacy"},
+				Spec: svnv1alpha1.SVNRepositorySpec{
+					Source: &svnv1alpha1.RepositorySource{
+						Dump: &svnv1alpha1.DumpSource{
+							PersistentVolumeClaim: &svnv1alpha1.PersistentVolumeClaimDumpSource{
+								ClaimName: "migration",
+								Path:      "dumps/legacy.dump.gz",
+							},
+						},
+					},
+				},
+			}}
+			repo = &f.repos.Items[0]
+		})
+
+		It("loads the dump file from the mounted volume", func() {
+			volumes := f.SourceVolumes()
+			Expect(volumes).To(HaveLen(1))
+			Expect(volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("migration"))
+			repos := f.BuildRepositories()
+			Expect(repos[0].Pending).To(BeFalse())
+			Expect(repos[0].Source.Dump).To(Equal(&svnconfig.DumpSource{
+				Path:        VolumePathSources + "/" + volumes[0].Name + "/dumps/legacy.dump.gz",
+				Compression: svnconfig.CompressionAuto,
+			}))
+			Expect(f.NeedsUpdaterStatus()).To(BeTrue())
+		})
+
+		It("stops waiting for server-updater once the load succeeds", func() {
+			repo.Status.Load = &svnv1alpha1.LoadStatus{Phase: "Succeeded", LastLoadedRevision: 42}
+			Expect(f.NeedsUpdaterStatus()).To(BeFalse())
+		})
+
+		It("unmounts the volume once the load succeeds", func() {
+			repo.Status.Load = &svnv1alpha1.LoadStatus{Phase: "Failed", LastLoadedRevision: 42}
+			Expect(f.SourceVolumes()).To(HaveLen(1))
+			repo.Status.Load = &svnv1alpha1.LoadStatus{Phase: "Succeeded", LastLoadedRevision: 42}
+			Expect(f.SourceVolumes()).To(BeEmpty())
+		})
+
+		It("rejects repositories in other namespaces", func() {
+			repo.Namespace = "team"
+			Expect(f.SourceVolumes()).To(BeEmpty())
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("team_legacy"))
+		})
+
+		It("rejects sources used with an initial layout", func() {
+			repo.Spec.InitialLayout = &svnv1alpha1.InitialLayout{Preset: svnv1alpha1.LayoutPresetStandard}
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("legacy"))
+		})
+
+		It("mounts the volumes to the SVN server while keeping existing ones", func() {
+			ss := &appsv1.StatefulSet{}
+			ss.Spec.Template.Spec.Volumes = []corev1.Volume{
+				{Name: VolumeNameConfig},
+				{Name: VolumeNamePrefixSource + "stale"},
+			}
+			ss.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name:         ContainerNameSVN,
+				VolumeMounts: []corev1.VolumeMount{{Name: VolumeNamePrefixSource + "stale"}},
+			}}
+			volumes := f.SourceVolumes()
+			setSourceVolumes(ss, volumes)
+			Expect(ss.Spec.Template.Spec.Volumes).To(Equal([]corev1.Volume{{Name: VolumeNameConfig}, volumes[0]}))
+			Expect(ss.Spec.Template.Spec.Containers[0].VolumeMounts).To(Equal([]corev1.VolumeMount{{
+				Name:      volumes[0].Name,
+				MountPath: VolumePathSources + "/" + volumes[0].Name,
+				ReadOnly:  true,
+			}}))
+		})
+	})
+
+	Describe("mirror sources", func() {
+		var f *GeneratorFactory
+		var repo *svnv1alpha1.SVNRepository
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.repos.Items = []svnv1alpha1.SVNRepository{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "upstream"},
+				Spec: svnv1alpha1.SVNRepositorySpec{
+					Source: &svnv1alpha1.RepositorySource{
+						Mirror: &svnv1alpha1.MirrorSource{URL: "https://svn.example.com/repos/upstream"},
+					},
+				},
+			}}
+			repo = &f.repos.Items[0]
+		})
+
+		It("synchronizes anonymously at the default interval", func() {
+			repos := f.BuildRepositories()
+			Expect(repos[0].Pending).To(BeFalse())
+			Expect(repos[0].Source.Mirror).To(Equal(&svnconfig.MirrorSource{
+				URL:             "https://svn.example.com/repos/upstream",
+				IntervalSeconds: 300,
+			}))
+			Expect(f.SourceVolumes()).To(BeEmpty())
+		})
+
+		It("mounts the credentials Secret to the SVN server", func() {
+			repo.Spec.Source.Mirror.CredentialsSecret = &corev1.LocalObjectReference{Name: "upstream-credentials"}
+			repo.Spec.Source.Mirror.Interval = &metav1.Duration{Duration: 90 * time.Second}
+			volumes := f.SourceVolumes()
+			Expect(volumes).To(HaveLen(1))
+			Expect(volumes[0].Secret.SecretName).To(Equal("upstream-credentials"))
+			Expect(f.BuildRepositories()[0].Source.Mirror).To(Equal(&svnconfig.MirrorSource{
+				URL:             "https://svn.example.com/repos/upstream",
+				CredentialsDir:  VolumePathSources + "/" + volumes[0].Name,
+				IntervalSeconds: 90,
+			}))
+		})
+
+		It("waits for server-updater until the mirror is synchronized", func() {
+			Expect(f.NeedsUpdaterStatus()).To(BeTrue())
+			repo.Status.Mirror = &svnv1alpha1.MirrorStatus{LastSyncedRevision: 42}
+			Expect(f.NeedsUpdaterStatus()).To(BeFalse())
+		})
+
+		It("rejects credentials of repositories in other namespaces", func() {
+			repo.Namespace = "team"
+			repo.Spec.Source.Mirror.CredentialsSecret = &corev1.LocalObjectReference{Name: "upstream-credentials"}
+			Expect(f.SourceVolumes()).To(BeEmpty())
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("team_upstream"))
+		})
+
+		It("rejects credentials of svn URLs", func() {
+			repo.Spec.Source.Mirror.URL = "svn://svn.example.com/repos/upstream"
+			repo.Spec.Source.Mirror.CredentialsSecret = &corev1.LocalObjectReference{Name: "upstream-credentials"}
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("upstream"))
+		})
+
+		It("rejects mirrors used with dump files", func() {
+			repo.Spec.Source.Dump = &svnv1alpha1.DumpSource{
+				ConfigMap: &svnv1alpha1.ConfigMapDumpSource{Name: "dumps", Key: "upstream.dump"},
+			}
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("upstream"))
+		})
+
+		It("rejects unsupported URLs", func() {
+			repo.Spec.Source.Mirror.URL = "file:///svn/repos/other"
+			Expect(f.BuildRepositories()[0].Pending).To(BeTrue())
+			Expect(f.RepositoryErrors()).To(HaveKey("upstream"))
+		})
+
+		It("converts the status reported by server-updater", func() {
+			mirror := mirrorStatusOf(&serverupdater.MirrorStatus{
+				Name:               "upstream",
+				LastSyncedRevision: 42,
+				LastSyncTime:       "2021-05-01T12:00:00Z",
+				Message:            "svnsync synchronize: exit status 1",
+			})
+			Expect(mirror.LastSyncedRevision).To(Equal(int64(42)))
+			Expect(mirror.Message).To(Equal("svnsync synchronize: exit status 1"))
+			Expect(mirror.LastSyncTime.UTC()).To(Equal(time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)))
+			local := mirror.DeepCopy()
+			local.LastSyncTime.Time = local.LastSyncTime.Local()
+			Expect(mirrorStatusEqual(mirror, local)).To(BeTrue())
+		})
+	})
+
+	Describe("replicas", func() {
+		var f *GeneratorFactory
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Name = "svn"
+			f.server.Namespace = "default"
+		})
+
+		It("lets replicas read repositories on the primary", func() {
+			Expect(f.BuildGenerator().Replication).To(BeNil())
+			replicas := int32(3)
+			f.server.Spec.Replicas = &replicas
+			f.replication = &corev1.Secret{
+				Data: map[string][]byte{
+					svnconfig.MirrorCredentialsUsername: []byte(svnconfig.ReplicationUser),
+					svnconfig.MirrorCredentialsPassword: []byte("secret"),
+					SecretKeyEncryptedPassword:          []byte("$2a$10$hash"),
+				},
+			}
+			Expect(f.BuildGenerator().Replication).To(Equal(&svnconfig.Replication{
+				User:              svnconfig.ReplicationUser,
+				EncryptedPassword: "$2a$10$hash",
+				UpdaterURLs: []string{
+					"http://svn-1.svn.default.svc:8081",
+					"http://svn-2.svn.default.svc:8081",
+				},
+			}))
+		})
+
+		It("runs Pods other than the primary as replicas", func() {
+			r := &SVNServerReconciler{DefaultSVNServerImage: "svn:latest"}
+			replicas := int32(3)
+			f.server.Spec.Replicas = &replicas
+			ss := &appsv1.StatefulSet{}
+			r.overrideWithPodTemplate(f.server, ss)
+			Expect(*ss.Spec.Replicas).To(Equal(int32(3)))
+			Expect(ss.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: VolumeNameReplication,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "svn-replication"},
+				},
+			}))
+			container := ss.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(Equal([]corev1.EnvVar{{Name: EnvPrimaryHost, Value: "svn-0.svn.default.svc"}}))
+			Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      VolumeNameReplication,
+				MountPath: VolumePathReplication,
+				ReadOnly:  true,
+			}))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal(serverupdater.ReadyPath))
+			Expect(container.ReadinessProbe.HTTPGet.Port).To(Equal(intstr.FromInt(UpdaterPort)))
+
+			By("scaling down to the primary only")
+			replicas = 1
+			r.overrideWithPodTemplate(f.server, ss)
+			Expect(*ss.Spec.Replicas).To(Equal(int32(1)))
+			for _, v := range ss.Spec.Template.Spec.Volumes {
+				Expect(v.Name).NotTo(Equal(VolumeNameReplication))
+			}
+			container = ss.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(BeNil())
+			for _, m := range container.VolumeMounts {
+				Expect(m.Name).NotTo(Equal(VolumeNameReplication))
+			}
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/"))
+			Expect(container.ReadinessProbe.HTTPGet.Port).To(Equal(intstr.FromInt(80)))
+		})
+
+		It("keeps clients on the same Pod behind the load balancer", func() {
+			r := &SVNServerReconciler{Scheme: scheme.Scheme}
+			Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+			svc, err := r.loadBalancerServiceFor(f.server)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(svc.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityClientIP))
+		})
+
+		It("reports the lag of replicas behind the primary", func() {
+			primary := &serverupdater.Status{
+				Repositories: []serverupdater.RepositoryStatus{
+					{Name: "a", Revision: 10},
+					{Name: "b", Revision: 5},
+					{Name: "c", Revision: 3},
+				},
+			}
+			replica := &serverupdater.Status{
+				Repositories: []serverupdater.RepositoryStatus{
+					{Name: "a", Revision: 10},
+					{Name: "b", Revision: 2},
+				},
+				Mirrors: []serverupdater.MirrorStatus{
+					{Name: "a", LastSyncedRevision: 10, LastSyncTime: "2021-05-01T12:05:00Z"},
+					{Name: "b", LastSyncedRevision: 2, LastSyncTime: "2021-05-01T12:00:00Z"},
+				},
+			}
+			status := replicaStatusOf("svn-1", primary, replica)
+			Expect(status.Name).To(Equal("svn-1"))
+			Expect(*status.RevisionLag).To(Equal(int64(3)))
+			Expect(status.LaggingRepositories).To(Equal(int32(2)))
+			Expect(status.LastSyncTime.UTC()).To(Equal(time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)))
+			Expect(status.Message).To(BeEmpty())
+
+			local := status.DeepCopy()
+			local.LastSyncTime.Time = local.LastSyncTime.Local()
+			Expect(replicaStatusesEqual([]svnv1alpha1.ReplicaStatus{status}, []svnv1alpha1.ReplicaStatus{*local})).To(BeTrue())
+		})
+
+		It("does not report the lag while either of the servers is not available", func() {
+			status := replicaStatusOf("svn-1", nil, &serverupdater.Status{})
+			Expect(status.RevisionLag).To(BeNil())
+			Expect(status.Message).To(Equal("the primary is not available"))
+			status = replicaStatusOf("svn-1", &serverupdater.Status{}, nil)
+			Expect(status.RevisionLag).To(BeNil())
+			Expect(status.Message).To(Equal("the replica is not available"))
+		})
+	})
+
+	Describe("passwords from Secrets", func() {
+		var f *GeneratorFactory
+		var user *svnv1alpha1.SVNUser
+		key := passwordKey{
+			NamespacedName: types.NamespacedName{Namespace: "default", Name: "alice-password"},
+			Key:            corev1.BasicAuthPasswordKey,
+		}
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Namespace = "default"
+			f.users.Items = []svnv1alpha1.SVNUser{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "alice"},
+				Spec: svnv1alpha1.SVNUserSpec{
+					PasswordSecretRef: &svnv1alpha1.PasswordSecretRef{Name: "alice-password"},
+				},
+			}}
+			user = &f.users.Items[0]
+		})
+
+		It("uses the hash of the password in the Secret", func() {
+			f.passwords = map[passwordKey]string{key: "$2a$10$hash"}
+			Expect(f.BuildUsers()).To(Equal([]svnconfig.User{{Name: "alice", EncryptedPassword: "$2a$10$hash"}}))
+			Expect(f.UserErrors()).To(BeEmpty())
+		})
+
+		It("excludes users whose passwords are not available", func() {
+			Expect(f.BuildUsers()).To(BeEmpty())
+			Expect(f.UserErrors()).To(HaveKey("alice"))
+		})
+
+		It("rejects users with both encryptedPassword and passwordSecretRef", func() {
+			f.passwords = map[passwordKey]string{key: "$2a$10$hash"}
+			user.Spec.EncryptedPassword = "$2a$10$other"
+			Expect(f.BuildUsers()).To(BeEmpty())
+			Expect(f.UserErrors()).To(HaveKey("alice"))
+		})
+
+		It("rejects users without passwords", func() {
+			user.Spec.PasswordSecretRef = nil
+			Expect(f.BuildUsers()).To(BeEmpty())
+			Expect(f.UserErrors()).To(HaveKey("alice"))
+		})
+
+		It("encrypts the password once for each version of the Secret", func() {
+			r := &SVNServerReconciler{}
+			hash, err := r.passwordHashOf(key, "1", []byte("secret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret"))).To(Succeed())
+			Expect(r.passwordHashOf(key, "1", []byte("secret"))).To(Equal(hash))
+
+			updated, err := r.passwordHashOf(key, "2", []byte("changed"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bcrypt.CompareHashAndPassword([]byte(updated), []byte("changed"))).To(Succeed())
+		})
+	})
+
+	Describe("credentials", func() {
+		var f *GeneratorFactory
+		BeforeEach(func() {
+			f = newFactory()
+			f.server.Name = "svn"
+			f.server.Namespace = "default"
+			f.users.Items = []svnv1alpha1.SVNUser{newUser("alice", nil)}
+		})
+
+		It("keeps the users and their permissions in the Secret", func() {
+			Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+			r := &SVNServerReconciler{Scheme: scheme.Scheme}
+			secret, err := r.authSecretFor(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Name).To(Equal("svn-auth"))
+			Expect(string(secret.Data[SecretKeyAuthUserFile])).To(ContainSubstring("alice:$2a$10$hash"))
+			Expect(secret.Data).To(HaveKey(SecretKeyAuthzSVNAccessFile))
+
+			cm, err := r.configMapFor(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveLen(2))
+			Expect(cm.Data).NotTo(HaveKey(SecretKeyAuthUserFile))
+			Expect(cm.Data[ConfigMapKeyApacheConfig]).To(ContainSubstring("AuthUserFile " + VolumePathAuth + SecretKeyAuthUserFile))
+		})
+
+		It("mounts the Secret to StatefulSets created before it was introduced", func() {
+			r := &SVNServerReconciler{DefaultSVNServerImage: "svn:latest"}
+			ss := &appsv1.StatefulSet{}
+			r.overrideWithPodTemplate(f.server, ss)
+			ss.Spec.Template.Spec.Volumes = ss.Spec.Template.Spec.Volumes[:1]
+			ss.Spec.Template.Spec.Containers[0].VolumeMounts = ss.Spec.Template.Spec.Containers[0].VolumeMounts[:2]
+
+			r.overrideWithPodTemplate(f.server, ss)
+			Expect(ss.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: VolumeNameAuth,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "svn-auth"},
+				},
+			}))
+			Expect(ss.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      VolumeNameAuth,
+				MountPath: VolumePathAuth,
+				ReadOnly:  true,
+			}))
+
+			By("keeping the StatefulSet as is once it mounts the Secret")
+			// The API server fills the default mode in the volumes.
+			defaultMode := corev1.SecretVolumeSourceDefaultMode
+			for i := range ss.Spec.Template.Spec.Volumes {
+				if v := &ss.Spec.Template.Spec.Volumes[i]; v.Secret != nil {
+					v.Secret.DefaultMode = &defaultMode
+				}
+			}
+			desired := ss.DeepCopy()
+			r.overrideWithPodTemplate(f.server, desired)
+			Expect(desired).To(Equal(ss))
+		})
+
+		It("mounts the token to read the status of server-updater", func() {
+			Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+			r := &SVNServerReconciler{Scheme: scheme.Scheme, DefaultSVNServerImage: "svn:latest"}
+			secret, err := r.updaterSecretFor(f.server)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Name).To(Equal("svn-updater"))
+			Expect(secret.Data[SecretKeyUpdaterToken]).To(HaveLen(48))
+
+			ss := &appsv1.StatefulSet{}
+			r.overrideWithPodTemplate(f.server, ss)
+			Expect(ss.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: VolumeNameUpdater,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "svn-updater"},
+				},
+			}))
+			Expect(ss.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      VolumeNameUpdater,
+				MountPath: VolumePathUpdater,
+				ReadOnly:  true,
+			}))
+		})
+
+		It("keeps the credentials in the ConfigMap until every Pod mounts the Secret", func() {
+			Expect(svnv1alpha1.AddToScheme(scheme.Scheme)).To(Succeed())
+			r := &SVNServerReconciler{Scheme: scheme.Scheme, DefaultSVNServerImage: "svn:latest"}
+			ss := &appsv1.StatefulSet{}
+			r.overrideWithPodTemplate(f.server, ss)
+			ss.Generation = 2
+			ss.Status = appsv1.StatefulSetStatus{ObservedGeneration: 2, CurrentRevision: "svn-1", UpdateRevision: "svn-2"}
+			Expect(authSecretMounted(ss)).To(BeFalse())
+
+			f.legacyAuth = true
+			cm, err := r.configMapFor(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data[SecretKeyAuthUserFile]).To(ContainSubstring("alice:$2a$10$hash"))
+			Expect(cm.Data).To(HaveKey(SecretKeyAuthzSVNAccessFile))
+			Expect(cm.Data[ConfigMapKeyApacheConfig]).To(ContainSubstring("AuthUserFile " + VolumePathConfig + SecretKeyAuthUserFile))
+
+			By("finishing the rollout")
+			ss.Status.CurrentRevision = "svn-2"
+			Expect(authSecretMounted(ss)).To(BeTrue())
+		})
+	})
+
+	Describe("inactive users", func() {
+		var f *GeneratorFactory
+		now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+		BeforeEach(func() {
+			f = newFactory(newGroup("devs"))
+			f.now = now
+			f.users.Items = []svnv1alpha1.SVNUser{
+				newUser("alice", nil, "devs"),
+				newUser("bob", nil, "devs"),
+				newUser("carol", nil, "devs"),
+			}
+		})
+		namesOf := func(users []svnconfig.User) []string {
+			names := []string{}
+			for _, u := range users {
+				names = append(names, u.Name)
+			}
+			return names
+		}
+
+		It("leaves disabled and expired users out of AuthUserFile and groups", func() {
+			f.users.Items[0].Spec.Disabled = true
+			f.users.Items[1].Spec.ExpiresAt = &metav1.Time{Time: now}
+			f.users.Items[2].Spec.ExpiresAt = &metav1.Time{Time: now.Add(time.Hour)}
+			Expect(namesOf(f.BuildUsers())).To(Equal([]string{"carol"}))
+			Expect(usersOf(f)).To(Equal(map[string][]string{"devs": {"carol"}}))
+			Expect(f.inactiveReasonOf(&f.users.Items[0])).To(Equal("disabled"))
+			Expect(f.inactiveReasonOf(&f.users.Items[1])).To(Equal("expired at 2021-05-01T12:00:00Z"))
+			Expect(f.inactiveReasonOf(&f.users.Items[2])).To(BeEmpty())
+		})
+
+		It("leaves the direct permissions of disabled and expired users out of the authz file", func() {
+			f.repos.Items = []svnv1alpha1.SVNRepository{{ObjectMeta: metav1.ObjectMeta{Name: "app"}}}
+			for i := range f.users.Items {
+				f.users.Items[i].Spec.Permissions = []svnv1alpha1.Permission{{
+					AllRepositories: true,
+					Permission:      svnv1alpha1.PermissionRW,
+				}}
+			}
+			f.users.Items[0].Spec.Disabled = true
+			f.users.Items[1].Spec.ExpiresAt = &metav1.Time{Time: now}
+			Expect(f.BuildRepositories()[0].Permissions).To(Equal([]svnconfig.Permission{
+				{User: "carol", Permission: "rw", Path: "/"},
+			}))
+		})
+
+		It("returns the time when the next user expires", func() {
+			Expect(f.NextExpiry()).To(BeNil())
+			f.users.Items[0].Spec.ExpiresAt = &metav1.Time{Time: now.Add(-time.Hour)}
+			f.users.Items[1].Spec.ExpiresAt = &metav1.Time{Time: now.Add(2 * time.Hour)}
+			f.users.Items[2].Spec.ExpiresAt = &metav1.Time{Time: now.Add(time.Hour)}
+			Expect(*f.NextExpiry()).To(Equal(now.Add(time.Hour)))
+			f.users.Items[2].Spec.Disabled = true
+			Expect(*f.NextExpiry()).To(Equal(now.Add(2 * time.Hour)))
+		})
+	})
+})
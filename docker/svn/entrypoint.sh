@@ -12,7 +12,16 @@ export SERVER_NAME="test.example.com"
 mkdir -p /svn
 chown -R www-data:www-data /svn
 
+updater_args=()
+apache_args=()
+# Every Pod other than the primary itself is a read-only replica of the primary.
+if [ -n "${SVN_PRIMARY_HOST}" ] && [ "$(hostname)" != "${SVN_PRIMARY_HOST%%.*}" ]; then
+    export SVN_MASTER_URI="http://${SVN_PRIMARY_HOST}/repos/"
+    updater_args+=(-primary-host "${SVN_PRIMARY_HOST}")
+    apache_args+=(-DSVNReplica)
+fi
+
 sudo -u www-data -g www-data mkdir -p /svn/repos
-sudo -u www-data -g www-data /work/server-updater &
+sudo -u www-data -g www-data /work/server-updater "${updater_args[@]}" &
 
-exec apache2 -DFOREGROUND "$@"
+exec apache2 -DFOREGROUND "${apache_args[@]}" "$@"
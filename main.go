@@ -19,7 +19,9 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
 	"os"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -33,6 +35,7 @@ import (
 
 	svnv1alpha1 "github.com/genkami/svn-operator/api/v1alpha1"
 	"github.com/genkami/svn-operator/controllers"
+	"github.com/genkami/svn-operator/pkg/serverupdater"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -92,10 +95,37 @@ func main() {
 		Log:                   ctrl.Log.WithName("controllers").WithName("SVNServer"),
 		Scheme:                mgr.GetScheme(),
 		DefaultSVNServerImage: defaultImage,
+		UpdaterClient:         &serverupdater.Client{HTTPClient: &http.Client{Timeout: 10 * time.Second}},
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SVNServer")
 		os.Exit(1)
 	}
+	if err = (&controllers.SVNBackupReconciler{
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("SVNBackup"),
+		Scheme:                mgr.GetScheme(),
+		DefaultSVNServerImage: defaultImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SVNBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.SVNBackupScheduleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("SVNBackupSchedule"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SVNBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.SVNRestoreReconciler{
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("SVNRestore"),
+		Scheme:                mgr.GetScheme(),
+		DefaultSVNServerImage: defaultImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SVNRestore")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
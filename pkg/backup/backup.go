@@ -0,0 +1,295 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backup backs up SVN repositories into a directory or an S3-compatible bucket and restores them.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Here is a list of methods to back up repositories.
+const (
+	// MethodDump writes the output of `svnadmin dump` compressed with gzip.
+	MethodDump = "Dump"
+	// MethodHotcopy copies repositories with `svnadmin hotcopy`.
+	MethodHotcopy = "Hotcopy"
+)
+
+// ResultFile is the name of the file that the result is written to in the destination.
+const ResultFile = "backup.json"
+
+// Result is what a backup has captured.
+type Result struct {
+	Repositories []RepositoryResult `json:"repositories"`
+}
+
+// RepositoryResult is the range of revisions of a repository captured by a backup.
+type RepositoryResult struct {
+	Name string `json:"name"`
+	// From is the first revision in the backup.
+	// It is greater than To if an incremental backup has no new revisions.
+	From int64 `json:"from"`
+	// To is the last revision in the backup.
+	To int64 `json:"to"`
+	// File is a path to the backup of the repository relative to the destination.
+	// It is empty if there is nothing to back up.
+	File string `json:"file,omitempty"`
+}
+
+// Backup backs up repositories.
+type Backup struct {
+	// SvnAdmin is a path to the `svnadmin` command.
+	SvnAdmin string
+	// SvnLook is a path to the `svnlook` command.
+	SvnLook string
+
+	// ReposDir is a path to a directory that SVN repositories resides in.
+	ReposDir string
+	// Dest is a path to a directory to store the backup in. It is ignored if Store is set.
+	Dest string
+	// Store is where the backup is stored. If nil, the backup is stored in Dest.
+	// Only MethodDump is available with stores other than directories, since hotcopies are directories.
+	Store Store
+
+	// Method is either MethodDump or MethodHotcopy.
+	Method string
+	// Repository is the name of the repository to back up. All repositories are backed up if empty.
+	Repository string
+	// Since is the last revisions captured by the previous backup, keyed by repository names.
+	// If not nil, only revisions after them are dumped incrementally.
+	Since map[string]int64
+}
+
+// Run backs up repositories and writes the result into ResultFile in the destination.
+func (b *Backup) Run() (*Result, error) {
+	if b.Method != MethodDump && b.Method != MethodHotcopy {
+		return nil, fmt.Errorf("unknown method: %q", b.Method)
+	}
+	if b.Since != nil && b.Method != MethodDump {
+		return nil, fmt.Errorf("incremental backups are only available with %s", MethodDump)
+	}
+	names, err := b.repositories()
+	if err != nil {
+		return nil, err
+	}
+	store := b.Store
+	if store == nil {
+		if err := os.MkdirAll(b.Dest, 0755); err != nil {
+			return nil, err
+		}
+		store = &DirStore{Dir: b.Dest}
+	} else if b.Method == MethodHotcopy {
+		return nil, fmt.Errorf("%s is only available with directories", MethodHotcopy)
+	}
+	result := &Result{Repositories: []RepositoryResult{}}
+	for _, name := range names {
+		var r *RepositoryResult
+		if b.Method == MethodDump {
+			r, err = b.dump(store, name)
+		} else {
+			r, err = b.hotcopy(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result.Repositories = append(result.Repositories, *r)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(ResultFile, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// repositories returns the names of the repositories to back up.
+func (b *Backup) repositories() ([]string, error) {
+	if b.Repository != "" {
+		if b.Repository != filepath.Base(b.Repository) {
+			return nil, fmt.Errorf("invalid repository name: %q", b.Repository)
+		}
+		if _, err := os.Stat(filepath.Join(b.ReposDir, b.Repository)); err != nil {
+			return nil, err
+		}
+		return []string{b.Repository}, nil
+	}
+	entries, err := ioutil.ReadDir(b.ReposDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dump streams the output of `svnadmin dump` to the store.
+func (b *Backup) dump(store Store, name string) (*RepositoryResult, error) {
+	repo := filepath.Join(b.ReposDir, name)
+	// The range is fixed beforehand so that the result matches the dump even if revisions are committed meanwhile.
+	youngest, err := b.youngestRevision(repo)
+	if err != nil {
+		return nil, err
+	}
+	result := &RepositoryResult{Name: name, From: 0, To: youngest}
+	args := []string{"dump", "--quiet"}
+	if since, ok := b.Since[name]; ok {
+		result.From = since + 1
+		if result.From > youngest {
+			return result, nil
+		}
+		args = append(args, "--incremental")
+	}
+	args = append(args, "--revision", fmt.Sprintf("%d:%d", result.From, result.To), repo)
+	result.File = name + ".dump.gz"
+
+	cmd := exec.Command(b.SvnAdmin, args...)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		gw := gzip.NewWriter(pw)
+		_, err := io.Copy(gw, stdout)
+		if err == nil {
+			err = gw.Close()
+		}
+		// The store must not see the end of the file until svnadmin succeeds, so that it stores nothing on failures.
+		if waitErr := cmd.Wait(); waitErr != nil {
+			err = fmt.Errorf("svnadmin dump: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+		}
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	writeErr := store.Put(result.File, pr)
+	// Drain the rest of the output so that svnadmin does not block forever on failures.
+	_, _ = io.Copy(ioutil.Discard, pr)
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	return result, nil
+}
+
+func (b *Backup) hotcopy(name string) (*RepositoryResult, error) {
+	src := filepath.Join(b.ReposDir, name)
+	dest := filepath.Join(b.Dest, name)
+	// A partial copy left by a failed backup cannot be resumed.
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(b.SvnAdmin, "hotcopy", src, dest).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("svnadmin hotcopy: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// The copy may contain revisions committed after the backup started.
+	youngest, err := b.youngestRevision(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &RepositoryResult{Name: name, From: 0, To: youngest, File: name}, nil
+}
+
+func (b *Backup) youngestRevision(repo string) (int64, error) {
+	return youngestRevision(b.SvnLook, repo)
+}
+
+func youngestRevision(svnLook, repo string) (int64, error) {
+	cmd := exec.Command(svnLook, "youngest", repo)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("svnlook youngest: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// writeFileAtomically writes the content of r into path, so that a broken file is never left at path.
+func writeFileAtomically(path string, r io.Reader) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// FormatSince formats the revisions for incremental backups, which is parsed by ParseSince.
+func FormatSince(since map[string]int64) string {
+	items := make([]string, 0, len(since))
+	for name, rev := range since {
+		items = append(items, fmt.Sprintf("%s=%d", name, rev))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// ParseSince parses the revisions formatted by FormatSince (e.g. `repo1=12,repo2=30`).
+func ParseSince(s string) (map[string]int64, error) {
+	since := map[string]int64{}
+	if s == "" {
+		return since, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid revision: %q", item)
+		}
+		rev, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil || rev < 0 {
+			return nil, fmt.Errorf("invalid revision: %q", item)
+		}
+		since[kv[0]] = rev
+	}
+	return since, nil
+}
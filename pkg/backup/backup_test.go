@@ -0,0 +1,192 @@
+package backup_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/genkami/svn-operator/pkg/backup"
+	"github.com/genkami/svn-operator/pkg/s3"
+	"github.com/genkami/svn-operator/pkg/s3/s3test"
+)
+
+var _ = Describe("Backup", func() {
+	var tmpDir string
+	var b *backup.Backup
+	writeScript := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755)).To(Succeed())
+		return path
+	}
+	mkRepo := func(name string, youngest string) {
+		repo := filepath.Join(b.ReposDir, name)
+		Expect(os.MkdirAll(repo, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(repo, "youngest"), []byte(youngest+"\n"), 0644)).To(Succeed())
+	}
+	readDump := func(name string) string {
+		f, err := os.Open(filepath.Join(b.Dest, name))
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		r, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+		content, err := ioutil.ReadAll(r)
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "backup")
+		Expect(err).NotTo(HaveOccurred())
+		b = &backup.Backup{
+			SvnAdmin: writeScript("svnadmin", `
+case "$1" in
+  dump) echo "$@";;
+  hotcopy) cp -r "$2" "$3";;
+esac
+`),
+			SvnLook:  writeScript("svnlook", `cat "$2/youngest"`),
+			ReposDir: filepath.Join(tmpDir, "repos"),
+			Dest:     filepath.Join(tmpDir, "backups", "daily"),
+			Method:   backup.MethodDump,
+		}
+		mkRepo("hoge", "3")
+		mkRepo("fuga", "5")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("dumps all repositories", func() {
+		result, err := b.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Repositories).To(Equal([]backup.RepositoryResult{
+			{Name: "fuga", From: 0, To: 5, File: "fuga.dump.gz"},
+			{Name: "hoge", From: 0, To: 3, File: "hoge.dump.gz"},
+		}))
+		Expect(readDump("hoge.dump.gz")).To(Equal("dump --quiet --revision 0:3 " + filepath.Join(b.ReposDir, "hoge") + "\n"))
+
+		content, err := ioutil.ReadFile(filepath.Join(b.Dest, backup.ResultFile))
+		Expect(err).NotTo(HaveOccurred())
+		var saved backup.Result
+		Expect(json.Unmarshal(content, &saved)).To(Succeed())
+		Expect(&saved).To(Equal(result))
+	})
+
+	It("dumps only revisions after the previous backup", func() {
+		b.Repository = "hoge"
+		b.Since = map[string]int64{"hoge": 1}
+		result, err := b.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Repositories).To(Equal([]backup.RepositoryResult{{Name: "hoge", From: 2, To: 3, File: "hoge.dump.gz"}}))
+		Expect(readDump("hoge.dump.gz")).To(Equal("dump --quiet --incremental --revision 2:3 " + filepath.Join(b.ReposDir, "hoge") + "\n"))
+	})
+
+	It("writes nothing if there are no new revisions", func() {
+		b.Since = map[string]int64{"fuga": 5, "hoge": 3}
+		result, err := b.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Repositories).To(Equal([]backup.RepositoryResult{
+			{Name: "fuga", From: 6, To: 5},
+			{Name: "hoge", From: 4, To: 3},
+		}))
+		Expect(filepath.Join(b.Dest, "hoge.dump.gz")).NotTo(BeAnExistingFile())
+	})
+
+	It("copies repositories with hotcopy", func() {
+		b.Method = backup.MethodHotcopy
+		result, err := b.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Repositories).To(ContainElement(backup.RepositoryResult{Name: "hoge", From: 0, To: 3, File: "hoge"}))
+		Expect(filepath.Join(b.Dest, "hoge", "youngest")).To(BeAnExistingFile())
+	})
+
+	It("fails if svnadmin fails", func() {
+		b.SvnAdmin = writeScript("svnadmin", `echo broken >&2; exit 1`)
+		_, err := b.Run()
+		Expect(err).To(MatchError(ContainSubstring("broken")))
+		Expect(filepath.Join(b.Dest, backup.ResultFile)).NotTo(BeAnExistingFile())
+	})
+
+	It("fails if the repository does not exist", func() {
+		b.Repository = "piyo"
+		_, err := b.Run()
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with an S3-compatible bucket", func() {
+		var server *s3test.Server
+		BeforeEach(func() {
+			server = s3test.NewServer("minio")
+			server.CreateBucket("backups")
+			b.Store = &backup.S3Store{
+				Client: &s3.Client{Endpoint: server.URL, AccessKeyID: "minio", SecretAccessKey: "minio123", PartSize: s3.MinPartSize},
+				Bucket: "backups",
+				Prefix: "daily/2021-05-01",
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("streams dumps to the bucket", func() {
+			_, err := b.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.Keys("backups")).To(Equal([]string{
+				"daily/2021-05-01/backup.json",
+				"daily/2021-05-01/fuga.dump.gz",
+				"daily/2021-05-01/hoge.dump.gz",
+			}))
+			data, _ := server.Object("backups", "daily/2021-05-01/hoge.dump.gz")
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(r)).To(ContainSubstring("--revision 0:3"))
+			Expect(b.Dest).NotTo(BeADirectory())
+		})
+
+		It("uploads nothing if svnadmin fails", func() {
+			b.SvnAdmin = writeScript("svnadmin", `echo partial; echo broken >&2; exit 1`)
+			_, err := b.Run()
+			Expect(err).To(MatchError(ContainSubstring("broken")))
+			Expect(server.Keys("backups")).To(BeEmpty())
+			Expect(server.Uploads()).To(BeZero())
+		})
+
+		It("rejects hotcopies", func() {
+			b.Method = backup.MethodHotcopy
+			_, err := b.Run()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("removes only the files of the backup", func() {
+			server.PutObject("backups", "daily/2021-05-01/hoge.dump.gz", []byte("hoge"))
+			server.PutObject("backups", "daily/2021-05-01-2/hoge.dump.gz", []byte("hoge"))
+			Expect(b.Store.RemoveAll()).To(Succeed())
+			Expect(server.Keys("backups")).To(Equal([]string{"daily/2021-05-01-2/hoge.dump.gz"}))
+		})
+	})
+
+	Describe("ParseSince", func() {
+		It("parses revisions formatted by FormatSince", func() {
+			since := map[string]int64{"hoge": 3, "ns_fuga": 0}
+			Expect(backup.FormatSince(since)).To(Equal("hoge=3,ns_fuga=0"))
+			Expect(backup.ParseSince(backup.FormatSince(since))).To(Equal(since))
+			Expect(backup.ParseSince("")).To(BeEmpty())
+		})
+
+		It("rejects invalid revisions", func() {
+			for _, s := range []string{"hoge", "hoge=x", "=1", "hoge=-1"} {
+				_, err := backup.ParseSince(s)
+				Expect(err).To(HaveOccurred(), s)
+			}
+		})
+	})
+})
@@ -0,0 +1,283 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// Step is a backup of a repository applied in order to restore the repository.
+type Step struct {
+	// Method is the method of the backup.
+	Method string
+	// Path is a path to the copy of the repository for MethodHotcopy, or to the dump file in Restore.Store for MethodDump.
+	Path string
+}
+
+// RestoreResult is the result of a restore.
+type RestoreResult struct {
+	// Revision is the youngest revision of the restored repository.
+	Revision int64 `json:"revision"`
+	// Verified is true if `svnadmin verify` has passed.
+	Verified bool `json:"verified"`
+	// VerifyMessage is the output of `svnadmin verify` if it has failed.
+	VerifyMessage string `json:"verifyMessage,omitempty"`
+	// Archived is a path that the replaced repository has been moved to.
+	Archived string `json:"archived,omitempty"`
+	// Error is the reason of the failure.
+	Error string `json:"error,omitempty"`
+}
+
+// maxVerifyMessageLength keeps the result small enough to fit in the termination log of the container.
+const maxVerifyMessageLength = 1024
+
+// Restore restores a repository from backups.
+type Restore struct {
+	// SvnAdmin is a path to the `svnadmin` command.
+	SvnAdmin string
+	// SvnLook is a path to the `svnlook` command.
+	SvnLook string
+
+	// ReposDir is a path to a directory that SVN repositories resides in.
+	ReposDir string
+	// TrashDir is a path to a directory that replaced repositories are moved to.
+	TrashDir string
+	// StagingDir is a path to a directory that repositories are restored in before they are moved to ReposDir.
+	StagingDir string
+
+	// Repository is the name of the repository to restore.
+	Repository string
+	// Steps is a list of backups to apply. Only the first one can be a hotcopy.
+	Steps []Step
+	// Store is where dump files are read from. Paths of dump files are relative to it.
+	// If nil, they are read from the local file system.
+	Store Store
+
+	// FSType is passed to `svnadmin create --fs-type` when the repository is restored from dump files.
+	FSType string
+	// CompatibleVersion is passed to `svnadmin create --compatible-version` when the repository is restored from dump files.
+	CompatibleVersion string
+
+	// MaintenanceTimeout is how long to wait for the existing repository to become read-only before it is replaced.
+	// If zero, the repository is replaced without waiting.
+	MaintenanceTimeout time.Duration
+	// PollInterval is an interval to check whether the existing repository has become read-only.
+	PollInterval time.Duration
+}
+
+// Run restores the repository in StagingDir, verifies it, and then replaces the repository in ReposDir with it.
+// The result is returned even on failures as long as there is something to report.
+func (r *Restore) Run() (*RestoreResult, error) {
+	if r.Repository == "" || filepath.Base(r.Repository) != r.Repository {
+		return nil, fmt.Errorf("invalid repository name: %q", r.Repository)
+	}
+	if len(r.Steps) == 0 {
+		return nil, fmt.Errorf("no backups to restore from")
+	}
+	if err := os.MkdirAll(r.StagingDir, 0755); err != nil {
+		return nil, err
+	}
+	staging := filepath.Join(r.StagingDir, r.Repository)
+	// Anything left by a failed restore is useless.
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	for i, step := range r.Steps {
+		if err := r.apply(staging, step, i == 0); err != nil {
+			return nil, fmt.Errorf("%s: %w", step.Path, err)
+		}
+	}
+
+	result := &RestoreResult{}
+	youngest, err := youngestRevision(r.SvnLook, staging)
+	if err != nil {
+		return nil, err
+	}
+	result.Revision = youngest
+	if out, err := exec.Command(r.SvnAdmin, "verify", "--quiet", staging).CombinedOutput(); err != nil {
+		result.VerifyMessage = truncate(strings.TrimSpace(string(out)), maxVerifyMessageLength)
+		return result, fmt.Errorf("svnadmin verify: %w", err)
+	}
+	result.Verified = true
+
+	dest := filepath.Join(r.ReposDir, r.Repository)
+	if fileExists(dest) {
+		if err := r.waitForMaintenance(dest); err != nil {
+			return result, err
+		}
+		// The hooks keep the restored repository read-only until the maintenance is over,
+		// and are kept up to date by server-updater after that.
+		if err := copyHooks(dest, staging); err != nil {
+			return result, err
+		}
+		if err := os.MkdirAll(r.TrashDir, 0755); err != nil {
+			return result, err
+		}
+		archived := filepath.Join(r.TrashDir, fmt.Sprintf("%s-%s", r.Repository, time.Now().UTC().Format("20060102T150405Z")))
+		if err := os.Rename(dest, archived); err != nil {
+			return result, err
+		}
+		result.Archived = archived
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// apply applies the backup to the staging repository.
+func (r *Restore) apply(staging string, step Step, first bool) error {
+	switch step.Method {
+	case MethodHotcopy:
+		if !first {
+			return fmt.Errorf("only the first backup can be a hotcopy")
+		}
+		out, err := exec.Command(r.SvnAdmin, "hotcopy", step.Path, staging).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("svnadmin hotcopy: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	case MethodDump:
+		if first {
+			args := []string{"create"}
+			if r.FSType != "" {
+				args = append(args, "--fs-type", r.FSType)
+			}
+			if r.CompatibleVersion != "" {
+				args = append(args, "--compatible-version", r.CompatibleVersion)
+			}
+			args = append(args, staging)
+			if out, err := exec.Command(r.SvnAdmin, args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("svnadmin create: %w: %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+		return r.load(staging, step.Path)
+	default:
+		return fmt.Errorf("unknown method: %q", step.Method)
+	}
+}
+
+// load loads the dump file compressed with gzip into the repository.
+func (r *Restore) load(repo, dump string) error {
+	store := r.Store
+	if store == nil {
+		store = &DirStore{}
+	}
+	f, err := store.Open(dump)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	cmd := exec.Command(r.SvnAdmin, "load", "--quiet", repo)
+	cmd.Stdin = gr
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("svnadmin load: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// waitForMaintenance waits until the built-in hooks of the repository reject writes,
+// so that nobody commits to the repository while it is replaced.
+func (r *Restore) waitForMaintenance(repo string) error {
+	if r.MaintenanceTimeout <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(r.MaintenanceTimeout)
+	for {
+		config := &svnconfig.HookConfig{}
+		data, err := ioutil.ReadFile(filepath.Join(repo, svnconfig.HookConfigPath))
+		if err == nil {
+			err = yaml.Unmarshal(data, config)
+		}
+		if err == nil && config.ReadOnly != nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the repository has not been put in maintenance within %s", r.MaintenanceTimeout)
+		}
+		time.Sleep(r.PollInterval)
+	}
+}
+
+// copyHooks replaces the hooks of the repository dest with the ones of src, including the configuration of the built-in hooks.
+func copyHooks(src, dest string) error {
+	srcHooks, destHooks := filepath.Join(src, "hooks"), filepath.Join(dest, "hooks")
+	entries, err := ioutil.ReadDir(srcHooks)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(destHooks); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(destHooks, 0755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(srcHooks, e.Name()), filepath.Join(destHooks, e.Name()), e.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	config := filepath.Join(src, svnconfig.HookConfigPath)
+	if !fileExists(config) {
+		return nil
+	}
+	return copyFile(config, filepath.Join(dest, svnconfig.HookConfigPath), 0644)
+}
+
+func copyFile(src, dest string, perm os.FileMode) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, data, perm)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
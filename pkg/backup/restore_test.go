@@ -0,0 +1,178 @@
+package backup_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/genkami/svn-operator/pkg/backup"
+	"github.com/genkami/svn-operator/pkg/s3"
+	"github.com/genkami/svn-operator/pkg/s3/s3test"
+)
+
+var _ = Describe("Restore", func() {
+	var tmpDir string
+	var r *backup.Restore
+	writeScript := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755)).To(Succeed())
+		return path
+	}
+	writeDump := func(name, content string) string {
+		buf := bytes.NewBuffer(nil)
+		w := gzip.NewWriter(buf)
+		_, err := w.Write([]byte(content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+		return path
+	}
+	readFile := func(path string) string {
+		content, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "restore")
+		Expect(err).NotTo(HaveOccurred())
+		r = &backup.Restore{
+			SvnAdmin: writeScript("svnadmin", `
+case "$1" in
+  create) for repo; do :; done; mkdir -p "$repo/conf" "$repo/hooks"; echo "$@" > "$repo/created";;
+  load) cat >> "$3/loaded";;
+  hotcopy) cp -r "$2" "$3";;
+  verify) if grep -q corrupt "$3/loaded" 2>/dev/null; then echo "E160004: Corrupt node-revision" >&2; exit 1; fi;;
+esac
+`),
+			SvnLook:      writeScript("svnlook", `echo 7`),
+			ReposDir:     filepath.Join(tmpDir, "repos"),
+			TrashDir:     filepath.Join(tmpDir, "trash"),
+			StagingDir:   filepath.Join(tmpDir, "restoring"),
+			Repository:   "hoge",
+			PollInterval: 10 * time.Millisecond,
+		}
+		Expect(os.MkdirAll(r.ReposDir, 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("loads a chain of dump files into a new repository", func() {
+		r.Steps = []backup.Step{
+			{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "full\n")},
+			{Method: backup.MethodDump, Path: writeDump("incremental.dump.gz", "incremental\n")},
+		}
+		result, err := r.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(&backup.RestoreResult{Revision: 7, Verified: true}))
+		Expect(readFile(filepath.Join(r.ReposDir, "hoge", "loaded"))).To(Equal("full\nincremental\n"))
+		Expect(filepath.Join(r.StagingDir, "hoge")).NotTo(BeADirectory())
+	})
+
+	It("creates the repository with the given options", func() {
+		r.Steps = []backup.Step{{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "full\n")}}
+		r.FSType = "fsx"
+		r.CompatibleVersion = "1.9"
+		_, err := r.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readFile(filepath.Join(r.ReposDir, "hoge", "created"))).To(Equal("create --fs-type fsx --compatible-version 1.9 " + filepath.Join(r.StagingDir, "hoge") + "\n"))
+	})
+
+	It("reads dump files from an S3-compatible bucket", func() {
+		server := s3test.NewServer("minio")
+		defer server.Close()
+		full, err := ioutil.ReadFile(writeDump("full.dump.gz", "full\n"))
+		Expect(err).NotTo(HaveOccurred())
+		server.PutObject("backups", "daily/full/hoge.dump.gz", full)
+		r.Store = &backup.S3Store{
+			Client: &s3.Client{Endpoint: server.URL, AccessKeyID: "minio", SecretAccessKey: "minio123"},
+			Bucket: "backups",
+		}
+		r.Steps = []backup.Step{{Method: backup.MethodDump, Path: "daily/full/hoge.dump.gz"}}
+		_, err = r.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readFile(filepath.Join(r.ReposDir, "hoge", "loaded"))).To(Equal("full\n"))
+	})
+
+	It("restores a hotcopy followed by dump files", func() {
+		hotcopy := filepath.Join(tmpDir, "backup", "hoge")
+		Expect(os.MkdirAll(hotcopy, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(hotcopy, "loaded"), []byte("hotcopy\n"), 0644)).To(Succeed())
+		r.Steps = []backup.Step{
+			{Method: backup.MethodHotcopy, Path: hotcopy},
+			{Method: backup.MethodDump, Path: writeDump("incremental.dump.gz", "incremental\n")},
+		}
+		_, err := r.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readFile(filepath.Join(r.ReposDir, "hoge", "loaded"))).To(Equal("hotcopy\nincremental\n"))
+	})
+
+	It("moves the existing repository to the trash", func() {
+		Expect(os.MkdirAll(filepath.Join(r.ReposDir, "hoge", "hooks"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(r.ReposDir, "hoge", "loaded"), []byte("old\n"), 0644)).To(Succeed())
+		r.Steps = []backup.Step{{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "full\n")}}
+		result, err := r.Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readFile(filepath.Join(r.ReposDir, "hoge", "loaded"))).To(Equal("full\n"))
+		Expect(readFile(filepath.Join(result.Archived, "loaded"))).To(Equal("old\n"))
+		Expect(filepath.Dir(result.Archived)).To(Equal(r.TrashDir))
+	})
+
+	It("keeps the existing repository if the restored one fails to verify", func() {
+		Expect(os.MkdirAll(filepath.Join(r.ReposDir, "hoge"), 0755)).To(Succeed())
+		r.Steps = []backup.Step{{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "corrupt\n")}}
+		result, err := r.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(result).To(Equal(&backup.RestoreResult{Revision: 7, VerifyMessage: "E160004: Corrupt node-revision"}))
+		Expect(filepath.Join(r.ReposDir, "hoge", "loaded")).NotTo(BeAnExistingFile())
+		Expect(r.TrashDir).NotTo(BeADirectory())
+	})
+
+	Context("when the repository must be put in maintenance", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(r.ReposDir, "hoge", "conf"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(r.ReposDir, "hoge", "hooks"), 0755)).To(Succeed())
+			r.Steps = []backup.Step{{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "full\n")}}
+			r.MaintenanceTimeout = 50 * time.Millisecond
+		})
+
+		It("replaces the repository once it becomes read-only", func() {
+			config := filepath.Join(r.ReposDir, "hoge", "conf", "svn-operator-hooks.yaml")
+			Expect(ioutil.WriteFile(config, []byte("readOnly:\n  message: restoring\n"), 0644)).To(Succeed())
+			hook := filepath.Join(r.ReposDir, "hoge", "hooks", "pre-commit")
+			Expect(ioutil.WriteFile(hook, []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			_, err := r.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readFile(filepath.Join(r.ReposDir, "hoge", "loaded"))).To(Equal("full\n"))
+			// The restored repository stays read-only until the maintenance is over.
+			Expect(readFile(config)).To(ContainSubstring("readOnly"))
+			Expect(hook).To(BeAnExistingFile())
+		})
+
+		It("gives up if the repository does not become read-only", func() {
+			_, err := r.Run()
+			Expect(err).To(MatchError(ContainSubstring("maintenance")))
+			Expect(filepath.Join(r.ReposDir, "hoge", "loaded")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	It("rejects hotcopies after the first backup", func() {
+		r.Steps = []backup.Step{
+			{Method: backup.MethodDump, Path: writeDump("full.dump.gz", "full\n")},
+			{Method: backup.MethodHotcopy, Path: tmpDir},
+		}
+		_, err := r.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(filepath.Join(r.ReposDir, "hoge")).NotTo(BeADirectory())
+	})
+})
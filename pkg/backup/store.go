@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/genkami/svn-operator/pkg/s3"
+)
+
+// Here is a list of environment variables that NewS3Store reads credentials from.
+const (
+	EnvAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	EnvSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	EnvSessionToken    = "AWS_SESSION_TOKEN"
+)
+
+// Store is where the files of backups are stored.
+type Store interface {
+	// Put stores the content read from r as the file with the given name.
+	// Nothing is stored if reading r fails.
+	Put(name string, r io.Reader) error
+	// Open opens the file with the given name.
+	Open(name string) (io.ReadCloser, error)
+	// RemoveAll removes all files in the store.
+	RemoveAll() error
+}
+
+// DirStore stores files in a directory.
+type DirStore struct {
+	// Dir is a path to the directory. Names are used as they are if empty.
+	Dir string
+}
+
+var _ Store = &DirStore{}
+
+func (s *DirStore) Put(name string, r io.Reader) error {
+	p := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(p, r)
+}
+
+func (s *DirStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.Dir, name))
+}
+
+func (s *DirStore) RemoveAll() error {
+	if s.Dir == "" {
+		return fmt.Errorf("no directory to remove")
+	}
+	return os.RemoveAll(s.Dir)
+}
+
+// S3Store stores files as objects in an S3-compatible bucket.
+// Files are uploaded as they are read, so they need no scratch space.
+type S3Store struct {
+	Client *s3.Client
+	Bucket string
+	// Prefix is prepended to names of files with a slash to make keys of objects.
+	Prefix string
+}
+
+var _ Store = &S3Store{}
+
+// NewS3Store returns an S3Store with the credentials in the environment variables.
+func NewS3Store(endpoint, region, bucket, prefix string) (*S3Store, error) {
+	client := &s3.Client{
+		Endpoint:        endpoint,
+		Region:          region,
+		AccessKeyID:     os.Getenv(EnvAccessKeyID),
+		SecretAccessKey: os.Getenv(EnvSecretAccessKey),
+		SessionToken:    os.Getenv(EnvSessionToken),
+	}
+	if client.Endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("both the endpoint and the bucket are required")
+	}
+	if client.AccessKeyID == "" || client.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s and %s are required", EnvAccessKeyID, EnvSecretAccessKey)
+	}
+	return &S3Store{Client: client, Bucket: bucket, Prefix: prefix}, nil
+}
+
+func (s *S3Store) Put(name string, r io.Reader) error {
+	return s.Client.PutObject(context.Background(), s.Bucket, s.keyOf(name), r)
+}
+
+func (s *S3Store) Open(name string) (io.ReadCloser, error) {
+	return s.Client.GetObject(context.Background(), s.Bucket, s.keyOf(name))
+}
+
+func (s *S3Store) RemoveAll() error {
+	if s.Prefix == "" {
+		return fmt.Errorf("refusing to remove everything in the bucket")
+	}
+	ctx := context.Background()
+	// Other backups may have names that start with the same prefix.
+	keys, err := s.Client.ListObjects(ctx, s.Bucket, path.Clean(s.Prefix)+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Client.DeleteObject(ctx, s.Bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Store) keyOf(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return path.Join(s.Prefix, name)
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package commitpolicy implements the checks of commits run by the built-in hooks,
+// namely svnconfig.CommitPolicy, svnconfig.Quota, svnconfig.ReadOnly and svnconfig.Mirror.
+package commitpolicy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// Here is a list of rules that commits can violate.
+// They are named after the fields of svnconfig.CommitPolicy so that users can find which setting rejected their commits.
+const (
+	RuleLogMessagePattern   = "logMessagePattern"
+	RuleMinLogMessageLength = "minLogMessageLength"
+	RuleMaxFileSize         = "maxFileSize"
+	RuleCaseCollisions      = "rejectCaseCollisions"
+	RuleBlockedPaths        = "blockedPaths"
+
+	// RuleQuota is violated by commits to repositories that have used up their quotas.
+	RuleQuota = "quota"
+	// RuleServerQuota is violated by commits to SVN servers that have used up their quotas.
+	RuleServerQuota = "svnServer.quota"
+	// RuleReadOnly is violated by any writes to read-only repositories.
+	RuleReadOnly = "readOnly"
+	// RuleMirror is violated by any writes to mirrors by users other than svnsync.
+	RuleMirror = "source.mirror"
+)
+
+// Violation is a rule that a commit violates.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s", v.Rule, v.Message)
+}
+
+// Here is a list of kinds of changes.
+const (
+	ActionAdd    = "A"
+	ActionDelete = "D"
+	ActionUpdate = "U"
+)
+
+// Change is a change of a single path in a commit.
+type Change struct {
+	// Action is one of ActionAdd, ActionDelete and ActionUpdate.
+	Action string
+
+	// Path is a path relative to the root of the repository. Paths of directories end with a slash.
+	Path string
+}
+
+// IsDir returns true if the changed path is a directory.
+func (c *Change) IsDir() bool {
+	return strings.HasSuffix(c.Path, "/")
+}
+
+// Transaction is a commit that is not committed yet.
+type Transaction interface {
+	// LogMessage returns the log message of the commit.
+	LogMessage() (string, error)
+
+	// Changes returns the paths changed by the commit.
+	Changes() ([]Change, error)
+
+	// FileSize returns the size of the file in the transaction.
+	FileSize(path string) (int64, error)
+
+	// Children returns the names of entries in the directory in the transaction.
+	Children(dir string) ([]string, error)
+}
+
+// Check returns the rules of the policy that the transaction violates.
+func Check(p *svnconfig.CommitPolicy, txn Transaction) ([]Violation, error) {
+	var violations []Violation
+	if p.LogMessagePattern != "" || p.MinLogMessageLength > 0 {
+		msg, err := txn.LogMessage()
+		if err != nil {
+			return nil, err
+		}
+		vs, err := checkLogMessage(p, msg)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, vs...)
+	}
+	if p.MaxFileSize <= 0 && !p.RejectCaseCollisions && len(p.BlockedPaths) == 0 {
+		return violations, nil
+	}
+	changes, err := txn.Changes()
+	if err != nil {
+		return nil, err
+	}
+	collided := map[string]bool{}
+	for i := range changes {
+		c := &changes[i]
+		if c.Action == ActionDelete {
+			continue
+		}
+		name := strings.TrimSuffix(c.Path, "/")
+		for _, pattern := range p.BlockedPaths {
+			if MatchPath(pattern, name) {
+				violations = append(violations, Violation{
+					Rule:    RuleBlockedPaths,
+					Message: fmt.Sprintf("%s matches the blocked path pattern %q", name, pattern),
+				})
+				break
+			}
+		}
+		if p.MaxFileSize > 0 && !c.IsDir() {
+			size, err := txn.FileSize(name)
+			if err != nil {
+				return nil, err
+			}
+			if size > p.MaxFileSize {
+				violations = append(violations, Violation{
+					Rule:    RuleMaxFileSize,
+					Message: fmt.Sprintf("%s is %d bytes, which exceeds the limit of %d bytes", name, size, p.MaxFileSize),
+				})
+			}
+		}
+		if p.RejectCaseCollisions && c.Action == ActionAdd && !collided[strings.ToLower(name)] {
+			other, err := caseCollisionOf(txn, name)
+			if err != nil {
+				return nil, err
+			}
+			if other != "" {
+				collided[strings.ToLower(name)] = true
+				violations = append(violations, Violation{
+					Rule:    RuleCaseCollisions,
+					Message: fmt.Sprintf("%s differs from %s only in case", name, other),
+				})
+			}
+		}
+	}
+	return violations, nil
+}
+
+func checkLogMessage(p *svnconfig.CommitPolicy, msg string) ([]Violation, error) {
+	var violations []Violation
+	if p.MinLogMessageLength > 0 {
+		length := utf8.RuneCountInString(strings.TrimSpace(msg))
+		if length < p.MinLogMessageLength {
+			violations = append(violations, Violation{
+				Rule:    RuleMinLogMessageLength,
+				Message: fmt.Sprintf("log message must be at least %d characters long, but it is %d characters long", p.MinLogMessageLength, length),
+			})
+		}
+	}
+	if p.LogMessagePattern != "" {
+		re, err := regexp.Compile(p.LogMessagePattern)
+		if err != nil {
+			return nil, err
+		}
+		if !re.MatchString(msg) {
+			violations = append(violations, Violation{
+				Rule:    RuleLogMessagePattern,
+				Message: fmt.Sprintf("log message must match the regular expression %q", p.LogMessagePattern),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// caseCollisionOf returns another path in the same directory that differs from p only in case,
+// or an empty string if there is no such path.
+func caseCollisionOf(txn Transaction, p string) (string, error) {
+	dir, name := path.Split(p)
+	children, err := txn.Children(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, child := range children {
+		if child != name && strings.EqualFold(child, name) {
+			return dir + child, nil
+		}
+	}
+	return "", nil
+}
+
+// CheckReadOnly returns a violation that tells the repository is read-only.
+func CheckReadOnly(r *svnconfig.ReadOnly) []Violation {
+	message := r.Message
+	if message == "" {
+		message = "the repository is read-only"
+	}
+	return []Violation{{Rule: RuleReadOnly, Message: message}}
+}
+
+// CheckMirror returns a violation unless user is the one that synchronizes the mirror.
+func CheckMirror(m *svnconfig.Mirror, user string) []Violation {
+	if user == m.SyncUser {
+		return nil
+	}
+	return []Violation{{Rule: RuleMirror, Message: "the repository is a mirror, which is written only by svnsync"}}
+}
+
+// CheckQuota returns the quotas that the repository has used up.
+// Since usage is measured periodically, commits are rejected only after the repository reaches its quota.
+func CheckQuota(q *svnconfig.Quota) []Violation {
+	var violations []Violation
+	if q.MaxSize > 0 && q.Size >= q.MaxSize {
+		violations = append(violations, Violation{
+			Rule:    RuleQuota,
+			Message: fmt.Sprintf("the repository uses %s, which reaches its quota of %s", formatSize(q.Size), formatSize(q.MaxSize)),
+		})
+	}
+	if q.MaxTotalSize > 0 && q.TotalSize >= q.MaxTotalSize {
+		violations = append(violations, Violation{
+			Rule:    RuleServerQuota,
+			Message: fmt.Sprintf("the repositories in the server use %s, which reaches the quota of %s", formatSize(q.TotalSize), formatSize(q.MaxTotalSize)),
+		})
+	}
+	return violations
+}
+
+// formatSize formats size in bytes in binary units.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	value := float64(size)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+// IsValidPattern reports whether pattern can be used as a blocked path pattern.
+func IsValidPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// MatchPath reports whether the path relative to the root of a repository, or one of its parent directories,
+// matches the pattern.
+//
+// Patterns without slashes are matched against base names (e.g. `*.exe`),
+// and others are matched against paths from the root (e.g. `/trunk/build`).
+func MatchPath(pattern, p string) bool {
+	p = "/" + strings.Trim(p, "/")
+	for ; p != "/"; p = path.Dir(p) {
+		target := p
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
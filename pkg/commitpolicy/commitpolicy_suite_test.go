@@ -0,0 +1,13 @@
+package commitpolicy_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCommitpolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Commitpolicy Suite")
+}
@@ -0,0 +1,243 @@
+package commitpolicy_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/genkami/svn-operator/pkg/commitpolicy"
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+type fakeTransaction struct {
+	log     string
+	changes []commitpolicy.Change
+	sizes   map[string]int64
+	tree    map[string][]string
+}
+
+func (t *fakeTransaction) LogMessage() (string, error) {
+	return t.log, nil
+}
+
+func (t *fakeTransaction) Changes() ([]commitpolicy.Change, error) {
+	return t.changes, nil
+}
+
+func (t *fakeTransaction) FileSize(path string) (int64, error) {
+	size, ok := t.sizes[path]
+	if !ok {
+		return 0, fmt.Errorf("no such file: %s", path)
+	}
+	return size, nil
+}
+
+func (t *fakeTransaction) Children(dir string) ([]string, error) {
+	return t.tree[dir], nil
+}
+
+var _ = Describe("Check", func() {
+	var policy *svnconfig.CommitPolicy
+	var txn *fakeTransaction
+	rulesOf := func() []string {
+		violations, err := commitpolicy.Check(policy, txn)
+		Expect(err).NotTo(HaveOccurred())
+		rules := []string{}
+		for _, v := range violations {
+			rules = append(rules, v.Rule)
+		}
+		return rules
+	}
+	BeforeEach(func() {
+		policy = &svnconfig.CommitPolicy{}
+		txn = &fakeTransaction{
+			log: "PROJ-1: fix a bug",
+			changes: []commitpolicy.Change{
+				{Action: commitpolicy.ActionAdd, Path: "trunk/docs/"},
+				{Action: commitpolicy.ActionAdd, Path: "trunk/docs/README"},
+				{Action: commitpolicy.ActionUpdate, Path: "trunk/main.c"},
+				{Action: commitpolicy.ActionDelete, Path: "trunk/huge.iso"},
+			},
+			sizes: map[string]int64{"trunk/docs/README": 100, "trunk/main.c": 2000},
+			tree: map[string][]string{
+				"trunk/":      {"docs", "main.c", "Makefile"},
+				"trunk/docs/": {"README"},
+			},
+		}
+	})
+
+	Context("when the policy is empty", func() {
+		It("accepts any commit", func() {
+			Expect(rulesOf()).To(BeEmpty())
+		})
+	})
+
+	Describe("log messages", func() {
+		It("rejects messages that do not match the pattern", func() {
+			policy.LogMessagePattern = `^[A-Z]+-[0-9]+: `
+			Expect(rulesOf()).To(BeEmpty())
+			txn.log = "fix a bug"
+			Expect(rulesOf()).To(Equal([]string{commitpolicy.RuleLogMessagePattern}))
+		})
+
+		It("rejects short messages, ignoring surrounding spaces", func() {
+			policy.MinLogMessageLength = 5
+			txn.log = "  fix\n"
+			Expect(rulesOf()).To(Equal([]string{commitpolicy.RuleMinLogMessageLength}))
+			txn.log = "fixed"
+			Expect(rulesOf()).To(BeEmpty())
+		})
+
+		It("returns an error if the pattern is invalid", func() {
+			policy.LogMessagePattern = `(`
+			_, err := commitpolicy.Check(policy, txn)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("file sizes", func() {
+		It("rejects files larger than the limit and tells which file is too large", func() {
+			policy.MaxFileSize = 1000
+			violations, err := commitpolicy.Check(policy, txn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]commitpolicy.Violation{{
+				Rule:    commitpolicy.RuleMaxFileSize,
+				Message: "trunk/main.c is 2000 bytes, which exceeds the limit of 1000 bytes",
+			}}))
+		})
+	})
+
+	Describe("case collisions", func() {
+		BeforeEach(func() {
+			policy.RejectCaseCollisions = true
+		})
+
+		It("accepts paths without collisions", func() {
+			Expect(rulesOf()).To(BeEmpty())
+		})
+
+		It("rejects added paths that differ from existing ones only in case", func() {
+			txn.changes = append(txn.changes, commitpolicy.Change{Action: commitpolicy.ActionAdd, Path: "trunk/makefile"})
+			txn.sizes["trunk/makefile"] = 10
+			txn.tree["trunk/"] = append(txn.tree["trunk/"], "makefile")
+			violations, err := commitpolicy.Check(policy, txn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]commitpolicy.Violation{{
+				Rule:    commitpolicy.RuleCaseCollisions,
+				Message: "trunk/makefile differs from trunk/Makefile only in case",
+			}}))
+		})
+	})
+
+	Describe("blocked paths", func() {
+		It("rejects additions and modifications of matching paths but allows deletions", func() {
+			policy.BlockedPaths = []string{"*.iso", "*.c"}
+			Expect(rulesOf()).To(Equal([]string{commitpolicy.RuleBlockedPaths}))
+		})
+
+		It("rejects paths under matching directories", func() {
+			policy.BlockedPaths = []string{"/trunk/docs"}
+			Expect(rulesOf()).To(Equal([]string{commitpolicy.RuleBlockedPaths, commitpolicy.RuleBlockedPaths}))
+		})
+	})
+})
+
+var _ = Describe("CheckQuota", func() {
+	It("accepts commits within the quotas", func() {
+		Expect(commitpolicy.CheckQuota(&svnconfig.Quota{Size: 100, MaxSize: 1024, TotalSize: 100})).To(BeEmpty())
+	})
+
+	It("rejects commits once the repository reaches its quota", func() {
+		Expect(commitpolicy.CheckQuota(&svnconfig.Quota{Size: 3 << 20, MaxSize: 3 << 20})).To(Equal([]commitpolicy.Violation{{
+			Rule:    commitpolicy.RuleQuota,
+			Message: "the repository uses 3.0 MiB, which reaches its quota of 3.0 MiB",
+		}}))
+	})
+
+	It("rejects commits once the server reaches its quota", func() {
+		Expect(commitpolicy.CheckQuota(&svnconfig.Quota{Size: 1, TotalSize: 1536, MaxTotalSize: 1024})).To(Equal([]commitpolicy.Violation{{
+			Rule:    commitpolicy.RuleServerQuota,
+			Message: "the repositories in the server use 1.5 KiB, which reaches the quota of 1.0 KiB",
+		}}))
+	})
+})
+
+var _ = Describe("CheckReadOnly", func() {
+	It("tells the message", func() {
+		Expect(commitpolicy.CheckReadOnly(&svnconfig.ReadOnly{Message: "frozen for the migration"})).To(Equal([]commitpolicy.Violation{{
+			Rule:    commitpolicy.RuleReadOnly,
+			Message: "frozen for the migration",
+		}}))
+	})
+
+	It("tells the repository is read-only if there is no message", func() {
+		Expect(commitpolicy.CheckReadOnly(&svnconfig.ReadOnly{})[0].Message).To(Equal("the repository is read-only"))
+	})
+})
+
+var _ = Describe("CheckMirror", func() {
+	mirror := &svnconfig.Mirror{SyncUser: "svnsync"}
+
+	It("accepts writes by the sync user", func() {
+		Expect(commitpolicy.CheckMirror(mirror, "svnsync")).To(BeEmpty())
+	})
+
+	It("rejects writes by others", func() {
+		Expect(commitpolicy.CheckMirror(mirror, "towa")).To(Equal([]commitpolicy.Violation{{
+			Rule:    commitpolicy.RuleMirror,
+			Message: "the repository is a mirror, which is written only by svnsync",
+		}}))
+	})
+})
+
+var _ = Describe("MatchPath", func() {
+	It("matches patterns without slashes against base names", func() {
+		Expect(commitpolicy.MatchPath("*.exe", "trunk/bin/app.exe")).To(BeTrue())
+		Expect(commitpolicy.MatchPath("*.exe", "trunk/bin/app.exe.txt")).To(BeFalse())
+	})
+
+	It("matches other patterns against paths from the root", func() {
+		Expect(commitpolicy.MatchPath("/trunk/*.key", "trunk/secret.key")).To(BeTrue())
+		Expect(commitpolicy.MatchPath("/trunk/*.key", "branches/b/secret.key")).To(BeFalse())
+	})
+
+	It("matches children of matching directories", func() {
+		Expect(commitpolicy.MatchPath("node_modules", "trunk/node_modules/x/index.js")).To(BeTrue())
+		Expect(commitpolicy.MatchPath("/trunk/build", "trunk/build/")).To(BeTrue())
+	})
+})
+
+var _ = Describe("IsValidPattern", func() {
+	It("rejects malformed patterns", func() {
+		Expect(commitpolicy.IsValidPattern("*.exe")).To(BeTrue())
+		Expect(commitpolicy.IsValidPattern("[")).To(BeFalse())
+		Expect(commitpolicy.IsValidPattern("")).To(BeFalse())
+	})
+})
+
+var _ = Describe("ParseChanged", func() {
+	It("parses the output of svnlook changed", func() {
+		out := strings.Join([]string{
+			"A   trunk/new.txt",
+			"U   trunk/modified.txt",
+			"_U  trunk/",
+			"D   trunk/deleted.txt",
+			"",
+		}, "\n")
+		Expect(commitpolicy.ParseChanged(out)).To(Equal([]commitpolicy.Change{
+			{Action: commitpolicy.ActionAdd, Path: "trunk/new.txt"},
+			{Action: commitpolicy.ActionUpdate, Path: "trunk/modified.txt"},
+			{Action: commitpolicy.ActionUpdate, Path: "trunk/"},
+			{Action: commitpolicy.ActionDelete, Path: "trunk/deleted.txt"},
+		}))
+	})
+})
+
+var _ = Describe("ParseTree", func() {
+	It("returns the names of the children", func() {
+		Expect(commitpolicy.ParseTree("trunk/\ntrunk/Makefile\ntrunk/docs/\n")).To(Equal([]string{"Makefile", "docs"}))
+		Expect(commitpolicy.ParseTree("/\ntrunk/\nbranches/\n")).To(Equal([]string{"trunk", "branches"}))
+	})
+})
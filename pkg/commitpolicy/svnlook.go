@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitpolicy
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SvnLookTransaction is a Transaction that is inspected by the `svnlook` command.
+type SvnLookTransaction struct {
+	// SvnLook is a path to the `svnlook` command.
+	SvnLook string
+	// Repos is a path to the repository.
+	Repos string
+	// Txn is the name of the transaction.
+	Txn string
+}
+
+var _ Transaction = &SvnLookTransaction{}
+
+func (t *SvnLookTransaction) LogMessage() (string, error) {
+	out, err := t.svnlook("log")
+	if err != nil {
+		return "", err
+	}
+	// svnlook appends a newline to the log message.
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (t *SvnLookTransaction) Changes() ([]Change, error) {
+	out, err := t.svnlook("changed")
+	if err != nil {
+		return nil, err
+	}
+	return ParseChanged(out)
+}
+
+func (t *SvnLookTransaction) FileSize(path string) (int64, error) {
+	out, err := t.svnlook("filesize", path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
+
+func (t *SvnLookTransaction) Children(dir string) ([]string, error) {
+	if dir == "" {
+		dir = "/"
+	}
+	out, err := t.svnlook("tree", "--non-recursive", "--full-paths", dir)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTree(out), nil
+}
+
+func (t *SvnLookTransaction) svnlook(subcommand string, args ...string) (string, error) {
+	args = append([]string{subcommand, "--transaction", t.Txn, t.Repos}, args...)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command(t.SvnLook, args...)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("svnlook %s: %w: %s", subcommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// ParseChanged parses the output of `svnlook changed`, which looks like:
+//
+//	A   trunk/new.txt
+//	U   trunk/modified.txt
+//	_U  trunk/
+//	D   trunk/deleted.txt
+func ParseChanged(out string) ([]Change, error) {
+	var changes []Change
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if len(line) < 5 {
+			return nil, fmt.Errorf("malformed output of svnlook changed: %q", line)
+		}
+		var action string
+		switch line[0] {
+		case 'A':
+			action = ActionAdd
+		case 'D':
+			action = ActionDelete
+		default:
+			action = ActionUpdate
+		}
+		changes = append(changes, Change{Action: action, Path: line[4:]})
+	}
+	return changes, nil
+}
+
+// ParseTree parses the output of `svnlook tree --non-recursive --full-paths` and returns the names of the children.
+// The first line of the output is the directory itself.
+func ParseTree(out string) []string {
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	var children []string
+	for _, line := range lines[1:] {
+		p := strings.TrimSuffix(line, "/")
+		if i := strings.LastIndex(p, "/"); i >= 0 {
+			p = p[i+1:]
+		}
+		children = append(children, p)
+	}
+	return children
+}
@@ -0,0 +1,60 @@
+package cron_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/genkami/svn-operator/pkg/cron"
+)
+
+var _ = Describe("Schedule", func() {
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+	next := func(spec, from string) string {
+		s, err := cron.Parse(spec)
+		Expect(err).NotTo(HaveOccurred())
+		n := s.Next(at(from))
+		if n.IsZero() {
+			return ""
+		}
+		return n.Format(time.RFC3339)
+	}
+
+	It("finds the next minute that matches", func() {
+		Expect(next("30 3 * * *", "2021-05-01T03:29:59Z")).To(Equal("2021-05-01T03:30:00Z"))
+		Expect(next("30 3 * * *", "2021-05-01T03:30:00Z")).To(Equal("2021-05-02T03:30:00Z"))
+	})
+
+	It("supports lists, ranges and steps", func() {
+		Expect(next("*/15 9-17 * * mon-fri", "2021-05-01T10:00:00Z")).To(Equal("2021-05-03T09:00:00Z"))
+		Expect(next("0 0,12 * * *", "2021-05-01T00:00:00Z")).To(Equal("2021-05-01T12:00:00Z"))
+		Expect(next("5/20 * * * *", "2021-05-01T00:30:00Z")).To(Equal("2021-05-01T00:45:00Z"))
+	})
+
+	It("supports months and macros", func() {
+		Expect(next("0 0 1 jan,jul *", "2021-05-01T00:00:00Z")).To(Equal("2021-07-01T00:00:00Z"))
+		Expect(next("@weekly", "2021-05-01T00:00:00Z")).To(Equal("2021-05-02T00:00:00Z"))
+		Expect(next("0 0 * * 7", "2021-05-01T00:00:00Z")).To(Equal("2021-05-02T00:00:00Z"))
+	})
+
+	It("matches either the day of month or the day of week if both are restricted", func() {
+		Expect(next("0 0 13 * fri", "2021-05-01T00:00:00Z")).To(Equal("2021-05-07T00:00:00Z"))
+		Expect(next("0 0 13 * fri", "2021-05-08T00:00:00Z")).To(Equal("2021-05-13T00:00:00Z"))
+	})
+
+	It("returns the zero time if the schedule never matches", func() {
+		Expect(next("0 0 30 2 *", "2021-05-01T00:00:00Z")).To(BeEmpty())
+	})
+
+	It("rejects invalid expressions", func() {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * foo *", "*/0 * * * *", "5-1 * * * *"} {
+			_, err := cron.Parse(spec)
+			Expect(err).To(HaveOccurred(), spec)
+		}
+	})
+})
@@ -0,0 +1,328 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3 is a minimal client of S3-compatible object storages such as Amazon S3 and MinIO.
+// It only implements what backups need, and always uses path-style URLs so that any endpoint works.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is the region used to sign requests if Client.Region is empty.
+	DefaultRegion = "us-east-1"
+
+	// DefaultPartSize is the size of parts of multipart uploads if Client.PartSize is zero.
+	// Objects can be up to 10000 times as large as the part size.
+	DefaultPartSize = 16 * 1024 * 1024
+
+	// MinPartSize is the minimum size of parts except the last one that S3 accepts.
+	MinPartSize = 5 * 1024 * 1024
+)
+
+// Client is a client of an S3-compatible object storage.
+type Client struct {
+	// Endpoint is the base URL of the storage (e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000).
+	Endpoint string
+	// Region is the region of the storage.
+	Region string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is a token for temporary credentials. It is optional.
+	SessionToken string
+
+	// PartSize is the size of parts that PutObject uploads at a time, which are buffered in memory.
+	PartSize int
+
+	// HTTPClient is used to send requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// Now returns the current time to sign requests. time.Now is used if nil.
+	Now func() time.Time
+}
+
+// Error is an error returned by the storage.
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// IsNotFound returns true if err means that the object or the bucket does not exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// PutObject uploads the content read from r as an object.
+// The content is streamed with a multipart upload if it is larger than PartSize, so its size need not be known.
+// If reading r fails, the upload is aborted and the object is left as it was.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, r io.Reader) error {
+	buf := make([]byte, c.partSize())
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err := c.do(ctx, http.MethodPut, bucket, key, nil, buf[:n], nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := c.createMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	parts, err := c.uploadParts(ctx, bucket, key, uploadID, buf, r)
+	if err == nil {
+		err = c.completeMultipartUpload(ctx, bucket, key, uploadID, parts)
+	}
+	if err != nil {
+		// The parts uploaded so far would be charged forever unless the upload is aborted.
+		query := url.Values{"uploadId": {uploadID}}
+		if _, abortErr := c.do(context.Background(), http.MethodDelete, bucket, key, query, nil, nil); abortErr != nil {
+			return fmt.Errorf("%w (failed to abort the upload: %s)", err, abortErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (c *Client) createMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp, &result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("s3: no upload ID is returned")
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts uploads buf, which is already filled, and then the rest of r.
+func (c *Client) uploadParts(ctx context.Context, bucket, key, uploadID string, buf []byte, r io.Reader) ([]completedPart, error) {
+	var parts []completedPart
+	n := len(buf)
+	for number := 1; ; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		header := http.Header{}
+		if _, err := c.doWithHeader(ctx, http.MethodPut, bucket, key, query, buf[:n], nil, header); err != nil {
+			return nil, err
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+		var err error
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		return err
+	}
+	// The storage may report errors with 200 OK once it has started to respond.
+	return errorOf(http.StatusOK, resp)
+}
+
+// GetObject returns the content of an object. The caller must close it.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, bucket, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return nil, errorFromResponse(resp.StatusCode, data)
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject deletes an object. It succeeds even if the object does not exist.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil, nil)
+	return err
+}
+
+// ListObjects returns the keys of all objects whose keys start with prefix.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, bucket, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(resp, &result); err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a request and returns the body of the response.
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, body []byte, header http.Header) ([]byte, error) {
+	return c.doWithHeader(ctx, method, bucket, key, query, body, header, nil)
+}
+
+// doWithHeader sends a request and returns the body of the response. The headers of the response are copied to respHeader.
+func (c *Client) doWithHeader(ctx context.Context, method, bucket, key string, query url.Values, body []byte, header, respHeader http.Header) ([]byte, error) {
+	req, err := c.newRequest(ctx, method, bucket, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, errorFromResponse(resp.StatusCode, data)
+	}
+	if respHeader != nil {
+		for name, values := range resp.Header {
+			respHeader[name] = values
+		}
+	}
+	return data, nil
+}
+
+// newRequest returns a signed request.
+func (c *Client) newRequest(ctx context.Context, method, bucket, key string, query url.Values, body []byte) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = escape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	c.sign(req, hashHex(body), now())
+	return req, nil
+}
+
+func (c *Client) partSize() int {
+	if c.PartSize <= 0 {
+		return DefaultPartSize
+	}
+	return c.PartSize
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// errorOf returns the error in the body, if any.
+func errorOf(statusCode int, body []byte) error {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(body, &root); err != nil || root.XMLName.Local != "Error" {
+		return nil
+	}
+	return errorFromResponse(statusCode, body)
+}
+
+func errorFromResponse(statusCode int, body []byte) error {
+	e := &Error{}
+	// The body is empty for HEAD requests and some errors.
+	_ = xml.Unmarshal(body, e)
+	e.StatusCode = statusCode
+	return e
+}
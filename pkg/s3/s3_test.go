@@ -0,0 +1,99 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/genkami/svn-operator/pkg/s3"
+	"github.com/genkami/svn-operator/pkg/s3/s3test"
+)
+
+var _ = Describe("Client", func() {
+	var server *s3test.Server
+	var c *s3.Client
+	ctx := context.Background()
+	BeforeEach(func() {
+		server = s3test.NewServer("minio")
+		server.CreateBucket("backups")
+		c = &s3.Client{
+			Endpoint:        server.URL,
+			AccessKeyID:     "minio",
+			SecretAccessKey: "minio123",
+			PartSize:        8,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	objectOf := func(key string) string {
+		data, ok := server.Object("backups", key)
+		Expect(ok).To(BeTrue(), key)
+		return string(data)
+	}
+
+	It("uploads small objects at once", func() {
+		Expect(c.PutObject(ctx, "backups", "daily/hoge.dump.gz", bytes.NewReader([]byte("hoge")))).To(Succeed())
+		Expect(objectOf("daily/hoge.dump.gz")).To(Equal("hoge"))
+	})
+
+	It("streams large objects with multipart uploads", func() {
+		content := "0123456789abcdefghijklmnopq"
+		Expect(c.PutObject(ctx, "backups", "hoge", bytes.NewReader([]byte(content)))).To(Succeed())
+		Expect(objectOf("hoge")).To(Equal(content))
+		Expect(server.Uploads()).To(BeZero())
+	})
+
+	It("aborts uploads if the content cannot be read", func() {
+		r := io.MultiReader(bytes.NewReader([]byte("0123456789abcdefghij")), &failingReader{})
+		Expect(c.PutObject(ctx, "backups", "hoge", r)).To(MatchError("broken pipe"))
+		_, ok := server.Object("backups", "hoge")
+		Expect(ok).To(BeFalse())
+		Expect(server.Uploads()).To(BeZero())
+	})
+
+	It("downloads objects", func() {
+		server.PutObject("backups", "dir/a b", []byte("content"))
+		r, err := c.GetObject(ctx, "backups", "dir/a b")
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		Expect(ioutil.ReadAll(r)).To(Equal([]byte("content")))
+	})
+
+	It("reports missing objects", func() {
+		_, err := c.GetObject(ctx, "backups", "missing")
+		Expect(s3.IsNotFound(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("NoSuchKey")))
+	})
+
+	It("lists and deletes objects", func() {
+		server.MaxKeys = 2
+		for _, key := range []string{"daily/a", "daily/b", "daily/c", "weekly/a"} {
+			server.PutObject("backups", key, []byte(key))
+		}
+		keys, err := c.ListObjects(ctx, "backups", "daily/")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(Equal([]string{"daily/a", "daily/b", "daily/c"}))
+		Expect(c.DeleteObject(ctx, "backups", "daily/b")).To(Succeed())
+		Expect(server.Keys("backups")).To(Equal([]string{"daily/a", "daily/c", "weekly/a"}))
+	})
+
+	It("rejects unknown credentials", func() {
+		c.AccessKeyID = "unknown"
+		err := c.PutObject(ctx, "backups", "hoge", bytes.NewReader(nil))
+		Expect(err).To(MatchError(ContainSubstring("InvalidAccessKeyId")))
+	})
+})
+
+type failingReader struct{}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3test provides an in-memory stand-in for S3-compatible object storages in tests.
+package s3test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is an S3-compatible HTTP server that keeps objects in memory.
+// It supports path-style requests for the operations that the s3 package uses.
+type Server struct {
+	*httptest.Server
+
+	// AccessKeyID is the only access key that the server accepts.
+	AccessKeyID string
+
+	// MaxKeys is the number of keys returned at a time by ListObjectsV2.
+	MaxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+// NewServer starts a server that accepts AccessKeyID. The caller must call Close.
+func NewServer(accessKeyID string) *Server {
+	s := &Server{
+		AccessKeyID: accessKeyID,
+		MaxKeys:     1000,
+		buckets:     map[string]map[string][]byte{},
+		uploads:     map[string]map[int][]byte{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// CreateBucket creates an empty bucket.
+func (s *Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = map[string][]byte{}
+	}
+}
+
+// Object returns the content of an object.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.buckets[bucket][key]
+	return data, ok
+}
+
+// PutObject stores an object.
+func (s *Server) PutObject(bucket, key string, data []byte) {
+	s.CreateBucket(bucket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket][key] = data
+}
+
+// Keys returns the sorted keys of all objects in the bucket.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys(bucket, "")
+}
+
+// Uploads returns the number of multipart uploads that are neither completed nor aborted.
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+func (s *Server) keys(bucket, prefix string) []string {
+	keys := []string{}
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/") {
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId", "The access key ID does not exist.")
+		return
+	}
+	sum := sha256.Sum256(body)
+	if req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The content hash does not match.")
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	bucket, key := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		bucket, key = path[:i], path[i+1:]
+	}
+	query := req.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objects, ok := s.buckets[bucket]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return
+	}
+	_, isUpload := query["uploadId"]
+	uploadID := query.Get("uploadId")
+	if isUpload {
+		if _, ok := s.uploads[uploadID]; !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+			return
+		}
+	}
+
+	switch {
+	case req.Method == http.MethodGet && key == "":
+		s.listObjects(w, bucket, query.Get("prefix"), query.Get("continuation-token"))
+	case req.Method == http.MethodGet:
+		data, ok := objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		_, _ = w.Write(data)
+	case req.Method == http.MethodPut && isUpload:
+		number, err := strconv.Atoi(query.Get("partNumber"))
+		if err != nil || number < 1 {
+			writeError(w, http.StatusBadRequest, "InvalidArgument", "Part number must be a positive integer.")
+			return
+		}
+		s.uploads[uploadID][number] = body
+		w.Header().Set("ETag", etagOf(body))
+	case req.Method == http.MethodPut:
+		objects[key] = body
+		w.Header().Set("ETag", etagOf(body))
+	case req.Method == http.MethodPost && query["uploads"] != nil:
+		s.nextID++
+		id := fmt.Sprintf("upload-%d", s.nextID)
+		s.uploads[id] = map[int][]byte{}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: id})
+	case req.Method == http.MethodPost && isUpload:
+		s.completeMultipartUpload(w, objects, key, uploadID, body)
+	case req.Method == http.MethodDelete && isUpload:
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "The operation is not supported by s3test.")
+	}
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, bucket, prefix, token string) {
+	keys := s.keys(bucket, prefix)
+	start := 0
+	if token != "" {
+		start = sort.SearchStrings(keys, token)
+	}
+	end := start + s.MaxKeys
+	if end > len(keys) {
+		end = len(keys)
+	}
+	type object struct {
+		Key string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []object
+	}{Name: bucket, Prefix: prefix, KeyCount: end - start}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, object{Key: key})
+	}
+	if end < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[end]
+	}
+	writeXML(w, result)
+}
+
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, objects map[string][]byte, key, uploadID string, body []byte) {
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeError(w, http.StatusBadRequest, "MalformedXML", "The XML is not well-formed.")
+		return
+	}
+	var data []byte
+	for i, p := range req.Parts {
+		part, ok := s.uploads[uploadID][p.PartNumber]
+		if !ok || p.ETag != etagOf(part) || (i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber) {
+			writeError(w, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		data = append(data, part...)
+	}
+	objects[key] = data
+	delete(s.uploads, uploadID)
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Key     string
+	}{Key: key})
+}
+
+func etagOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write(append([]byte(xml.Header), data...))
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	data, _ := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: message})
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write(append([]byte(xml.Header), data...))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// fsfsConfigPath is a path to the configuration file of FSFS relative to the repository.
+var fsfsConfigPath = filepath.Join("db", "fsfs.conf")
+
+// createEmptyRepository runs `svnadmin create` with the options of the entry and writes the FSFS options.
+// The UUID is not set here since loading a dump file into an empty repository overwrites it.
+func (u *Updater) createEmptyRepository(repo string, opts *svnconfig.CreateOptions) error {
+	args := []string{u.SvnAdmin, "create"}
+	if opts != nil && opts.FSType != "" {
+		args = append(args, "--fs-type", opts.FSType)
+	}
+	if opts != nil && opts.CompatibleVersion != "" {
+		args = append(args, "--compatible-version", opts.CompatibleVersion)
+	}
+	args = append(args, repo)
+	if err := u.runCommand(args...); err != nil {
+		return err
+	}
+	if opts == nil || opts.FSFS == nil {
+		return nil
+	}
+	if err := writeFSFSOptions(filepath.Join(repo, fsfsConfigPath), opts.FSFS); err != nil {
+		// The options take effect only if they are written before the first commit,
+		// so we remove the repository to try again from scratch next time.
+		if rmErr := os.RemoveAll(repo); rmErr != nil {
+			u.Log.Error(rmErr, "failed to remove repository", "repository", repo)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeFSFSOptions sets the options in the configuration file of FSFS, leaving the other settings as they are.
+func writeFSFSOptions(path string, opts *svnconfig.FSFSOptions) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conf := string(content)
+	if opts.EnableRepSharing != nil {
+		conf = setINIOption(conf, "rep-sharing", "enable-rep-sharing", strconv.FormatBool(*opts.EnableRepSharing))
+	}
+	if opts.RevPropPackSize > 0 {
+		conf = setINIOption(conf, "packed-revprops", "revprop-pack-size", strconv.Itoa(opts.RevPropPackSize))
+	}
+	if opts.CompressPackedRevProps != nil {
+		conf = setINIOption(conf, "packed-revprops", "compress-packed-revprops", strconv.FormatBool(*opts.CompressPackedRevProps))
+	}
+	return ioutil.WriteFile(path, []byte(conf), 0644)
+}
+
+// setINIOption sets the option in the section of the INI-style configuration.
+// An existing option is replaced, otherwise the option is added to the top of the section.
+// Commented-out defaults are left as they are.
+func setINIOption(conf, section, key, value string) string {
+	option := key + " = " + value
+	lines := strings.Split(conf, "\n")
+	header := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if header >= 0 {
+				break
+			}
+			if trimmed == "["+section+"]" {
+				header = i
+			}
+			continue
+		}
+		if header < 0 {
+			continue
+		}
+		if eq := strings.Index(trimmed, "="); eq >= 0 && strings.TrimSpace(trimmed[:eq]) == key {
+			lines[i] = option
+			return strings.Join(lines, "\n")
+		}
+	}
+	if header < 0 {
+		if conf != "" && !strings.HasSuffix(conf, "\n") {
+			conf += "\n"
+		}
+		return conf + fmt.Sprintf("\n[%s]\n%s\n", section, option)
+	}
+	lines = append(lines[:header+1], append([]string{option}, lines[header+1:]...)...)
+	return strings.Join(lines, "\n")
+}
+
+// syncUUIDs sets the UUIDs of repositories that have other UUIDs than specified.
+func (u *Updater) syncUUIDs(reposConfig *svnconfig.ReposConfig) error {
+	for i := range reposConfig.Repositories {
+		entry := &reposConfig.Repositories[i]
+		repo := filepath.Join(u.ReposDir, entry.Name)
+		if !fileExists(repo) {
+			// The repository is being loaded, which sets the UUID by itself.
+			continue
+		}
+		if err := u.enforceUUID(repo, entry.Create); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforceUUID runs `svnadmin setuuid` if the repository has another UUID than specified.
+func (u *Updater) enforceUUID(repo string, opts *svnconfig.CreateOptions) error {
+	if opts == nil || opts.UUID == "" {
+		return nil
+	}
+	out, err := u.commandOutput(u.SvnLook, "uuid", repo)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(out) == opts.UUID {
+		return nil
+	}
+	u.Log.Info("setting UUID", "repository", repo, "uuid", opts.UUID)
+	return u.runCommand(u.SvnAdmin, "setuuid", repo, opts.UUID)
+}
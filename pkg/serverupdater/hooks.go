@@ -0,0 +1,219 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// hookMode is the permission of installed hooks.
+// Hooks are run by Apache, which owns the repositories, so they need not be writable by others.
+const hookMode = 0755
+
+// userHookSuffix is appended to the names of hooks in RepoEntry.Hooks
+// if the built-in hooks of the same name are installed, which run them after their own checks.
+const userHookSuffix = ".user"
+
+func (u *Updater) syncHooks(reposConfig *svnconfig.ReposConfig) error {
+	for i := range reposConfig.Repositories {
+		entry := &reposConfig.Repositories[i]
+		repo := filepath.Join(u.ReposDir, entry.Name)
+		if !fileExists(repo) {
+			// The repository is being loaded. Its hooks are installed when the load finishes.
+			continue
+		}
+		if err := u.installHooks(repo, entry, reposConfig); err != nil {
+			return fmt.Errorf("failed to install hooks of %q: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// installHooks makes the hooks of the repository identical to the ones that the entry declares,
+// including the built-in hooks.
+// Hooks that are not declared are removed, while the templates created by `svnadmin create` are left as they are.
+// reposConfig is the whole ReposConfig that the entry belongs to.
+func (u *Updater) installHooks(repo string, entry *svnconfig.RepoEntry, reposConfig *svnconfig.ReposConfig) error {
+	hooksDir := filepath.Join(repo, "hooks")
+	scripts := map[string][]byte{}
+	for i := range entry.Hooks {
+		if !svnconfig.IsValidHookName(entry.Hooks[i].Name) {
+			return fmt.Errorf("invalid hook name: %q", entry.Hooks[i].Name)
+		}
+		scripts[entry.Hooks[i].Name] = entry.Hooks[i].Script
+	}
+	builtin, err := u.installHookConfig(repo, entry, reposConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range svnconfig.HookNames {
+		script, declared := scripts[name]
+		switch {
+		case builtin[name]:
+			if declared {
+				if err := u.installHook(hooksDir, name+userHookSuffix, script); err != nil {
+					return err
+				}
+			} else {
+				if err := u.removeHook(hooksDir, name+userHookSuffix); err != nil {
+					return err
+				}
+			}
+			if err := u.installHook(hooksDir, name, u.builtinHookScript(name, declared)); err != nil {
+				return err
+			}
+		case declared:
+			if err := u.installHook(hooksDir, name, script); err != nil {
+				return err
+			}
+			if err := u.removeHook(hooksDir, name+userHookSuffix); err != nil {
+				return err
+			}
+		default:
+			if err := u.removeHook(hooksDir, name); err != nil {
+				return err
+			}
+			if err := u.removeHook(hooksDir, name+userHookSuffix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// installHookConfig writes svnconfig.HookConfig into the repository and returns the set of built-in hooks
+// that the repository needs.
+func (u *Updater) installHookConfig(repo string, entry *svnconfig.RepoEntry, reposConfig *svnconfig.ReposConfig) (map[string]bool, error) {
+	config := &svnconfig.HookConfig{
+		CommitPolicy: entry.CommitPolicy,
+		Quota:        u.quotaOf(entry, reposConfig.MaxTotalSize),
+		ReadOnly:     entry.ReadOnly,
+	}
+	builtin := map[string]bool{}
+	if config.CommitPolicy != nil || config.Quota != nil {
+		builtin["pre-commit"] = true
+	}
+	if config.ReadOnly != nil {
+		builtin["pre-commit"] = true
+		builtin["pre-lock"] = true
+	}
+	if entry.Source != nil && entry.Source.Mirror != nil {
+		config.Mirror = &svnconfig.Mirror{SyncUser: MirrorSyncUser}
+		// svnsync needs pre-revprop-change to copy revision properties, which SVN rejects without the hook.
+		builtin["start-commit"] = true
+		builtin["pre-lock"] = true
+		builtin["pre-revprop-change"] = true
+	}
+	if len(reposConfig.ReplicaUpdaterURLs) > 0 {
+		// Replicas would otherwise serve stale revisions and revision properties until their next synchronization.
+		config.Replicas = &svnconfig.Replicas{UpdaterURLs: reposConfig.ReplicaUpdaterURLs, TokenFile: u.StatusTokenFile}
+		builtin["post-commit"] = true
+		builtin["post-revprop-change"] = true
+	}
+	path := filepath.Join(repo, svnconfig.HookConfigPath)
+	if len(builtin) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return builtin, nil
+	}
+	if u.HookCommand == "" {
+		return nil, fmt.Errorf("built-in hooks are not available")
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return builtin, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return builtin, nil
+}
+
+// builtinHookScript returns a hook that runs HookCommand, which in turn runs the user's hook next to it if any.
+func (u *Updater) builtinHookScript(name string, hasUserHook bool) []byte {
+	args := []string{shellQuote(u.HookCommand)}
+	if hasUserHook {
+		// The user's hook is referred to relative to the hook itself since repositories can be moved
+		// (e.g. from LoadingDir to ReposDir), and SVN always runs hooks by their absolute paths.
+		args = append(args, "-user-hook", `"$0`+userHookSuffix+`"`)
+	}
+	args = append(args, name, `"$@"`)
+	return []byte("#!/bin/sh\n# Installed by svn-operator. Do not edit.\nexec " + strings.Join(args, " ") + "\n")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (u *Updater) installHook(hooksDir, name string, script []byte) error {
+	dest := filepath.Join(hooksDir, name)
+	if info, err := os.Stat(dest); err == nil && info.Mode().Perm() == hookMode {
+		current, err := ioutil.ReadFile(dest)
+		if err == nil && bytes.Equal(current, script) {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+	// Hooks are replaced atomically so that no commit runs a partially written hook.
+	tmp := filepath.Join(hooksDir, "."+name+".tmp")
+	if err := ioutil.WriteFile(tmp, script, hookMode); err != nil {
+		return err
+	}
+	// WriteFile does not change the permission of existing files, nor does it ignore umask.
+	if err := os.Chmod(tmp, hookMode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	u.Log.Info("installed hook", "hook", dest)
+	return nil
+}
+
+func (u *Updater) removeHook(hooksDir, name string) error {
+	path := filepath.Join(hooksDir, name)
+	err := os.Remove(path)
+	if err == nil {
+		u.Log.Info("removed hook", "hook", path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commitInfo is the author and the date of a revision.
+type commitInfo struct {
+	uuid     string
+	revision int64
+	author   string
+	date     time.Time
+}
+
+// svnlookDateLayout is the layout of dates printed by `svnlook info`, which is followed by a human-readable date
+// (e.g. `2021-05-01 12:34:56 +0900 (Sat, 01 May 2021)`).
+const svnlookDateLayout = "2006-01-02 15:04:05 -0700"
+
+// inspectRepository fills the details of the repository in status.
+func (u *Updater) inspectRepository(status *RepositoryStatus) error {
+	repo := filepath.Join(u.ReposDir, status.Name)
+	fsType, err := readFirstLine(filepath.Join(repo, "db", "fs-type"))
+	if err != nil {
+		return err
+	}
+	status.FSType = fsType
+	if format, err := readFirstLine(filepath.Join(repo, "db", "format")); err == nil {
+		// The first line of db/format is the format number, followed by options such as the layout of revisions.
+		status.FSFormat, _ = strconv.Atoi(strings.Fields(format + " ")[0])
+	}
+	out, err := u.commandOutput(u.SvnLook, "uuid", repo)
+	if err != nil {
+		return err
+	}
+	status.UUID = strings.TrimSpace(out)
+	youngest, err := u.youngestRevision(repo)
+	if err != nil {
+		return err
+	}
+	status.Revision = youngest
+
+	info, err := u.commitInfoOf(status.Name, repo, status.UUID, youngest)
+	if err != nil {
+		return err
+	}
+	status.LastChangedAuthor = info.author
+	if !info.date.IsZero() {
+		status.LastChangedTime = info.date.UTC().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// commitInfoOf returns the author and the date of the revision.
+// The results are cached since Status is requested frequently while new revisions are committed less often.
+func (u *Updater) commitInfoOf(name, repo, uuid string, revision int64) (*commitInfo, error) {
+	u.mu.Lock()
+	cached, ok := u.commitInfos[name]
+	u.mu.Unlock()
+	if ok && cached.uuid == uuid && cached.revision == revision {
+		return cached, nil
+	}
+
+	out, err := u.commandOutput(u.SvnLook, "info", "--revision", strconv.FormatInt(revision, 10), repo)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseSvnLookInfo(out)
+	if err != nil {
+		return nil, err
+	}
+	info.uuid = uuid
+	info.revision = revision
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.commitInfos == nil {
+		u.commitInfos = map[string]*commitInfo{}
+	}
+	u.commitInfos[name] = info
+	return info, nil
+}
+
+// parseSvnLookInfo parses the output of `svnlook info`, which consists of the author, the date,
+// the size of the log message and the log message.
+func parseSvnLookInfo(out string) (*commitInfo, error) {
+	lines := strings.SplitN(out, "\n", 3)
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("malformed output of svnlook info: %q", out)
+	}
+	info := &commitInfo{author: lines[0]}
+	if len(lines[1]) >= len(svnlookDateLayout) {
+		date, err := time.Parse(svnlookDateLayout, lines[1][:len(svnlookDateLayout)])
+		if err != nil {
+			return nil, err
+		}
+		info.date = date
+	}
+	return info, nil
+}
+
+func readFirstLine(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0]), nil
+}
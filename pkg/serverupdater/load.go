@@ -0,0 +1,337 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// Here is a list of phases of loading dump files.
+const (
+	LoadPhaseLoading   = "Loading"
+	LoadPhaseSucceeded = "Succeeded"
+	LoadPhaseFailed    = "Failed"
+)
+
+// LoadStatus is the progress of loading a dump file into a repository.
+type LoadStatus struct {
+	Name               string `json:"name"`
+	Phase              string `json:"phase"`
+	LastLoadedRevision int64  `json:"lastLoadedRevision"`
+	Message            string `json:"message,omitempty"`
+}
+
+const (
+	// maxRevision is used as the upper bound of the revision range to load the rest of dump files.
+	maxRevision = math.MaxInt32
+
+	// loadStatusInterval is the minimum interval to save the progress of loads.
+	loadStatusInterval = time.Second
+
+	// loadStatusDir is a directory in LoadingDir that the progress of loads is saved in.
+	// Names of repositories never start with a dot, so it is apart from the repositories being loaded.
+	loadStatusDir = ".status"
+)
+
+var committedRevisionPattern = regexp.MustCompile(`^------- Committed (?:revision|new rev) (\d+)`)
+
+// startLoad starts loading the dump file into the repository in background.
+//
+// The dump file is loaded into a repository in LoadingDir, which is moved to ReposDir on success,
+// so that nobody can access the repository until the load finishes.
+// If the load is interrupted, the next call to startLoad resumes it from the last loaded revision.
+// The hooks are installed right before the repository is moved to ReposDir.
+func (u *Updater) startLoad(entry *svnconfig.RepoEntry, reposConfig *svnconfig.ReposConfig) error {
+	name := entry.Name
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.loading[name]; ok {
+		return nil
+	}
+
+	staging := filepath.Join(u.LoadingDir, name)
+	if !fileExists(staging) {
+		if err := os.MkdirAll(u.LoadingDir, 0755); err != nil {
+			return err
+		}
+		if err := u.createEmptyRepository(staging, entry.Create); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if u.loading == nil {
+		u.loading = map[string]context.CancelFunc{}
+	}
+	u.loading[name] = cancel
+	go u.runLoad(ctx, staging, *entry, reposConfig)
+	return nil
+}
+
+func (u *Updater) runLoad(ctx context.Context, staging string, entry svnconfig.RepoEntry, reposConfig *svnconfig.ReposConfig) {
+	name, dump := entry.Name, entry.Source.Dump
+	log := u.Log.WithValues("repository", name, "dump", dump.Path)
+	defer func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		delete(u.loading, name)
+	}()
+
+	status := &LoadStatus{Name: name, Phase: LoadPhaseLoading}
+	log.Info("loading dump file")
+	err := u.load(ctx, staging, dump, status)
+	if err == nil {
+		// Loading a dump file into an empty repository overwrites the UUID.
+		err = u.enforceUUID(staging, entry.Create)
+	}
+	if err == nil {
+		// Hooks are not run while loading, but they must be in place as soon as the repository becomes accessible.
+		err = u.installHooks(staging, &entry, reposConfig)
+	}
+	if err == nil {
+		err = os.Rename(staging, filepath.Join(u.ReposDir, name))
+	}
+	if ctx.Err() != nil {
+		// The load has been discarded.
+		return
+	}
+	if err != nil {
+		log.Error(err, "failed to load dump file")
+		status.Phase = LoadPhaseFailed
+		status.Message = err.Error()
+	} else {
+		log.Info("loaded dump file", "revision", status.LastLoadedRevision)
+		status.Phase = LoadPhaseSucceeded
+		status.Message = ""
+	}
+	if err := u.writeLoadStatus(status); err != nil {
+		log.Error(err, "failed to save load status")
+	}
+}
+
+// load loads the dump file into the staging repository, updating status as revisions are committed.
+func (u *Updater) load(ctx context.Context, staging string, dump *svnconfig.DumpSource, status *LoadStatus) error {
+	youngest, err := u.youngestRevision(staging)
+	if err != nil {
+		return err
+	}
+	status.LastLoadedRevision = youngest
+	if err := u.writeLoadStatus(status); err != nil {
+		return err
+	}
+
+	dumpFile, err := os.Open(dump.Path)
+	if err != nil {
+		return err
+	}
+	defer dumpFile.Close()
+	stream, wait, err := u.decompress(ctx, dumpFile, dump.Compression)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"load", staging}
+	if youngest > 0 {
+		// Revisions in the dump file keep their numbers as long as they are loaded into a repository
+		// created from the same dump file, so we can skip revisions that have already been loaded.
+		args = append(args, "--revision", fmt.Sprintf("%d:%d", youngest+1, maxRevision))
+	}
+	cmd := exec.CommandContext(ctx, u.SvnAdmin, args...)
+	cmd.Stdin = stream
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	lastSaved := time.Now()
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		m := committedRevisionPattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		rev, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		status.LastLoadedRevision = rev
+		if time.Since(lastSaved) >= loadStatusInterval {
+			lastSaved = time.Now()
+			if err := u.writeLoadStatus(status); err != nil {
+				u.Log.Error(err, "failed to save load status", "repository", status.Name)
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		// The decompression has nothing to do any more, but we wait for it not to leave zombies.
+		_ = wait()
+		return fmt.Errorf("svnadmin load: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return wait()
+}
+
+// decompress returns a stream of the uncompressed dump file and a function to wait for the decompression to finish.
+func (u *Updater) decompress(ctx context.Context, r io.Reader, compression string) (io.Reader, func() error, error) {
+	br := bufio.NewReader(r)
+	if compression == "" || compression == svnconfig.CompressionAuto {
+		compression = detectCompression(br)
+	}
+	noWait := func() error { return nil }
+	switch compression {
+	case svnconfig.CompressionNone:
+		return br, noWait, nil
+	case svnconfig.CompressionGzip:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gr, gr.Close, nil
+	case svnconfig.CompressionBzip2:
+		return bzip2.NewReader(br), noWait, nil
+	case svnconfig.CompressionXz:
+		// There is no xz decoder in the standard library.
+		cmd := exec.CommandContext(ctx, u.Xz, "--decompress", "--stdout")
+		cmd.Stdin = br
+		stderr := bytes.NewBuffer(nil)
+		cmd.Stderr = stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		wait := func() error {
+			if err := cmd.Wait(); err != nil {
+				return fmt.Errorf("xz: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return nil
+		}
+		return stdout, wait, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown compression: %q", compression)
+	}
+}
+
+// detectCompression detects the compression format from the magic number.
+func detectCompression(br *bufio.Reader) string {
+	magic, _ := br.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return svnconfig.CompressionGzip
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return svnconfig.CompressionBzip2
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return svnconfig.CompressionXz
+	default:
+		return svnconfig.CompressionNone
+	}
+}
+
+func (u *Updater) youngestRevision(repo string) (int64, error) {
+	out, err := u.commandOutput(u.SvnLook, "youngest", repo)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
+
+// discardLoad stops the load of the repository and removes its intermediate state.
+func (u *Updater) discardLoad(name string) error {
+	u.mu.Lock()
+	cancel, ok := u.loading[name]
+	u.mu.Unlock()
+	if ok {
+		cancel()
+		return fmt.Errorf("waiting for the load of %q to stop", name)
+	}
+	if err := os.RemoveAll(filepath.Join(u.LoadingDir, name)); err != nil {
+		return err
+	}
+	if err := os.Remove(u.loadStatusPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (u *Updater) loadStatusPath(name string) string {
+	return filepath.Join(u.LoadingDir, loadStatusDir, name+".json")
+}
+
+func (u *Updater) writeLoadStatus(status *LoadStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	path := u.loadStatusPath(status.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadStatuses returns the progress of all loads, including finished ones.
+func (u *Updater) loadStatuses() ([]LoadStatus, error) {
+	if u.LoadingDir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(u.LoadingDir, loadStatusDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]LoadStatus, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var status LoadStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// MirrorSyncUser is the user that `svnsync` commits to mirrors as.
+// The built-in hooks of mirrors reject writes by other users.
+// It contains an underscore so that no SVNUser can have the same name.
+const MirrorSyncUser = "svn_operator_mirror"
+
+// mirrorStatusPath is a path relative to a repository to the file that keeps its MirrorStatus.
+// It is kept inside the repository so that it is archived or deleted along with the repository.
+const mirrorStatusPath = "conf/svn-operator-mirror.json"
+
+// MirrorStatus is the progress of synchronizing a mirror with its upstream repository.
+type MirrorStatus struct {
+	Name string `json:"name"`
+	// LastSyncedRevision is the youngest revision after the last successful synchronization.
+	LastSyncedRevision int64 `json:"lastSyncedRevision"`
+	// LastSyncTime is when the last successful synchronization finished in RFC 3339.
+	LastSyncTime string `json:"lastSyncTime,omitempty"`
+	// LastAttemptTime is when the last synchronization started in RFC 3339.
+	LastAttemptTime string `json:"lastAttemptTime,omitempty"`
+	// Message is the reason of the failure of the last synchronization.
+	Message string `json:"message,omitempty"`
+}
+
+// SyncMirrors starts synchronizing mirrors declared in ReposConfig whose intervals have elapsed.
+// This is called more often than the intervals of mirrors so that they are synchronized on time.
+func (u *Updater) SyncMirrors() error {
+	reposConfig, _, err := u.readReposConfig()
+	if err != nil {
+		return err
+	}
+	return u.syncMirrors(reposConfig)
+}
+
+func (u *Updater) syncMirrors(reposConfig *svnconfig.ReposConfig) error {
+	for i := range reposConfig.Repositories {
+		entry := &reposConfig.Repositories[i]
+		if entry.Source == nil || entry.Source.Mirror == nil {
+			continue
+		}
+		if entry.ReadOnly != nil {
+			// The built-in hooks reject svnsync as well until the repository becomes writable again.
+			continue
+		}
+		if _, _, err := u.startMirror(entry, false); err != nil {
+			return fmt.Errorf("failed to synchronize mirror %q: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// mirrorRun is a synchronization of a mirror running in background.
+type mirrorRun struct {
+	cancel context.CancelFunc
+	// done is closed when the synchronization finishes.
+	done chan struct{}
+}
+
+// startMirror starts synchronizing the mirror in background unless its interval has not elapsed,
+// or regardless of the interval if now is true.
+// It returns the running synchronization, or nil if there is none. started is false if it has already been running.
+func (u *Updater) startMirror(entry *svnconfig.RepoEntry, now bool) (run *mirrorRun, started bool, err error) {
+	name := entry.Name
+	repo := filepath.Join(u.ReposDir, name)
+	if !fileExists(repo) {
+		return nil, false, nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if run, ok := u.mirroring[name]; ok {
+		return run, false, nil
+	}
+	status, err := readMirrorStatus(repo)
+	if err != nil {
+		return nil, false, err
+	}
+	if status == nil {
+		status = &MirrorStatus{Name: name}
+	}
+	if last, err := time.Parse(time.RFC3339, status.LastAttemptTime); err == nil && !now {
+		interval := time.Duration(entry.Source.Mirror.IntervalSeconds) * time.Second
+		if time.Since(last) < interval {
+			return nil, false, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if u.mirroring == nil {
+		u.mirroring = map[string]*mirrorRun{}
+	}
+	run = &mirrorRun{cancel: cancel, done: make(chan struct{})}
+	u.mirroring[name] = run
+	go u.runMirror(ctx, repo, *entry.Source.Mirror, status, run.done)
+	return run, true, nil
+}
+
+func (u *Updater) runMirror(ctx context.Context, repo string, mirror svnconfig.MirrorSource, status *MirrorStatus, done chan struct{}) {
+	log := u.Log.WithValues("repository", status.Name, "url", mirror.URL)
+	defer func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		delete(u.mirroring, status.Name)
+		close(done)
+	}()
+
+	status.LastAttemptTime = time.Now().UTC().Format(time.RFC3339)
+	log.Info("synchronizing mirror")
+	err := u.mirror(ctx, repo, &mirror)
+	if ctx.Err() != nil {
+		// The mirror is being deleted.
+		return
+	}
+	if err == nil {
+		var rev int64
+		rev, err = u.youngestRevision(repo)
+		if err == nil {
+			status.LastSyncedRevision = rev
+		}
+	}
+	if err != nil {
+		log.Error(err, "failed to synchronize mirror")
+		status.Message = err.Error()
+	} else {
+		log.Info("synchronized mirror", "revision", status.LastSyncedRevision)
+		status.LastSyncTime = time.Now().UTC().Format(time.RFC3339)
+		status.Message = ""
+	}
+	if err := writeMirrorStatus(repo, status); err != nil {
+		log.Error(err, "failed to save mirror status")
+	}
+}
+
+// mirror initializes the repository as a mirror of the upstream repository if it has not been,
+// and copies revisions that it does not have yet.
+func (u *Updater) mirror(ctx context.Context, repo string, mirror *svnconfig.MirrorSource) error {
+	args, cleanup, err := u.svnsyncArgsOf(ctx, mirror)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	dest := "file://" + repo
+	switch from := u.mirroredURLOf(ctx, repo); from {
+	case "":
+		initArgs := append([]string{"initialize", dest, mirror.URL}, args...)
+		if err := u.runSvnsync(ctx, initArgs...); err != nil {
+			return err
+		}
+	case mirror.URL:
+	default:
+		return fmt.Errorf("the repository is a mirror of %s, not %s", from, mirror.URL)
+	}
+	return u.runSvnsync(ctx, append([]string{"synchronize", dest}, args...)...)
+}
+
+// svnsyncArgsOf returns the options of `svnsync` to access the upstream repository,
+// along with a function to remove the files that the options refer to.
+//
+// The password is not passed as an argument, which anyone in the container could read.
+// Instead, it is written to the authentication cache in a temporary configuration directory
+// that only the server-updater can read. `--no-auth-cache` keeps svnsync from writing the cache, not from reading it.
+func (u *Updater) svnsyncArgsOf(ctx context.Context, mirror *svnconfig.MirrorSource) ([]string, func(), error) {
+	args := []string{"--non-interactive", "--no-auth-cache", "--sync-username", MirrorSyncUser}
+	nop := func() {}
+	if mirror.CredentialsDir == "" {
+		return args, nop, nil
+	}
+	username, err := ioutil.ReadFile(filepath.Join(mirror.CredentialsDir, svnconfig.MirrorCredentialsUsername))
+	if err != nil {
+		return nil, nop, err
+	}
+	password, err := ioutil.ReadFile(filepath.Join(mirror.CredentialsDir, svnconfig.MirrorCredentialsPassword))
+	if err != nil {
+		return nil, nop, err
+	}
+	realm, err := u.realmOf(ctx, mirror.URL)
+	if err != nil {
+		return nil, nop, fmt.Errorf("failed to find the authentication realm of %s: %w", mirror.URL, err)
+	}
+	// TempDir creates the directory with 0700.
+	configDir, err := ioutil.TempDir("", "svnsync-")
+	if err != nil {
+		return nil, nop, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(configDir); err != nil {
+			u.Log.Error(err, "failed to remove svnsync config directory", "directory", configDir)
+		}
+	}
+	user := strings.TrimRight(string(username), "\r\n")
+	if realm != "" {
+		if err := writeSimpleCredentials(configDir, realm, user, strings.TrimRight(string(password), "\r\n")); err != nil {
+			cleanup()
+			return nil, nop, err
+		}
+	}
+	return append(args, "--config-dir", configDir, "--source-username", user), cleanup, nil
+}
+
+// basicRealmPattern matches the realm in the WWW-Authenticate header of Basic authentication.
+var basicRealmPattern = regexp.MustCompile(`(?i)^Basic\s+realm="([^"]*)"`)
+
+// realmOf returns the realm string that Subversion keys the credentials of the upstream repository by,
+// or an empty string if the repository can be read without credentials.
+func (u *Updater) realmOf(ctx context.Context, rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	port := parsed.Port()
+	switch {
+	case parsed.Scheme != "http" && parsed.Scheme != "https":
+		return "", fmt.Errorf("credentials are only supported for http and https URLs")
+	case port == "" && parsed.Scheme == "http":
+		port = "80"
+	case port == "":
+		port = "443"
+	}
+	if u.TimeoutMs > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(u.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	req, err := http.NewRequest(http.MethodOptions, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		if m := basicRealmPattern.FindStringSubmatch(header); m != nil {
+			// This is how Subversion builds realm strings of HTTP servers.
+			return fmt.Sprintf("<%s://%s:%s> %s", parsed.Scheme, strings.ToLower(parsed.Hostname()), port, m[1]), nil
+		}
+	}
+	return "", fmt.Errorf("the server does not support Basic authentication")
+}
+
+// writeSimpleCredentials writes the username and password to the authentication cache of Subversion
+// in configDir, in the same format as `svn` caches plaintext passwords.
+func writeSimpleCredentials(configDir, realm, username, password string) error {
+	dir := filepath.Join(configDir, "auth", "svn.simple")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, kv := range [][2]string{
+		{"passtype", "simple"},
+		{"password", password},
+		{"svn:realmstring", realm},
+		{"username", username},
+	} {
+		fmt.Fprintf(buf, "K %d\n%s\nV %d\n%s\n", len(kv[0]), kv[0], len(kv[1]), kv[1])
+	}
+	buf.WriteString("END\n")
+	sum := md5.Sum([]byte(realm))
+	return ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])), buf.Bytes(), 0600)
+}
+
+// mirroredURLOf returns the URL of the upstream repository that `svnsync initialize` has recorded in the repository,
+// or an empty string if the repository has not been initialized as a mirror.
+func (u *Updater) mirroredURLOf(ctx context.Context, repo string) string {
+	// svnlook fails if the property does not exist, which is not worth logging.
+	out, err := exec.CommandContext(ctx, u.SvnLook, "propget", "--revprop", "-r", "0", repo, "svn:sync-from-url").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// runSvnsync runs `svnsync` without TimeoutMs, since synchronizations can take as long as the history of the upstream.
+// The error contains the standard error of svnsync but not the arguments.
+func (u *Updater) runSvnsync(ctx context.Context, args ...string) error {
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(ctx, u.SvnSync, args...)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("svnsync %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// stopMirror stops the synchronization of the mirror, if any, before the repository is deleted.
+func (u *Updater) stopMirror(name string) error {
+	u.mu.Lock()
+	run, ok := u.mirroring[name]
+	u.mu.Unlock()
+	if ok {
+		run.cancel()
+		return fmt.Errorf("waiting for the synchronization of %q to stop", name)
+	}
+	return nil
+}
+
+func readMirrorStatus(repo string) (*MirrorStatus, error) {
+	data, err := ioutil.ReadFile(filepath.Join(repo, mirrorStatusPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	status := &MirrorStatus{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func writeMirrorStatus(repo string, status *MirrorStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(repo, mirrorStatusPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
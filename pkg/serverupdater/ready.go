@@ -0,0 +1,96 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// ReadyPath is a path of the endpoint that tells whether the server is ready to serve requests.
+const ReadyPath = "/ready"
+
+// readyTimeout is a timeout to check whether the Apache HTTP Server responds.
+const readyTimeout = 5 * time.Second
+
+// Ready returns an error if the server is not ready to serve requests.
+//
+// Replicas are not ready until every repository on the primary has been synchronized at least once,
+// since clients would otherwise be told that revisions they have seen on the primary do not exist.
+func (u *Updater) Ready(ctx context.Context) error {
+	if u.ServerURL != "" {
+		if err := u.checkServer(ctx); err != nil {
+			return err
+		}
+	}
+	if !u.isReplica() {
+		return nil
+	}
+	u.mu.Lock()
+	known := u.primaryRepositories != nil
+	u.mu.Unlock()
+	if !known {
+		return fmt.Errorf("repositories on the primary are not known yet")
+	}
+	reposConfig, _, err := u.readReposConfig()
+	if err != nil {
+		return err
+	}
+	for _, entry := range reposConfig.Repositories {
+		mirror, err := readMirrorStatus(filepath.Join(u.ReposDir, entry.Name))
+		if err != nil {
+			return err
+		}
+		if mirror == nil || mirror.LastSyncTime == "" {
+			return fmt.Errorf("repository %s has not been synchronized with the primary yet", entry.Name)
+		}
+	}
+	return nil
+}
+
+// checkServer returns an error if the Apache HTTP Server does not respond successfully.
+func (u *Updater) checkServer(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, u.ServerURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code from the Apache HTTP Server: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ServeReady responds with 200 if the server is ready to serve requests, and with 503 otherwise.
+// The reason is only logged, since the endpoint is not protected by StatusToken for the kubelet.
+func (u *Updater) ServeReady(w http.ResponseWriter, r *http.Request) {
+	if err := u.Ready(r.Context()); err != nil {
+		u.Log.V(1).Info("not ready", "reason", err.Error())
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// primaryStatusTimeout is a timeout to fetch Status from the primary.
+const primaryStatusTimeout = 10 * time.Second
+
+// SyncPath is a path of the endpoint that tells replicas to synchronize a repository with the primary.
+const SyncPath = "/sync"
+
+// isReplica returns true if the server is a read-only replica of the primary.
+func (u *Updater) isReplica() bool {
+	return u.PrimaryURL != ""
+}
+
+// refreshPrimaryRepositories fetches the repositories that exist on the primary along with their UUIDs.
+// The last known ones are kept if the primary is not available.
+func (u *Updater) refreshPrimaryRepositories() error {
+	ctx, cancel := context.WithTimeout(context.Background(), primaryStatusTimeout)
+	defer cancel()
+	status, err := (&Client{}).Status(ctx, u.PrimaryUpdaterURL, u.StatusToken)
+	if err != nil {
+		return err
+	}
+	repos := make(map[string]string, len(status.Repositories))
+	for _, r := range status.Repositories {
+		repos[r.Name] = r.UUID
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.primaryRepositories = repos
+	return nil
+}
+
+// replicaConfigOf turns every repository that exists on the primary into a mirror of it.
+//
+// Commits have already passed the hooks, policies and quotas on the primary, so replicas have none of them.
+// Repositories are given the same UUIDs as the ones on the primary, since clients reject servers that
+// switch UUIDs, which happens when the Service balances requests between the primary and replicas.
+func (u *Updater) replicaConfigOf(reposConfig *svnconfig.ReposConfig) *svnconfig.ReposConfig {
+	u.mu.Lock()
+	primary := u.primaryRepositories
+	u.mu.Unlock()
+
+	repos := make([]svnconfig.RepoEntry, 0, len(reposConfig.Repositories))
+	for _, entry := range reposConfig.Repositories {
+		uuid, ok := primary[entry.Name]
+		if !ok {
+			// Empty repositories would be served until the primary creates the repository.
+			continue
+		}
+		create := &svnconfig.CreateOptions{}
+		if entry.Create != nil {
+			*create = *entry.Create
+		}
+		if uuid != "" {
+			create.UUID = uuid
+		}
+		repos = append(repos, svnconfig.RepoEntry{
+			Name: entry.Name,
+			Source: &svnconfig.Source{
+				Mirror: &svnconfig.MirrorSource{
+					URL:             u.PrimaryURL + "/" + entry.Name,
+					CredentialsDir:  u.ReplicationCredentialsDir,
+					IntervalSeconds: int64(u.ReplicaInterval / time.Second),
+				},
+			},
+			Create: create,
+		})
+	}
+	return &svnconfig.ReposConfig{Repositories: repos, Deletions: reposConfig.Deletions}
+}
+
+// SyncRepository synchronizes the repository with the primary without waiting for ReplicaInterval,
+// and returns once the repository has caught up with the primary.
+// The primary calls this through SyncPath whenever the repository is changed.
+func (u *Updater) SyncRepository(ctx context.Context, name string) error {
+	if !u.isReplica() {
+		return fmt.Errorf("the server is not a replica")
+	}
+	entry, err := u.replicaEntryOf(name)
+	if err != nil {
+		return err
+	}
+	if entry == nil || !fileExists(filepath.Join(u.ReposDir, name)) {
+		// The repository has just been created on the primary.
+		if err := u.SyncRepositories(); err != nil {
+			return err
+		}
+		if entry, err = u.replicaEntryOf(name); err != nil {
+			return err
+		} else if entry == nil {
+			return fmt.Errorf("repository %q does not exist on the primary", name)
+		}
+	}
+	for {
+		run, started, err := u.startMirror(entry, true)
+		if err != nil {
+			return err
+		}
+		if run == nil {
+			return fmt.Errorf("repository %q has not been created yet", name)
+		}
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if started {
+			break
+		}
+		// The synchronization that has been running may have started before the change.
+	}
+	status, err := readMirrorStatus(filepath.Join(u.ReposDir, name))
+	if err != nil {
+		return err
+	}
+	if status != nil && status.Message != "" {
+		return errors.New(status.Message)
+	}
+	return nil
+}
+
+// replicaEntryOf returns the entry of the repository that the replica copies from the primary, or nil if there is none.
+func (u *Updater) replicaEntryOf(name string) (*svnconfig.RepoEntry, error) {
+	reposConfig, _, err := u.readReposConfig()
+	if err != nil {
+		return nil, err
+	}
+	for i := range reposConfig.Repositories {
+		if reposConfig.Repositories[i].Name == name {
+			return &reposConfig.Repositories[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ServeSync synchronizes the repository given by the `repository` query parameter for clients that have StatusToken.
+func (u *Updater) ServeSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !u.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := r.URL.Query().Get("repository")
+	if name == "" || filepath.Base(name) != name {
+		http.Error(w, fmt.Sprintf("invalid repository name: %q", name), http.StatusBadRequest)
+		return
+	}
+	if err := u.SyncRepository(r.Context(), name); err != nil {
+		u.Log.Error(err, "failed to synchronize repository", "repository", name)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Sync tells server-updater of a replica listening on baseURL to synchronize the repository with the primary,
+// and waits for it to finish. token is the StatusToken of server-updater.
+func (c *Client) Sync(ctx context.Context, baseURL, token, repository string) error {
+	req, err := http.NewRequest(http.MethodPost, baseURL+SyncPath+"?repository="+url.QueryEscape(repository), nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code from server-updater: %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// NotifyReplicas tells every replica to synchronize the repository, and returns once all of them have finished.
+// The built-in post-commit and post-revprop-change hooks of the primary call this.
+func NotifyReplicas(ctx context.Context, replicas *svnconfig.Replicas, repository string) error {
+	var token string
+	if replicas.TokenFile != "" {
+		data, err := ioutil.ReadFile(replicas.TokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	errs := make([]error, len(replicas.UpdaterURLs))
+	var wg sync.WaitGroup
+	for i, baseURL := range replicas.UpdaterURLs {
+		wg.Add(1)
+		go func(i int, baseURL string) {
+			defer wg.Done()
+			if err := (&Client{}).Sync(ctx, baseURL, token, repository); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", baseURL, err)
+			}
+		}(i, baseURL)
+	}
+	wg.Wait()
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("failed to synchronize replicas: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StatusPath is a path of the endpoint that serves Status.
+const StatusPath = "/status"
+
+// Status is a report of the current state of an SVN server.
+type Status struct {
+	// ReposConfigHash is a hash of the ReposConfig that the server has applied.
+	// It is empty until the server applies ReposConfig for the first time.
+	ReposConfigHash string `json:"reposConfigHash"`
+
+	// Repositories is a list of repositories that exist in the server.
+	Repositories []RepositoryStatus `json:"repositories"`
+
+	// Loads is a list of repositories that are being loaded or have been loaded from dump files.
+	Loads []LoadStatus `json:"loads,omitempty"`
+
+	// Mirrors is a list of mirrors that have been synchronized at least once.
+	Mirrors []MirrorStatus `json:"mirrors,omitempty"`
+
+	// TotalSize is the last measured size of all repositories in bytes, including archived ones
+	// and ones being loaded.
+	TotalSize int64 `json:"totalSize,omitempty"`
+}
+
+// RepositoryStatus is a report of the current state of an SVN repository.
+type RepositoryStatus struct {
+	Name string `json:"name"`
+
+	// Size is the last measured size of the repository in bytes.
+	Size int64 `json:"size,omitempty"`
+
+	// Revision is the youngest revision.
+	Revision int64 `json:"revision"`
+	// UUID is the UUID of the repository.
+	UUID string `json:"uuid,omitempty"`
+	// FSType is the type of the filesystem (e.g. fsfs).
+	FSType string `json:"fsType,omitempty"`
+	// FSFormat is the format number of the filesystem.
+	FSFormat int `json:"fsFormat,omitempty"`
+	// LastChangedAuthor is the author of the youngest revision.
+	LastChangedAuthor string `json:"lastChangedAuthor,omitempty"`
+	// LastChangedTime is the date of the youngest revision in RFC 3339.
+	LastChangedTime string `json:"lastChangedTime,omitempty"`
+}
+
+// HasRepository returns true if the server has the given repository.
+func (s *Status) HasRepository(name string) bool {
+	return s.RepositoryStatusOf(name) != nil
+}
+
+// RepositoryStatusOf returns the status of the given repository, or nil if the server does not have it.
+func (s *Status) RepositoryStatusOf(name string) *RepositoryStatus {
+	for i := range s.Repositories {
+		if s.Repositories[i].Name == name {
+			return &s.Repositories[i]
+		}
+	}
+	return nil
+}
+
+// LoadStatusOf returns the progress of loading the given repository, or nil if there is no load.
+func (s *Status) LoadStatusOf(name string) *LoadStatus {
+	for i := range s.Loads {
+		if s.Loads[i].Name == name {
+			return &s.Loads[i]
+		}
+	}
+	return nil
+}
+
+// MirrorStatusOf returns the progress of synchronizing the given mirror, or nil if it has never been synchronized.
+func (s *Status) MirrorStatusOf(name string) *MirrorStatus {
+	for i := range s.Mirrors {
+		if s.Mirrors[i].Name == name {
+			return &s.Mirrors[i]
+		}
+	}
+	return nil
+}
+
+// HashReposConfig returns a hash of the content of ReposConfig, which is used to determine
+// whether the server has applied the ReposConfig.
+func HashReposConfig(reposConfig string) string {
+	sum := sha256.Sum256([]byte(reposConfig))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status returns the current state of the SVN server.
+func (u *Updater) Status() (*Status, error) {
+	u.mu.Lock()
+	hash := u.appliedReposConfigHash
+	sizes := u.sizes
+	totalSize := u.totalSize
+	u.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(u.ReposDir)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]RepositoryStatus, 0, len(entries))
+	var mirrors []MirrorStatus
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		repo := RepositoryStatus{Name: e.Name(), Size: sizes[e.Name()]}
+		if err := u.inspectRepository(&repo); err != nil {
+			// The rest of the repositories are still worth reporting.
+			u.Log.Error(err, "failed to inspect repository", "repository", e.Name())
+		}
+		repos = append(repos, repo)
+		mirror, err := readMirrorStatus(filepath.Join(u.ReposDir, e.Name()))
+		if err != nil {
+			u.Log.Error(err, "failed to read mirror status", "repository", e.Name())
+		} else if mirror != nil {
+			mirrors = append(mirrors, *mirror)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].Name < mirrors[j].Name })
+	loads, err := u.loadStatuses()
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		ReposConfigHash: hash,
+		Repositories:    repos,
+		Loads:           loads,
+		Mirrors:         mirrors,
+		TotalSize:       totalSize,
+	}, nil
+}
+
+// ServeHTTP serves Status as JSON to clients that have StatusToken.
+func (u *Updater) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !u.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	status, err := u.Status()
+	if err != nil {
+		u.Log.Error(err, "failed to get status")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		u.Log.Error(err, "failed to write status")
+	}
+}
+
+// authorized returns true if the request has StatusToken as its bearer token.
+func (u *Updater) authorized(r *http.Request) bool {
+	if u.StatusToken == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(u.StatusToken)) == 1
+}
+
+// Client fetches Status from server-updater.
+type Client struct {
+	// HTTPClient is used to send requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Status fetches Status from server-updater listening on baseURL (e.g. http://svnserver-0.svnserver.default.svc:8081).
+// token is the StatusToken of server-updater.
+func (c *Client) Status(ctx context.Context, baseURL, token string) (*Status, error) {
+	req, err := http.NewRequest(http.MethodGet, baseURL+StatusPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from server-updater: %d", resp.StatusCode)
+	}
+	status := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -22,11 +22,16 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -42,51 +47,248 @@ type Updater struct {
 
 	// SvnAdmin is a path to the `svnadmin` command.
 	SvnAdmin string
+	// SvnMucc is a path to the `svnmucc` command.
+	SvnMucc string
+	// SvnLook is a path to the `svnlook` command.
+	SvnLook string
+	// SvnSync is a path to the `svnsync` command.
+	SvnSync string
+	// Xz is a path to the `xz` command.
+	Xz string
+	// HookCommand is a path to the `svn-hook` command, which implements the built-in hooks.
+	HookCommand string
 
 	// ReposConfig is a path to a set of definitions of repositories that the server has.
 	ReposConfig string
 
 	// ReposDir is a path to a directory that SVN repositories resides in.
 	ReposDir string
+	// TrashDir is a path to a directory that archived repositories are moved to.
+	TrashDir string
+	// LoadingDir is a path to a directory that repositories being loaded from dump files reside in.
+	LoadingDir string
+
+	// StatusToken is a bearer token that clients must send to read Status, since Status tells the names
+	// and the last commits of repositories that users may not be allowed to read.
+	// Status is served to anyone if empty.
+	StatusToken string
+
+	// ServerURL is the URL of the Apache HTTP Server in the same Pod (e.g. http://localhost/),
+	// which must respond before the server is ready. It is not checked if empty.
+	ServerURL string
+
+	// PrimaryURL is the URL of the parent of repositories on the primary (e.g. http://svnserver-0.svnserver/repos)
+	// if the server is a read-only replica. Replicas copy all repositories from the primary with svnsync.
+	PrimaryURL string
+	// PrimaryUpdaterURL is the base URL of server-updater running in the primary.
+	PrimaryUpdaterURL string
+	// ReplicationCredentialsDir is a path to a directory that contains `username` and `password` files
+	// to read repositories on the primary.
+	ReplicationCredentialsDir string
+	// ReplicaInterval is the interval between synchronizations of repositories on replicas.
+	// Replicas are also told to synchronize repositories as soon as they are changed on the primary,
+	// so this is a fallback in case they miss it.
+	ReplicaInterval time.Duration
+	// StatusTokenFile is a path to the file that contains StatusToken, which the built-in hooks of the primary
+	// read to tell replicas to synchronize repositories.
+	StatusTokenFile string
 
 	// Log is a logger.
 	Log logr.Logger
 
 	// TimeoutMs is a timeout in milliseconds to run command.
 	TimeoutMs int
+
+	// syncMu serializes SyncRepositories, which replicas also call when they are told that repositories are changed.
+	syncMu sync.Mutex
+
+	mu sync.Mutex
+	// appliedReposConfigHash is a hash of the ReposConfig that is successfully applied.
+	appliedReposConfigHash string
+	// loading is a set of cancel functions of loads running in background, keyed by repository names.
+	loading map[string]context.CancelFunc
+	// mirroring is a set of synchronizations of mirrors running in background, keyed by repository names.
+	mirroring map[string]*mirrorRun
+	// sizes is the last measured sizes of repositories in bytes, keyed by repository names.
+	sizes map[string]int64
+	// totalSize is the last measured size of all repositories in bytes.
+	totalSize int64
+	// commitInfos is a cache of the last commits of repositories, keyed by repository names.
+	commitInfos map[string]*commitInfo
+	// primaryRepositories is the last known UUIDs of repositories on the primary, keyed by repository names.
+	// It is used only by replicas.
+	primaryRepositories map[string]string
 }
 
 func (u *Updater) OnConfigChanged() error {
 	if err := u.reloadApache(); err != nil {
 		return err
 	}
-	if err := u.createRepositories(); err != nil {
+	return u.SyncRepositories()
+}
+
+// SyncRepositories creates and deletes repositories, installs their hooks and sets their UUIDs according to ReposConfig.
+// This is called periodically as well as on config changes, to retry operations that have failed or been postponed.
+func (u *Updater) SyncRepositories() error {
+	u.syncMu.Lock()
+	defer u.syncMu.Unlock()
+	if u.isReplica() {
+		if err := u.refreshPrimaryRepositories(); err != nil {
+			// The repositories that are known to exist are still worth synchronizing.
+			u.Log.Error(err, "failed to fetch the status of the primary")
+		}
+	}
+	reposConfig, rawReposConfig, err := u.readReposConfig()
+	if err != nil {
+		return err
+	}
+	if err := u.createRepositories(reposConfig); err != nil {
 		return err
 	}
+	if err := u.syncHooks(reposConfig); err != nil {
+		return err
+	}
+	if err := u.syncUUIDs(reposConfig); err != nil {
+		return err
+	}
+	// Mirrors are initialized only after their hooks are installed, since svnsync needs pre-revprop-change.
+	if err := u.syncMirrors(reposConfig); err != nil {
+		return err
+	}
+	if err := u.deleteRepositories(reposConfig); err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.appliedReposConfigHash = HashReposConfig(string(rawReposConfig))
 	return nil
 }
 
+// readReposConfig returns ReposConfig along with its raw content.
+// Replicas get the configuration to copy the repositories from the primary instead.
+func (u *Updater) readReposConfig() (*svnconfig.ReposConfig, []byte, error) {
+	rawReposConfig, err := ioutil.ReadFile(u.ReposConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reposConfig svnconfig.ReposConfig
+	if err := yaml.Unmarshal(rawReposConfig, &reposConfig); err != nil {
+		return nil, nil, err
+	}
+	if u.isReplica() {
+		return u.replicaConfigOf(&reposConfig), rawReposConfig, nil
+	}
+	return &reposConfig, rawReposConfig, nil
+}
+
 func (u *Updater) reloadApache() error {
 	return u.runCommand(u.InitdScript, "reload")
 }
 
-func (u *Updater) createRepositories() error {
-	reposConfigFile, err := os.Open(u.ReposConfig)
-	if err != nil {
+func (u *Updater) createRepositories(reposConfig *svnconfig.ReposConfig) error {
+	for i := range reposConfig.Repositories {
+		err := u.createRepository(&reposConfig.Repositories[i], reposConfig)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *Updater) createRepository(entry *svnconfig.RepoEntry, reposConfig *svnconfig.ReposConfig) error {
+	dest := filepath.Join(u.ReposDir, entry.Name)
+	if fileExists(dest) {
+		return nil
+	}
+	if entry.Source != nil && entry.Source.Dump != nil {
+		return u.startLoad(entry, reposConfig)
+	}
+	if err := u.createEmptyRepository(dest, entry.Create); err != nil {
+		return err
+	}
+	if entry.Initial == nil {
+		return nil
+	}
+	if err := u.commitInitialContent(dest, entry.Initial); err != nil {
+		// The initial content is committed only to newly created repositories,
+		// so we remove the repository to try again from scratch next time.
+		if rmErr := os.RemoveAll(dest); rmErr != nil {
+			u.Log.Error(rmErr, "failed to remove repository", "repository", entry.Name)
+		}
 		return err
 	}
-	defer reposConfigFile.Close()
-	rawReposConfig, err := ioutil.ReadAll(reposConfigFile)
+	return nil
+}
+
+// InitialCommitAuthor is the author of the revision 1 that contains the initial content of repositories.
+const InitialCommitAuthor = "svn-operator"
+
+// commitInitialContent commits the given content to the repository as a single revision.
+func (u *Updater) commitInitialContent(repo string, c *svnconfig.InitialContent) error {
+	tmpDir, err := ioutil.TempDir("", "svn-initial-content")
 	if err != nil {
 		return err
 	}
-	var reposConfig svnconfig.ReposConfig
-	err = yaml.Unmarshal(rawReposConfig, &reposConfig)
+	defer os.RemoveAll(tmpDir)
+
+	dirs, err := initialDirectories(c)
 	if err != nil {
 		return err
 	}
-	for i := range reposConfig.Repositories {
-		err = u.createRepository(reposConfig.Repositories[i].Name)
+	args := []string{
+		u.SvnMucc,
+		"--non-interactive",
+		"--username", InitialCommitAuthor,
+		"--message", "Initial content created by svn-operator",
+		"--root-url", "file://" + repo,
+	}
+	for _, dir := range dirs {
+		args = append(args, "mkdir", dir)
+	}
+	for i := range c.Files {
+		src := filepath.Join(tmpDir, strconv.Itoa(i))
+		if err := ioutil.WriteFile(src, c.Files[i].Content, 0644); err != nil {
+			return err
+		}
+		args = append(args, "put", src, c.Files[i].Path)
+	}
+	return u.runCommand(args...)
+}
+
+// initialDirectories returns the directories in c and the parent directories of files in c,
+// in the order that they should be created.
+func initialDirectories(c *svnconfig.InitialContent) ([]string, error) {
+	set := map[string]bool{}
+	addParents := func(p string) {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			set[dir] = true
+		}
+	}
+	for _, dir := range c.Directories {
+		if !svnconfig.IsValidRelativePath(dir) {
+			return nil, fmt.Errorf("invalid directory: %q", dir)
+		}
+		set[dir] = true
+		addParents(dir)
+	}
+	for i := range c.Files {
+		if !svnconfig.IsValidRelativePath(c.Files[i].Path) {
+			return nil, fmt.Errorf("invalid file: %q", c.Files[i].Path)
+		}
+		addParents(c.Files[i].Path)
+	}
+	dirs := make([]string, 0, len(set))
+	for dir := range set {
+		dirs = append(dirs, dir)
+	}
+	// Parent directories always come before their children since they are prefixes of them.
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func (u *Updater) deleteRepositories(reposConfig *svnconfig.ReposConfig) error {
+	for i := range reposConfig.Deletions {
+		err := u.deleteRepository(&reposConfig.Deletions[i])
 		if err != nil {
 			return err
 		}
@@ -94,12 +296,35 @@ func (u *Updater) createRepositories() error {
 	return nil
 }
 
-func (u *Updater) createRepository(name string) error {
-	dest := filepath.Join(u.ReposDir, name)
-	if fileExists(dest) {
+func (u *Updater) deleteRepository(d *svnconfig.Deletion) error {
+	if d.Name == "" || filepath.Base(d.Name) != d.Name {
+		return fmt.Errorf("invalid repository name: %q", d.Name)
+	}
+	log := u.Log.WithValues("repository", d.Name, "policy", d.Policy)
+	if err := u.discardLoad(d.Name); err != nil {
+		return err
+	}
+	if err := u.stopMirror(d.Name); err != nil {
+		return err
+	}
+	src := filepath.Join(u.ReposDir, d.Name)
+	if !fileExists(src) {
 		return nil
 	}
-	return u.runCommand(u.SvnAdmin, "create", dest)
+	switch d.Policy {
+	case svnconfig.DeletionPolicyArchive:
+		if err := os.MkdirAll(u.TrashDir, 0755); err != nil {
+			return err
+		}
+		dest := filepath.Join(u.TrashDir, fmt.Sprintf("%s-%s", d.Name, time.Now().UTC().Format("20060102T150405Z")))
+		log.Info("archiving repository", "destination", dest)
+		return os.Rename(src, dest)
+	case svnconfig.DeletionPolicyDelete:
+		log.Info("deleting repository")
+		return os.RemoveAll(src)
+	default:
+		return fmt.Errorf("unknown deletion policy: %q", d.Policy)
+	}
 }
 
 func (u *Updater) runCommand(cmd ...string) error {
@@ -124,6 +349,25 @@ func (u *Updater) runCommand(cmd ...string) error {
 	return nil
 }
 
+// commandOutput runs the command and returns its standard output.
+func (u *Updater) commandOutput(cmd ...string) (string, error) {
+	ctx := context.Background()
+	if u.TimeoutMs > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(u.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	stderr := bytes.NewBuffer(nil)
+	command := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	command.Stderr = stderr
+	out, err := command.Output()
+	if err != nil {
+		u.Log.Error(err, "command error", "command", strings.Join(cmd, " "), "stderr", stderr.String())
+		return "", err
+	}
+	return string(out), nil
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	if err == nil {
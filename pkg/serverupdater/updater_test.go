@@ -0,0 +1,915 @@
+package serverupdater_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/genkami/svn-operator/pkg/serverupdater"
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+var _ = Describe("Updater", func() {
+	var tmpDir string
+	var u *serverupdater.Updater
+	writeReposConfig := func(content string) {
+		Expect(ioutil.WriteFile(u.ReposConfig, []byte(content), 0644)).To(Succeed())
+	}
+	mkRepo := func(name string) {
+		Expect(os.MkdirAll(filepath.Join(u.ReposDir, name), 0755)).To(Succeed())
+	}
+	writeScript := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "serverupdater")
+		Expect(err).NotTo(HaveOccurred())
+		u = &serverupdater.Updater{
+			InitdScript: "true",
+			SvnAdmin:    writeScript("svnadmin", `mkdir -p "$2"`),
+			SvnMucc:     "false",
+			ReposConfig: filepath.Join(tmpDir, "Repos"),
+			ReposDir:    filepath.Join(tmpDir, "repos"),
+			TrashDir:    filepath.Join(tmpDir, "trash"),
+			LoadingDir:  filepath.Join(tmpDir, "loading"),
+			Log:         log.NullLogger{},
+		}
+		mkRepo("keep")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("deletions", func() {
+		const reposConfig = `repositories:
+- name: keep
+deletions:
+- name: archived
+  policy: Archive
+- name: deleted
+  policy: Delete
+`
+		BeforeEach(func() {
+			mkRepo("archived")
+			mkRepo("deleted")
+			writeReposConfig(reposConfig)
+			Expect(u.OnConfigChanged()).To(Succeed())
+		})
+
+		It("moves archived repositories to the trash", func() {
+			Expect(filepath.Join(u.ReposDir, "archived")).NotTo(BeADirectory())
+			archived, err := filepath.Glob(filepath.Join(u.TrashDir, "archived-*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archived).To(HaveLen(1))
+		})
+
+		It("removes deleted repositories", func() {
+			Expect(filepath.Join(u.ReposDir, "deleted")).NotTo(BeADirectory())
+			Expect(filepath.Join(u.TrashDir, "deleted")).NotTo(BeADirectory())
+		})
+
+		It("reports the remaining repositories and the applied configuration", func() {
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.ReposConfigHash).To(Equal(serverupdater.HashReposConfig(reposConfig)))
+			Expect(status.Repositories).To(Equal([]serverupdater.RepositoryStatus{{Name: "keep"}}))
+		})
+
+		It("does nothing when the repositories are already gone", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			archived, err := filepath.Glob(filepath.Join(u.TrashDir, "archived-*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archived).To(HaveLen(1))
+		})
+	})
+
+	Context("when a deletion has an invalid name", func() {
+		It("returns an error and does not report the configuration as applied", func() {
+			writeReposConfig(`repositories: []
+deletions:
+- name: ../keep
+  policy: Delete
+`)
+			Expect(u.OnConfigChanged()).NotTo(Succeed())
+			Expect(filepath.Join(u.ReposDir, "keep")).To(BeADirectory())
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.ReposConfigHash).To(BeEmpty())
+		})
+	})
+
+	Describe("initial content", func() {
+		var logFile string
+		BeforeEach(func() {
+			logFile = filepath.Join(tmpDir, "svnmucc.log")
+			u.SvnMucc = writeScript("svnmucc", `
+while [ $# -gt 0 ]; do
+  case "$1" in
+    put) echo "put $(cat "$2") $3"; shift 3;;
+    mkdir) echo "mkdir $2"; shift 2;;
+    *) shift;;
+  esac
+done >> `+logFile)
+			writeReposConfig(`repositories:
+- name: keep
+  initial:
+    directories: [trunk]
+- name: new
+  initial:
+    directories: [trunk, branches, tags]
+    files:
+    - path: trunk/docs/README
+      content: aGVsbG8=
+`)
+		})
+
+		It("is committed only to newly created repositories", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Expect(filepath.Join(u.ReposDir, "new")).To(BeADirectory())
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(log)).To(Equal(`mkdir branches
+mkdir tags
+mkdir trunk
+mkdir trunk/docs
+put hello trunk/docs/README
+`))
+		})
+
+		It("removes the repository if it fails to commit", func() {
+			u.SvnMucc = "false"
+			Expect(u.OnConfigChanged()).NotTo(Succeed())
+			Expect(filepath.Join(u.ReposDir, "new")).NotTo(BeADirectory())
+		})
+	})
+
+	Describe("dump files", func() {
+		const dump = "SVN-fs-dump-format-version: 2\n"
+		var dumpPath string
+		loadStatusOf := func(name string) *serverupdater.LoadStatus {
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			return status.LoadStatusOf(name)
+		}
+		loadPhaseOf := func(name string) func() string {
+			return func() string {
+				if s := loadStatusOf(name); s != nil {
+					return s.Phase
+				}
+				return ""
+			}
+		}
+		BeforeEach(func() {
+			u.SvnAdmin = writeScript("svnadmin", `
+case "$1" in
+  create) mkdir -p "$2";;
+  load) echo "$@" > "$2/args"; cat > "$2/dump"; echo "------- Committed revision 3 >>>";;
+esac
+`)
+			u.SvnLook = writeScript("svnlook", `cat "$2/youngest" 2>/dev/null || echo 0`)
+			buf := bytes.NewBuffer(nil)
+			w := gzip.NewWriter(buf)
+			_, err := w.Write([]byte(dump))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Close()).To(Succeed())
+			dumpPath = filepath.Join(tmpDir, "legacy.dump.gz")
+			Expect(ioutil.WriteFile(dumpPath, buf.Bytes(), 0644)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: legacy
+  source:
+    dump:
+      path: ` + dumpPath + `
+      compression: auto
+  hooks:
+  - name: pre-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+`)
+		})
+
+		It("loads the decompressed dump file into a new repository", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(func() *serverupdater.LoadStatus { return loadStatusOf("legacy") }).Should(Equal(&serverupdater.LoadStatus{
+				Name:               "legacy",
+				Phase:              serverupdater.LoadPhaseSucceeded,
+				LastLoadedRevision: 3,
+			}))
+			loaded, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "legacy", "dump"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(loaded)).To(Equal(dump))
+			Expect(filepath.Join(u.ReposDir, "legacy", "hooks", "pre-commit")).To(BeAnExistingFile())
+		})
+
+		It("resumes an interrupted load from the last loaded revision", func() {
+			staging := filepath.Join(u.LoadingDir, "legacy")
+			Expect(os.MkdirAll(staging, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(staging, "youngest"), []byte("2\n"), 0644)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(loadPhaseOf("legacy")).Should(Equal(serverupdater.LoadPhaseSucceeded))
+			args, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "legacy", "args"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(args)).To(Equal("load " + staging + " --revision 3:2147483647\n"))
+		})
+
+		It("keeps the progress apart from repositories being loaded", func() {
+			writeReposConfig(`repositories:
+- name: legacy
+  source:
+    dump:
+      path: ` + dumpPath + `
+- name: legacy.json
+  source:
+    dump:
+      path: ` + dumpPath + `
+`)
+			Expect(os.MkdirAll(filepath.Join(u.LoadingDir, "legacy.json"), 0755)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(loadPhaseOf("legacy")).Should(Equal(serverupdater.LoadPhaseSucceeded))
+			Eventually(loadPhaseOf("legacy.json")).Should(Equal(serverupdater.LoadPhaseSucceeded))
+			Expect(filepath.Join(u.ReposDir, "legacy", "dump")).To(BeAnExistingFile())
+			Expect(filepath.Join(u.ReposDir, "legacy.json", "dump")).To(BeAnExistingFile())
+		})
+
+		It("reports failures and keeps the repository out of the repository directory", func() {
+			Expect(os.Remove(dumpPath)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(loadPhaseOf("legacy")).Should(Equal(serverupdater.LoadPhaseFailed))
+			Expect(loadStatusOf("legacy").Message).To(ContainSubstring("no such file"))
+			Expect(filepath.Join(u.ReposDir, "legacy")).NotTo(BeADirectory())
+		})
+	})
+
+	Describe("mirrors", func() {
+		// upstream stands in for the upstream server. svnsync copies its youngest revision while it is up.
+		var upstream, logFile, authFile, upstreamURL string
+		// realmServer asks for the credentials of the upstream repository that requires them.
+		var realmServer *httptest.Server
+		mirrorStatusOf := func(name string) *serverupdater.MirrorStatus {
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			return status.MirrorStatusOf(name)
+		}
+		syncedRevisionOf := func(name string) func() int64 {
+			return func() int64 {
+				if s := mirrorStatusOf(name); s != nil {
+					return s.LastSyncedRevision
+				}
+				return -1
+			}
+		}
+		svnsyncLog := func() string {
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			return string(log)
+		}
+		BeforeEach(func() {
+			upstream = filepath.Join(tmpDir, "upstream")
+			Expect(os.MkdirAll(upstream, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(upstream, "youngest"), []byte("5\n"), 0644)).To(Succeed())
+			logFile = filepath.Join(tmpDir, "svnsync.log")
+			authFile = filepath.Join(tmpDir, "svnsync-auth.log")
+			realmServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Upstream Server"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			upstreamURL = realmServer.URL + "/upstream"
+			// The config directory is temporary, so its path is left out of the log.
+			u.SvnSync = writeScript("svnsync", `
+for arg; do
+  if [ "$prev" = --config-dir ]; then
+    ls -ld "$arg" | cut -c 1-10 >> `+authFile+`
+    ls -l "$arg"/auth/svn.simple/* | cut -c 1-10 >> `+authFile+`
+    cat "$arg"/auth/svn.simple/* >> `+authFile+`
+  fi
+  prev="$arg"
+done
+echo "$@" | sed 's| --config-dir [^ ]*||' >> `+logFile+`
+repo="${2#file://}"
+case "$1" in
+  initialize) echo "$3" > "$repo/sync-from-url";;
+  synchronize)
+    if [ ! -f `+upstream+`/youngest ]; then
+      echo "svnsync: E170013: Unable to connect to a repository" >&2
+      exit 1
+    fi
+    cp `+upstream+`/youngest "$repo/youngest";;
+esac
+`)
+			u.SvnLook = writeScript("svnlook", `
+case "$1" in
+  youngest) cat "$2/youngest" 2>/dev/null || echo 0;;
+  propget) cat "$5/sync-from-url";;
+esac
+`)
+			u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+			credentials := filepath.Join(tmpDir, "credentials")
+			Expect(os.MkdirAll(credentials, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(credentials, "username"), []byte("reader"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(credentials, "password"), []byte("secret\n"), 0644)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: mirror
+  source:
+    mirror:
+      url: ` + upstreamURL + `
+      credentialsDir: ` + credentials + `
+      intervalSeconds: 3600
+`)
+		})
+
+		AfterEach(func() {
+			realmServer.Close()
+		})
+
+		It("initializes a new mirror and reports the last synced revision", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(5)))
+			s := mirrorStatusOf("mirror")
+			Expect(s.LastSyncTime).NotTo(BeEmpty())
+			Expect(s.Message).To(BeEmpty())
+			repo := filepath.Join(u.ReposDir, "mirror")
+			args := "--non-interactive --no-auth-cache --sync-username " + serverupdater.MirrorSyncUser +
+				" --source-username reader\n"
+			Expect(svnsyncLog()).To(Equal("initialize file://" + repo + " " + upstreamURL + " " + args +
+				"synchronize file://" + repo + " " + args))
+		})
+
+		It("passes the password through the authentication cache that only server-updater can read", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(5)))
+			Expect(svnsyncLog()).NotTo(ContainSubstring("secret"))
+			// realmServer.URL is already in the form of http://127.0.0.1:port.
+			realm := "<" + realmServer.URL + "> Upstream Server"
+			auth, err := ioutil.ReadFile(authFile)
+			Expect(err).NotTo(HaveOccurred())
+			entry := "drwx------\n-rw-------\n" +
+				"K 8\npasstype\nV 6\nsimple\n" +
+				"K 8\npassword\nV 6\nsecret\n" +
+				"K 15\nsvn:realmstring\nV " + strconv.Itoa(len(realm)) + "\n" + realm + "\n" +
+				"K 8\nusername\nV 6\nreader\n" +
+				"END\n"
+			// Both initialize and synchronize read the credentials.
+			Expect(string(auth)).To(Equal(entry + entry))
+		})
+
+		It("installs the built-in hooks that only svnsync passes", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(5)))
+			for _, name := range []string{"start-commit", "pre-lock", "pre-revprop-change"} {
+				Expect(filepath.Join(u.ReposDir, "mirror", "hooks", name)).To(BeAnExistingFile())
+			}
+			content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "mirror", svnconfig.HookConfigPath))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("mirror:\n  syncUser: " + serverupdater.MirrorSyncUser + "\n"))
+		})
+
+		It("synchronizes the mirror again only after the interval", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(5)))
+			Expect(ioutil.WriteFile(filepath.Join(upstream, "youngest"), []byte("8\n"), 0644)).To(Succeed())
+			Expect(u.SyncMirrors()).To(Succeed())
+			Consistently(syncedRevisionOf("mirror"), "200ms").Should(Equal(int64(5)))
+
+			writeReposConfig(`repositories:
+- name: mirror
+  source:
+    mirror:
+      url: ` + upstreamURL + `
+      intervalSeconds: 0
+`)
+			Expect(u.SyncMirrors()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(8)))
+			// The mirror has already been initialized.
+			Expect(strings.Count(svnsyncLog(), "initialize")).To(Equal(1))
+		})
+
+		It("reports failures and keeps the last synced revision", func() {
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(syncedRevisionOf("mirror")).Should(Equal(int64(5)))
+			Expect(os.RemoveAll(upstream)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: mirror
+  source:
+    mirror:
+      url: ` + upstreamURL + `
+      intervalSeconds: 0
+`)
+			Expect(u.SyncMirrors()).To(Succeed())
+			Eventually(func() string { return mirrorStatusOf("mirror").Message }).Should(ContainSubstring("Unable to connect"))
+			Expect(mirrorStatusOf("mirror").LastSyncedRevision).To(Equal(int64(5)))
+		})
+
+		It("refuses to synchronize a mirror of another repository", func() {
+			repo := filepath.Join(u.ReposDir, "mirror")
+			mkRepo("mirror")
+			Expect(ioutil.WriteFile(filepath.Join(repo, "sync-from-url"), []byte("svn://localhost/other\n"), 0644)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(func() *serverupdater.MirrorStatus { return mirrorStatusOf("mirror") }).ShouldNot(BeNil())
+			Expect(mirrorStatusOf("mirror").Message).To(Equal("the repository is a mirror of svn://localhost/other, not " + upstreamURL))
+			Expect(logFile).NotTo(BeAnExistingFile())
+		})
+
+		It("does not synchronize read-only mirrors", func() {
+			writeReposConfig(`repositories:
+- name: mirror
+  source:
+    mirror:
+      url: svn://localhost/upstream
+      intervalSeconds: 3600
+  readOnly: {}
+`)
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Consistently(func() *serverupdater.MirrorStatus { return mirrorStatusOf("mirror") }, "200ms").Should(BeNil())
+			Expect(logFile).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("replicas", func() {
+		const uuid = "6b3c1e2a-0000-4000-8000-000000000000"
+		var primary *httptest.Server
+		var logFile string
+		BeforeEach(func() {
+			// primary stands in for server-updater running in the primary.
+			primary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != serverupdater.StatusPath {
+					// The Apache HTTP Server of the primary asks for the replication credentials.
+					w.Header().Set("WWW-Authenticate", `Basic realm="SVN Server"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if r.Header.Get("Authorization") != "Bearer status-token" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(&serverupdater.Status{
+					Repositories: []serverupdater.RepositoryStatus{{Name: "shared", Revision: 3, UUID: uuid}},
+				})
+			}))
+			logFile = filepath.Join(tmpDir, "svnsync.log")
+			u.SvnAdmin = writeScript("svnadmin", `
+case "$1" in
+  create) mkdir -p "$2"; echo 11111111-1111-1111-1111-111111111111 > "$2/uuid";;
+  setuuid) echo "$3" > "$2/uuid";;
+esac
+`)
+			u.SvnLook = writeScript("svnlook", `
+case "$1" in
+  uuid) cat "$2/uuid";;
+  youngest) echo 3;;
+esac
+`)
+			u.SvnSync = writeScript("svnsync", `echo "$@" | sed 's| --config-dir [^ ]*||' >> `+logFile)
+			u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+			u.PrimaryURL = primary.URL + "/repos"
+			u.PrimaryUpdaterURL = primary.URL
+			u.StatusToken = "status-token"
+			u.ReplicaInterval = time.Hour
+			u.ReplicationCredentialsDir = filepath.Join(tmpDir, "replication")
+			Expect(os.MkdirAll(u.ReplicationCredentialsDir, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(u.ReplicationCredentialsDir, "username"), []byte(svnconfig.ReplicationUser), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(u.ReplicationCredentialsDir, "password"), []byte("secret"), 0644)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: shared
+  hooks:
+  - name: post-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+  commitPolicy:
+    minLogMessageLength: 10
+  maxSize: 1000
+- name: loading
+  source:
+    dump:
+      path: /svn-sources/legacy.dump
+maxTotalSize: 5000
+`)
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(func() *serverupdater.MirrorStatus {
+				status, err := u.Status()
+				Expect(err).NotTo(HaveOccurred())
+				return status.MirrorStatusOf("shared")
+			}).ShouldNot(BeNil())
+		})
+
+		AfterEach(func() {
+			primary.Close()
+		})
+
+		It("copies the repositories on the primary with the same UUIDs", func() {
+			Expect(filepath.Join(u.ReposDir, "loading")).NotTo(BeADirectory())
+			content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "shared", "uuid"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(uuid + "\n"))
+			repo := filepath.Join(u.ReposDir, "shared")
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(log)).To(HavePrefix("initialize file://" + repo + " " + primary.URL + "/repos/shared " +
+				"--non-interactive --no-auth-cache --sync-username " + serverupdater.MirrorSyncUser +
+				" --source-username " + svnconfig.ReplicationUser + "\n"))
+		})
+
+		It("installs none of the hooks of the primary", func() {
+			hooksDir := filepath.Join(u.ReposDir, "shared", "hooks")
+			Expect(filepath.Join(hooksDir, "post-commit")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(hooksDir, "pre-revprop-change")).To(BeAnExistingFile())
+			Expect(u.MeasureUsage()).To(Succeed())
+			content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "shared", svnconfig.HookConfigPath))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("mirror:\n  syncUser: " + serverupdater.MirrorSyncUser + "\n"))
+		})
+
+		It("becomes ready after synchronizing every repository on the primary", func() {
+			w := httptest.NewRecorder()
+			u.ServeReady(w, httptest.NewRequest(http.MethodGet, serverupdater.ReadyPath, nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			By("starting over without the repositories on the primary")
+			fresh := &serverupdater.Updater{
+				ReposConfig: u.ReposConfig,
+				ReposDir:    filepath.Join(tmpDir, "fresh"),
+				PrimaryURL:  u.PrimaryURL,
+				Log:         u.Log,
+			}
+			w = httptest.NewRecorder()
+			fresh.ServeReady(w, httptest.NewRequest(http.MethodGet, serverupdater.ReadyPath, nil))
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("is not ready until the first synchronization succeeds", func() {
+			u.ReposDir = filepath.Join(tmpDir, "failing")
+			u.SvnSync = writeScript("svnsync", `exit 1`)
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Eventually(func() string {
+				status, err := u.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if s := status.MirrorStatusOf("shared"); s != nil {
+					return s.Message
+				}
+				return ""
+			}).ShouldNot(BeEmpty())
+			Expect(u.Ready(context.Background())).To(MatchError(ContainSubstring("shared")))
+		})
+
+		It("keeps copying the known repositories while the primary is not available", func() {
+			primary.Close()
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Expect(filepath.Join(u.ReposDir, "shared")).To(BeADirectory())
+		})
+
+		It("synchronizes a repository as soon as the primary tells it to", func() {
+			primaryYoungest := filepath.Join(tmpDir, "primary-youngest")
+			Expect(ioutil.WriteFile(primaryYoungest, []byte("4\n"), 0644)).To(Succeed())
+			u.SvnSync = writeScript("svnsync", `
+[ "$1" = synchronize ] && cp `+primaryYoungest+` "${2#file://}/youngest"
+true
+`)
+			u.SvnLook = writeScript("svnlook", `
+case "$1" in
+  uuid) cat "$2/uuid";;
+  youngest) cat "$2/youngest";;
+esac
+`)
+			server := httptest.NewServer(http.HandlerFunc(u.ServeSync))
+			defer server.Close()
+			tokenFile := filepath.Join(tmpDir, "token")
+			Expect(ioutil.WriteFile(tokenFile, []byte("status-token\n"), 0644)).To(Succeed())
+
+			replicas := &svnconfig.Replicas{UpdaterURLs: []string{server.URL}, TokenFile: tokenFile}
+			Expect(serverupdater.NotifyReplicas(context.Background(), replicas, "shared")).To(Succeed())
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.MirrorStatusOf("shared").LastSyncedRevision).To(Equal(int64(4)))
+		})
+
+		It("requires the token to synchronize a repository", func() {
+			server := httptest.NewServer(http.HandlerFunc(u.ServeSync))
+			defer server.Close()
+			client := &serverupdater.Client{}
+			Expect(client.Sync(context.Background(), server.URL, "", "shared")).To(MatchError(ContainSubstring("401")))
+			Expect(client.Sync(context.Background(), server.URL, "status-token", "unknown")).To(MatchError(ContainSubstring("unknown")))
+		})
+	})
+
+	Describe("creation options", func() {
+		var logFile string
+		fsfsConf := func(name string) string {
+			content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, name, "db", "fsfs.conf"))
+			Expect(err).NotTo(HaveOccurred())
+			return string(content)
+		}
+		BeforeEach(func() {
+			logFile = filepath.Join(tmpDir, "svnadmin.log")
+			u.SvnAdmin = writeScript("svnadmin", `
+echo "$@" >> `+logFile+`
+case "$1" in
+  create)
+    eval "repo=\${$#}"
+    mkdir -p "$repo/db"
+    printf '[rep-sharing]\n# enable-rep-sharing = true\n[packed-revprops]\nrevprop-pack-size = 16\n' > "$repo/db/fsfs.conf"
+    echo 11111111-1111-1111-1111-111111111111 > "$repo/uuid";;
+  setuuid) echo "$3" > "$2/uuid";;
+esac
+`)
+			u.SvnLook = writeScript("svnlook", `cat "$2/uuid"`)
+			writeReposConfig(`repositories:
+- name: keep
+  create:
+    uuid: 22222222-2222-2222-2222-222222222222
+- name: new
+  create:
+    fsType: fsfs
+    compatibleVersion: "1.8"
+    uuid: 22222222-2222-2222-2222-222222222222
+    fsfs:
+      enableRepSharing: false
+      revPropPackSize: 64
+      compressPackedRevProps: true
+`)
+			Expect(ioutil.WriteFile(filepath.Join(u.ReposDir, "keep", "uuid"), []byte("33333333-3333-3333-3333-333333333333\n"), 0644)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+		})
+
+		It("creates repositories with the options", func() {
+			log, err := ioutil.ReadFile(logFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(log)).To(ContainSubstring("create --fs-type fsfs --compatible-version 1.8 " + filepath.Join(u.ReposDir, "new") + "\n"))
+			Expect(fsfsConf("new")).To(Equal("[rep-sharing]\nenable-rep-sharing = false\n# enable-rep-sharing = true\n" +
+				"[packed-revprops]\ncompress-packed-revprops = true\nrevprop-pack-size = 64\n"))
+		})
+
+		It("sets the UUIDs of new and existing repositories", func() {
+			for _, name := range []string{"keep", "new"} {
+				uuid, err := ioutil.ReadFile(filepath.Join(u.ReposDir, name, "uuid"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(uuid)).To(Equal("22222222-2222-2222-2222-222222222222\n"))
+			}
+		})
+
+		It("does not set the UUIDs again once they match", func() {
+			Expect(os.Remove(logFile)).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Expect(logFile).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("hooks", func() {
+		hookPath := func(name string) string {
+			return filepath.Join(u.ReposDir, "keep", "hooks", name)
+		}
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(u.ReposDir, "keep", "hooks"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(hookPath("pre-commit.tmpl"), []byte("template"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(hookPath("post-commit"), []byte("old"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(hookPath("start-commit"), []byte("old"), 0644)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: keep
+  hooks:
+  - name: pre-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+  - name: start-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+`)
+			Expect(u.OnConfigChanged()).To(Succeed())
+		})
+
+		It("installs declared hooks as executables", func() {
+			for _, name := range []string{"pre-commit", "start-commit"} {
+				content, err := ioutil.ReadFile(hookPath(name))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("#!/bin/sh\nexit 1\n"))
+				info, err := os.Stat(hookPath(name))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+			}
+		})
+
+		It("removes hooks that are not declared but keeps templates", func() {
+			Expect(hookPath("post-commit")).NotTo(BeAnExistingFile())
+			Expect(hookPath("pre-commit.tmpl")).To(BeAnExistingFile())
+		})
+
+		It("removes all hooks once they are no longer declared", func() {
+			writeReposConfig(`repositories:
+- name: keep
+`)
+			Expect(u.OnConfigChanged()).To(Succeed())
+			Expect(hookPath("pre-commit")).NotTo(BeAnExistingFile())
+			Expect(hookPath("start-commit")).NotTo(BeAnExistingFile())
+			Expect(hookPath("pre-commit.tmpl")).To(BeAnExistingFile())
+		})
+
+		Context("when a commit policy is specified", func() {
+			BeforeEach(func() {
+				u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+				writeReposConfig(`repositories:
+- name: keep
+  hooks:
+  - name: pre-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+  commitPolicy:
+    minLogMessageLength: 10
+`)
+				Expect(u.OnConfigChanged()).To(Succeed())
+			})
+
+			It("installs the built-in pre-commit hook that runs the declared one", func() {
+				out, err := exec.Command(hookPath("pre-commit"), "/path/to/repo", "1-a").Output()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(out)).To(Equal("-user-hook " + hookPath("pre-commit.user") + " pre-commit /path/to/repo 1-a\n"))
+				content, err := ioutil.ReadFile(hookPath("pre-commit.user"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("#!/bin/sh\nexit 1\n"))
+			})
+
+			It("writes the policy for the built-in hooks", func() {
+				content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "keep", svnconfig.HookConfigPath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("commitPolicy:\n  minLogMessageLength: 10\n"))
+			})
+
+			It("removes the built-in hook and the policy once the policy is removed", func() {
+				writeReposConfig(`repositories:
+- name: keep
+  hooks:
+  - name: pre-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+`)
+				Expect(u.OnConfigChanged()).To(Succeed())
+				content, err := ioutil.ReadFile(hookPath("pre-commit"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("#!/bin/sh\nexit 1\n"))
+				Expect(hookPath("pre-commit.user")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(u.ReposDir, "keep", svnconfig.HookConfigPath)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when the repository is read-only", func() {
+			It("installs the built-in pre-commit and pre-lock hooks", func() {
+				u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+				writeReposConfig(`repositories:
+- name: keep
+  readOnly:
+    message: frozen
+`)
+				Expect(u.OnConfigChanged()).To(Succeed())
+				out, err := exec.Command(hookPath("pre-lock"), "/path/to/repo", "/trunk/a", "alice", "", "0").Output()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(out)).To(Equal("pre-lock /path/to/repo /trunk/a alice  0\n"))
+				Expect(hookPath("pre-commit")).To(BeAnExistingFile())
+				content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "keep", svnconfig.HookConfigPath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("readOnly:\n  message: frozen\n"))
+			})
+		})
+
+		Context("when the server has replicas", func() {
+			It("installs the built-in post-commit and post-revprop-change hooks that notify them", func() {
+				u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+				u.StatusTokenFile = "/etc/svn-updater/token"
+				writeReposConfig(`repositories:
+- name: keep
+replicaUpdaterURLs:
+- http://svn-1.svn.default.svc:8081
+`)
+				Expect(u.OnConfigChanged()).To(Succeed())
+				Expect(hookPath("post-commit")).To(BeAnExistingFile())
+				Expect(hookPath("post-revprop-change")).To(BeAnExistingFile())
+				content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "keep", svnconfig.HookConfigPath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("replicas:\n  tokenFile: /etc/svn-updater/token\n  updaterURLs:\n  - http://svn-1.svn.default.svc:8081\n"))
+			})
+		})
+
+		It("rejects unknown hook names", func() {
+			writeReposConfig(`repositories:
+- name: keep
+  hooks:
+  - name: ../../evil
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+`)
+			Expect(u.OnConfigChanged()).NotTo(Succeed())
+			Expect(filepath.Join(u.ReposDir, "evil")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("usage", func() {
+		BeforeEach(func() {
+			u.HookCommand = writeScript("svn-hook", `echo "$@"`)
+			Expect(ioutil.WriteFile(filepath.Join(u.ReposDir, "keep", "db"), make([]byte, 300), 0644)).To(Succeed())
+			mkRepo("other")
+			Expect(ioutil.WriteFile(filepath.Join(u.ReposDir, "other", "db"), make([]byte, 200), 0644)).To(Succeed())
+			Expect(os.MkdirAll(u.TrashDir, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(u.TrashDir, "archived"), make([]byte, 100), 0644)).To(Succeed())
+			writeReposConfig(`repositories:
+- name: keep
+  maxSize: 1000
+- name: other
+maxTotalSize: 5000
+`)
+			// The hooks are not measured since they are installed after the measurement.
+			Expect(u.MeasureUsage()).To(Succeed())
+			Expect(u.OnConfigChanged()).To(Succeed())
+		})
+
+		It("reports the sizes of repositories and the whole server", func() {
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Repositories).To(Equal([]serverupdater.RepositoryStatus{
+				{Name: "keep", Size: 300},
+				{Name: "other", Size: 200},
+			}))
+			Expect(status.TotalSize).To(Equal(int64(600)))
+		})
+
+		It("passes the usage to the built-in hooks", func() {
+			content, err := ioutil.ReadFile(filepath.Join(u.ReposDir, "keep", svnconfig.HookConfigPath))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("quota:\n  maxSize: 1000\n  maxTotalSize: 5000\n  size: 300\n  totalSize: 600\n"))
+			Expect(filepath.Join(u.ReposDir, "keep", "hooks", "pre-commit")).To(BeAnExistingFile())
+			Expect(filepath.Join(u.ReposDir, "other", "hooks", "pre-commit")).To(BeAnExistingFile())
+		})
+	})
+
+	Describe("repository details", func() {
+		var infoCount string
+		BeforeEach(func() {
+			infoCount = filepath.Join(tmpDir, "info-count")
+			db := filepath.Join(u.ReposDir, "keep", "db")
+			Expect(os.MkdirAll(db, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(db, "fs-type"), []byte("fsfs\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(db, "format"), []byte("8\nlayout sharded 1000\n"), 0644)).To(Succeed())
+			u.SvnLook = writeScript("svnlook", `
+case "$1" in
+  uuid) echo 6b3c1e2a-0000-4000-8000-000000000000;;
+  youngest) echo 42;;
+  info) echo x >> `+infoCount+`; printf 'alice\n2021-05-01 12:34:56 +0900 (Sat, 01 May 2021)\n3\nfix\n';;
+esac
+`)
+		})
+
+		It("reports the details of repositories", func() {
+			status, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Repositories).To(Equal([]serverupdater.RepositoryStatus{{
+				Name:              "keep",
+				Revision:          42,
+				UUID:              "6b3c1e2a-0000-4000-8000-000000000000",
+				FSType:            "fsfs",
+				FSFormat:          8,
+				LastChangedAuthor: "alice",
+				LastChangedTime:   "2021-05-01T03:34:56Z",
+			}}))
+		})
+
+		It("caches the last commit until a new revision is committed", func() {
+			_, err := u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = u.Status()
+			Expect(err).NotTo(HaveOccurred())
+			count, err := ioutil.ReadFile(infoCount)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(count)).To(Equal("x\n"))
+		})
+	})
+
+	Describe("Client", func() {
+		It("fetches the status over HTTP", func() {
+			server := httptest.NewServer(u)
+			defer server.Close()
+			client := &serverupdater.Client{}
+			status, err := client.Status(context.Background(), server.URL, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Repositories).To(Equal([]serverupdater.RepositoryStatus{{Name: "keep"}}))
+		})
+
+		It("requires the token if the server has one", func() {
+			u.StatusToken = "status-token"
+			server := httptest.NewServer(u)
+			defer server.Close()
+			client := &serverupdater.Client{}
+			_, err := client.Status(context.Background(), server.URL, "")
+			Expect(err).To(MatchError(ContainSubstring("401")))
+			_, err = client.Status(context.Background(), server.URL, "wrong")
+			Expect(err).To(MatchError(ContainSubstring("401")))
+			status, err := client.Status(context.Background(), server.URL, "status-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.Repositories).To(Equal([]serverupdater.RepositoryStatus{{Name: "keep"}}))
+		})
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 Genta Kamitani.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverupdater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/genkami/svn-operator/pkg/svnconfig"
+)
+
+// MeasureUsage measures the sizes of repositories and updates the usage that the built-in hooks refer to.
+// Measuring large repositories takes a while, so this should be called less frequently than SyncRepositories.
+func (u *Updater) MeasureUsage() error {
+	entries, err := ioutil.ReadDir(u.ReposDir)
+	if err != nil {
+		return err
+	}
+	sizes := map[string]int64{}
+	var total int64
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		size, err := dirSize(filepath.Join(u.ReposDir, e.Name()))
+		if err != nil {
+			return err
+		}
+		sizes[e.Name()] = size
+		total += size
+	}
+	// Archived repositories and repositories being loaded reside in the same volume.
+	for _, dir := range []string{u.TrashDir, u.LoadingDir} {
+		if dir == "" {
+			continue
+		}
+		size, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+
+	u.mu.Lock()
+	u.sizes = sizes
+	u.totalSize = total
+	u.mu.Unlock()
+
+	reposConfig, _, err := u.readReposConfig()
+	if err != nil {
+		return err
+	}
+	return u.syncHooks(reposConfig)
+}
+
+// quotaOf returns the last measured usage of the repository and its limits,
+// or nil if the repository has no limits.
+func (u *Updater) quotaOf(entry *svnconfig.RepoEntry, maxTotalSize int64) *svnconfig.Quota {
+	if entry.MaxSize <= 0 && maxTotalSize <= 0 {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return &svnconfig.Quota{
+		Size:         u.sizes[entry.Name],
+		MaxSize:      entry.MaxSize,
+		TotalSize:    u.totalSize,
+		MaxTotalSize: maxTotalSize,
+	}
+}
+
+// dirSize returns the total size of regular files in the directory, which is 0 if the directory does not exist.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The file has been removed during the walk, e.g. by a commit.
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
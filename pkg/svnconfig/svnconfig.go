@@ -3,14 +3,25 @@ package svnconfig
 
 import (
 	"bytes"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 	"text/template"
 
 	"sigs.k8s.io/yaml"
 )
 
+// RootPath is a path that represents the whole repository.
+const RootPath = "/"
+
 var (
+	// pathPattern must be kept in sync with the validation of svnv1alpha1.Permission.Path.
+	pathPattern = regexp.MustCompile(`^(?:/|(?:/[^/\[\]\x00-\x1f\x7f]+)+)$`)
+
 	tmplAuthzSVNAccessFile = template.Must(template.New("AuthzSVNAccessFile").Parse(rawTmplAuthzSVNAccessFile))
 	tmplAuthUserFile       = template.Must(template.New("AuthUserFile").Parse(rawTmplAuthUserFile))
+	tmplApacheConfig       = template.Must(template.New("ApacheConfig").Parse(rawTmplApacheConfig))
 )
 
 // Generator generates configuration files for SVN server.
@@ -21,24 +32,315 @@ type Generator struct {
 	Repositories []Repository
 	Groups       []Group
 	Users        []User
+
+	// Deletions is a list of repositories that are being deleted.
+	Deletions []Deletion
+
+	// MaxTotalSize is the maximum total size of repositories in bytes. Zero means no limit.
+	MaxTotalSize int64
+
+	// Paths is a set of paths that the Apache configuration file refers to.
+	Paths Paths
+
+	// Replication is the user that read-only replicas copy repositories from the primary as.
+	// It is nil if the server has no replicas.
+	Replication *Replication
+}
+
+// ReplicationUser is the name of Replication.User.
+// It never conflicts with names of users since they never contain more than one underscore.
+const ReplicationUser = "svn_operator_replica"
+
+// Replication is a user that can read every path of every repository, which is used by replicas.
+type Replication struct {
+	User              string
+	EncryptedPassword string
+
+	// UpdaterURLs are the base URLs of server-updaters of the replicas,
+	// which the primary tells to synchronize repositories as soon as they are changed.
+	UpdaterURLs []string
+}
+
+// Paths is a set of paths of files and directories inside SVN servers.
+type Paths struct {
+	// ReposDir is a directory that SVN repositories reside in.
+	ReposDir string
+
+	// AuthUserFile is a path to the file generated by Generator.AuthUserFile.
+	AuthUserFile string
+
+	// AuthzSVNAccessFile is a path to the file generated by Generator.AuthzSVNAccessFile.
+	AuthzSVNAccessFile string
 }
 
 // Repository is a definition of a repository.
 type Repository struct {
 	Name        string
 	Permissions []Permission
+
+	// AnonymousAccess is a permission given to users who are not logged in.
+	AnonymousAccess string
+
+	// AuthenticatedAccess is a permission given to all users who have logged in.
+	AuthenticatedAccess string
+
+	// Initial is the content that is committed as revision 1 right after the repository is created.
+	Initial *InitialContent
+
+	// Source is the history that the repository is created from.
+	// It cannot be used with Initial.
+	Source *Source
+
+	// Hooks is a list of hook scripts installed into the repository.
+	Hooks []Hook
+
+	// CommitPolicy is a set of checks that the built-in pre-commit hook applies to commits.
+	CommitPolicy *CommitPolicy
+
+	// MaxSize is the maximum size of the repository in bytes. Zero means no limit.
+	MaxSize int64
+
+	// ReadOnly freezes the repository if not nil.
+	// Mirrors are read-only as well, but they are still written by `svnsync`.
+	ReadOnly *ReadOnly
+
+	// Create is a set of options to create the repository.
+	Create *CreateOptions
+
+	// Pending means that the repository should not be created yet,
+	// e.g. because its initial content is not available.
+	Pending bool
+}
+
+// InitialContent is the content of the first revision of a repository.
+type InitialContent struct {
+	// Directories is a list of directories relative to the root of the repository.
+	Directories []string `json:"directories,omitempty"`
+	Files       []File   `json:"files,omitempty"`
+}
+
+// Source is the history that a repository is created from.
+// At most one of Dump and Mirror is set.
+type Source struct {
+	Dump   *DumpSource   `json:"dump,omitempty"`
+	Mirror *MirrorSource `json:"mirror,omitempty"`
+}
+
+// MirrorSource is an upstream repository that a repository replicates with `svnsync`.
+type MirrorSource struct {
+	// URL is the URL of the upstream repository.
+	URL string `json:"url"`
+
+	// CredentialsDir is an absolute path to a directory inside the SVN server that contains
+	// `username` and `password` files to access URL. Empty means anonymous access.
+	CredentialsDir string `json:"credentialsDir,omitempty"`
+
+	// IntervalSeconds is the interval between synchronizations in seconds.
+	IntervalSeconds int64 `json:"intervalSeconds"`
+}
+
+// Here is a list of names of files in MirrorSource.CredentialsDir.
+// They must be kept in sync with the keys documented in svnv1alpha1.MirrorSource.CredentialsSecret.
+const (
+	MirrorCredentialsUsername = "username"
+	MirrorCredentialsPassword = "password"
+)
+
+// DumpSource is a dump file created by `svnadmin dump`.
+type DumpSource struct {
+	// Path is an absolute path to the dump file inside the SVN server.
+	Path string `json:"path"`
+
+	// Compression is one of CompressionAuto, CompressionNone, CompressionGzip, CompressionBzip2 and CompressionXz.
+	Compression string `json:"compression,omitempty"`
+}
+
+// Here is a list of allowed values of DumpSource.Compression.
+// They must be kept in sync with svnv1alpha1.DumpSource.Compression.
+const (
+	CompressionAuto  = "auto"
+	CompressionNone  = "none"
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionXz    = "xz"
+)
+
+// File is a file in a repository.
+type File struct {
+	// Path is a path relative to the root of the repository.
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+}
+
+// Hook is a hook script of a repository.
+type Hook struct {
+	// Name is one of HookNames.
+	Name   string `json:"name"`
+	Script []byte `json:"script"`
+}
+
+// HookNames is a list of names of hooks that SVN repositories support.
+// It must be kept in sync with svnv1alpha1.Hook.Name.
+var HookNames = []string{
+	"start-commit",
+	"pre-commit",
+	"post-commit",
+	"pre-lock",
+	"post-lock",
+	"pre-unlock",
+	"post-unlock",
+	"pre-revprop-change",
+	"post-revprop-change",
+}
+
+// IsValidHookName reports whether name is one of HookNames.
+func IsValidHookName(name string) bool {
+	for _, n := range HookNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CommitPolicy is a set of checks that the built-in pre-commit hook applies to commits.
+// Zero values disable the corresponding checks.
+type CommitPolicy struct {
+	// LogMessagePattern is a regular expression that log messages must match.
+	LogMessagePattern string `json:"logMessagePattern,omitempty"`
+
+	// MinLogMessageLength is the minimum number of characters of log messages, excluding surrounding spaces.
+	MinLogMessageLength int `json:"minLogMessageLength,omitempty"`
+
+	// MaxFileSize is the maximum size of files in bytes.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+
+	// RejectCaseCollisions rejects paths that differ from other paths in the same directory only in case.
+	RejectCaseCollisions bool `json:"rejectCaseCollisions,omitempty"`
+
+	// BlockedPaths is a list of patterns of paths that cannot be added nor modified.
+	BlockedPaths []string `json:"blockedPaths,omitempty"`
+}
+
+// CreateOptions is a set of options to create a repository.
+type CreateOptions struct {
+	// FSType is the type of the filesystem passed to `svnadmin create --fs-type`.
+	FSType string `json:"fsType,omitempty"`
+
+	// CompatibleVersion is the oldest version of Subversion that can read the repository,
+	// passed to `svnadmin create --compatible-version`.
+	CompatibleVersion string `json:"compatibleVersion,omitempty"`
+
+	// UUID is set by `svnadmin setuuid` whenever the repository has another UUID, not only at creation time.
+	UUID string `json:"uuid,omitempty"`
+
+	// FSFS is a set of options written into db/fsfs.conf.
+	FSFS *FSFSOptions `json:"fsfs,omitempty"`
 }
 
+// FSFSOptions is a set of tuning options of FSFS.
+type FSFSOptions struct {
+	// EnableRepSharing is `enable-rep-sharing` in `[rep-sharing]`.
+	EnableRepSharing *bool `json:"enableRepSharing,omitempty"`
+
+	// RevPropPackSize is `revprop-pack-size` in `[packed-revprops]` in kilobytes.
+	RevPropPackSize int `json:"revPropPackSize,omitempty"`
+
+	// CompressPackedRevProps is `compress-packed-revprops` in `[packed-revprops]`.
+	CompressPackedRevProps *bool `json:"compressPackedRevProps,omitempty"`
+}
+
+// ReadOnly means that nobody can write to a repository.
+type ReadOnly struct {
+	// Message is shown to users who try to write to the repository.
+	Message string `json:"message,omitempty"`
+}
+
+// HookConfigPath is a path relative to a repository to the file that the built-in hooks read HookConfig from.
+const HookConfigPath = "conf/svn-operator-hooks.yaml"
+
+// HookConfig is the configuration of the built-in hooks of a repository.
+type HookConfig struct {
+	CommitPolicy *CommitPolicy `json:"commitPolicy,omitempty"`
+	Quota        *Quota        `json:"quota,omitempty"`
+	ReadOnly     *ReadOnly     `json:"readOnly,omitempty"`
+	Mirror       *Mirror       `json:"mirror,omitempty"`
+	Replicas     *Replicas     `json:"replicas,omitempty"`
+}
+
+// Replicas are read-only replicas that the built-in post-commit and post-revprop-change hooks
+// tell to synchronize the repository.
+type Replicas struct {
+	// UpdaterURLs are the base URLs of server-updaters of the replicas.
+	UpdaterURLs []string `json:"updaterURLs"`
+	// TokenFile is a path to the token that the server-updaters require.
+	// The token itself is not written into repositories, which are copied by backups.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// Mirror means that a repository is written only by `svnsync`.
+type Mirror struct {
+	// SyncUser is the user that `svnsync` commits as. The built-in hooks reject writes by others.
+	SyncUser string `json:"syncUser"`
+}
+
+// Quota is the usage of storage and its limits in bytes, which are measured periodically.
+// Zero limits mean no limit.
+type Quota struct {
+	// Size is the size of the repository.
+	Size int64 `json:"size"`
+	// MaxSize is the maximum size of the repository.
+	MaxSize int64 `json:"maxSize,omitempty"`
+
+	// TotalSize is the total size of all repositories in the server.
+	TotalSize int64 `json:"totalSize"`
+	// MaxTotalSize is the maximum total size of all repositories in the server.
+	MaxTotalSize int64 `json:"maxTotalSize,omitempty"`
+}
+
+// Deletion is a repository that should be removed from the SVN server.
+type Deletion struct {
+	Name string `json:"name"`
+
+	// Policy is either DeletionPolicyArchive or DeletionPolicyDelete.
+	Policy string `json:"policy"`
+}
+
+// Here is a list of allowed values of Deletion.Policy.
+// They must be kept in sync with svnv1alpha1.SVNRepositorySpec.DeletionPolicy.
+const (
+	DeletionPolicyArchive = "Archive"
+	DeletionPolicyDelete  = "Delete"
+)
+
 // Permission configurates permission to a specific repository.
+//
+// Exactly one of Group and User should be set.
 type Permission struct {
 	Group      string
 	Permission string
+
+	// Path is a path inside the repository that the permission applies to.
+	// Empty Path is equivalent to RootPath.
+	Path string
+
+	// User is a name of the user that the permission is directly given to.
+	User string
+}
+
+// Section is a set of permissions to a specific path in a repository.
+type Section struct {
+	Path        string
+	Permissions []Permission
 }
 
 // Group is a definitions of a group.
 type Group struct {
 	Name  string
 	Users []string
+
+	// Groups is a list of names of groups that are members of the group.
+	Groups []string
 }
 
 // User is a definition of a user.
@@ -47,14 +349,142 @@ type User struct {
 	EncryptedPassword string
 }
 
+// Sections groups the permissions of the repository by their paths.
+// The result is sorted by path and always contains RootPath.
+//
+// If the same group or user has more than one permission to the same path,
+// they are merged into the strongest one, since mod_authz_svn grants the union of them anyway.
+func (r Repository) Sections() []Section {
+	byPath := map[string][]Permission{RootPath: {}}
+	for _, p := range r.Permissions {
+		path := p.Path
+		if path == "" {
+			path = RootPath
+		}
+		if r.isReadOnly() {
+			p.Permission = readOnlyPermission(p.Permission)
+		}
+		byPath[path] = mergePermission(byPath[path], p)
+	}
+	sections := make([]Section, 0, len(byPath))
+	for path, perms := range byPath {
+		sections = append(sections, Section{Path: path, Permissions: perms})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Path < sections[j].Path
+	})
+	return sections
+}
+
+// AuthenticatedPermission returns the permission given to all users who have logged in,
+// taking ReadOnly into account.
+func (r Repository) AuthenticatedPermission() string {
+	if r.isReadOnly() {
+		return readOnlyPermission(r.AuthenticatedAccess)
+	}
+	return r.AuthenticatedAccess
+}
+
+// isReadOnly returns true if users cannot write to the repository, either because it is frozen
+// or because it is a mirror.
+func (r Repository) isReadOnly() bool {
+	return r.ReadOnly != nil || (r.Source != nil && r.Source.Mirror != nil)
+}
+
+// readOnlyPermission reduces permission to at most `r`.
+func readOnlyPermission(permission string) string {
+	if strengthOf(permission) > strengthOf("r") {
+		return "r"
+	}
+	return permission
+}
+
+func mergePermission(perms []Permission, p Permission) []Permission {
+	for i := range perms {
+		if perms[i].Group == p.Group && perms[i].User == p.User {
+			if strengthOf(p.Permission) > strengthOf(perms[i].Permission) {
+				perms[i].Permission = p.Permission
+			}
+			return perms
+		}
+	}
+	return append(perms, p)
+}
+
+func strengthOf(permission string) int {
+	switch permission {
+	case "rw":
+		return 2
+	case "r":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsValidPath reports whether path can be used as a section name of the authz file.
+func IsValidPath(path string) bool {
+	return pathPattern.MatchString(path)
+}
+
+// IsValidRelativePath reports whether path can be used as a path of a file or a directory
+// relative to the root of a repository.
+func IsValidRelativePath(p string) bool {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == "." {
+		return false
+	}
+	for _, c := range p {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
 // ReposConfig is a special configuration structure that is used to create SVN repositories.
 type ReposConfig struct {
 	Repositories []RepoEntry `json:"repositories"`
+	Deletions    []Deletion  `json:"deletions,omitempty"`
+
+	// MaxTotalSize is the maximum total size of all repositories in bytes.
+	MaxTotalSize int64 `json:"maxTotalSize,omitempty"`
+
+	// ReplicaUpdaterURLs are the base URLs of server-updaters of read-only replicas.
+	// The built-in hooks tell them to synchronize repositories on every commit, instead of waiting for their intervals.
+	ReplicaUpdaterURLs []string `json:"replicaUpdaterURLs,omitempty"`
 }
 
 // RepoEntry is an entry for SVN repository.
 type RepoEntry struct {
 	Name string `json:"name,omitempty"`
+
+	// Initial is committed only if the repository is newly created.
+	Initial *InitialContent `json:"initial,omitempty"`
+
+	// Source.Dump is loaded only if the repository is newly created,
+	// while Source.Mirror is synchronized periodically.
+	Source *Source `json:"source,omitempty"`
+
+	// Hooks is a complete list of hooks of the repository.
+	// Hooks that are not listed here are removed from the repository.
+	Hooks []Hook `json:"hooks,omitempty"`
+
+	// CommitPolicy is applied by the built-in pre-commit hook, which runs before the pre-commit hook in Hooks.
+	CommitPolicy *CommitPolicy `json:"commitPolicy,omitempty"`
+
+	// MaxSize is the maximum size of the repository in bytes.
+	MaxSize int64 `json:"maxSize,omitempty"`
+
+	// ReadOnly makes the built-in hooks reject writes to the repository.
+	ReadOnly *ReadOnly `json:"readOnly,omitempty"`
+
+	// Create is used when the repository is newly created, except for Create.UUID that is always enforced.
+	Create *CreateOptions `json:"create,omitempty"`
 }
 
 // AuthzSVNAccessFile is an authorization configuration file for mod_authz_svn.
@@ -79,6 +509,27 @@ func (g *Generator) AuthUserFile() (string, error) {
 	return buf.String(), nil
 }
 
+// ApacheConfig is a configuration file for Apache that serves SVN repositories.
+//
+// See https://svnbook.red-bean.com/en/1.7/svn.serverconfig.httpd.html for more details.
+func (g *Generator) ApacheConfig() (string, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := tmplApacheConfig.Execute(buf, g); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// AllowsAnonymousAccess returns true if at least one of the repositories can be accessed without credentials.
+func (g *Generator) AllowsAnonymousAccess() bool {
+	for _, r := range g.Repositories {
+		if r.AnonymousAccess != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *Generator) ReposConfig() (string, error) {
 	marshaled, err := yaml.Marshal(g.BuildReposConfig())
 	if err != nil {
@@ -90,7 +541,23 @@ func (g *Generator) ReposConfig() (string, error) {
 func (g *Generator) BuildReposConfig() *ReposConfig {
 	repos := []RepoEntry{}
 	for _, r := range g.Repositories {
-		repos = append(repos, RepoEntry{Name: r.Name})
+		if r.Pending {
+			continue
+		}
+		repos = append(repos, RepoEntry{
+			Name:         r.Name,
+			Initial:      r.Initial,
+			Source:       r.Source,
+			Hooks:        r.Hooks,
+			CommitPolicy: r.CommitPolicy,
+			MaxSize:      r.MaxSize,
+			ReadOnly:     r.ReadOnly,
+			Create:       r.Create,
+		})
+	}
+	config := &ReposConfig{Repositories: repos, Deletions: g.Deletions, MaxTotalSize: g.MaxTotalSize}
+	if g.Replication != nil {
+		config.ReplicaUpdaterURLs = g.Replication.UpdaterURLs
 	}
-	return &ReposConfig{Repositories: repos}
+	return config
 }
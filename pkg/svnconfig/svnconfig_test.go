@@ -2,6 +2,7 @@ package svnconfig_test
 
 import (
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	"github.com/genkami/svn-operator/pkg/svnconfig"
@@ -80,9 +81,9 @@ engen1 = gura, ame, ina, calli, kiara
 						Repositories: []svnconfig.Repository{},
 						Users:        []svnconfig.User{},
 						Groups: []svnconfig.Group{
-							{"gen4", []string{"coco", "watame", "kanata", "luna", "towa"}},
-							{"gen5", []string{"nene", "polka", "lamy", "botan", "aloe"}},
-							{"gen999", []string{}},
+							{Name: "gen4", Users: []string{"coco", "watame", "kanata", "luna", "towa"}},
+							{Name: "gen5", Users: []string{"nene", "polka", "lamy", "botan", "aloe"}},
+							{Name: "gen999", Users: []string{}},
 						},
 					}
 					Expect(render()).To(Equal(`
@@ -91,6 +92,29 @@ gen4 = coco, watame, kanata, luna, towa
 gen5 = nene, polka, lamy, botan, aloe
 gen999 = 
 
+`))
+				})
+			})
+
+			Context("when a group contains other groups", func() {
+				It("generates a list of groups followed by users", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{},
+						Users:        []svnconfig.User{},
+						Groups: []svnconfig.Group{
+							{Name: "holox", Users: []string{"laplus", "lui"}},
+							{Name: "hololive", Users: []string{"sora"}, Groups: []string{"gen0", "holox"}},
+							{Name: "gen0", Users: []string{"miko", "suisei"}},
+							{Name: "staff", Groups: []string{"hololive"}},
+						},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+holox = laplus, lui
+hololive = @gen0, @holox, sora
+gen0 = miko, suisei
+staff = @hololive
+
 `))
 				})
 			})
@@ -101,9 +125,9 @@ gen999 =
 				It("drops all permissions", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo", []svnconfig.Permission{}}},
+							{Name: "therepo", Permissions: []svnconfig.Permission{}}},
 						Groups: []svnconfig.Group{
-							{"fams", []string{"fubuki", "ayame", "mio", "subaru"}}},
+							{Name: "fams", Users: []string{"fubuki", "ayame", "mio", "subaru"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -120,11 +144,11 @@ fams = fubuki, ayame, mio, subaru
 				It("grants 'r' permission to the group", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo", []svnconfig.Permission{
-								{"smok", "r"},
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "smok", Permission: "r", Path: "/"},
 							}}},
 						Groups: []svnconfig.Group{
-							{"smok", []string{"subaru", "mio", "okayu", "korone"}}},
+							{Name: "smok", Users: []string{"subaru", "mio", "okayu", "korone"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -142,11 +166,11 @@ smok = subaru, mio, okayu, korone
 				It("grants 'rw' permission to the group", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo", []svnconfig.Permission{
-								{"idgen2", "rw"},
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "idgen2", Permission: "rw", Path: "/"},
 							}}},
 						Groups: []svnconfig.Group{
-							{"idgen2", []string{"ollie", "anya", "reine"}}},
+							{Name: "idgen2", Users: []string{"ollie", "anya", "reine"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -164,11 +188,11 @@ idgen2 = ollie, anya, reine
 				It("grants no permission to the group", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo", []svnconfig.Permission{
-								{"nenes", ""},
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "nenes", Permission: "", Path: "/"},
 							}}},
 						Groups: []svnconfig.Group{
-							{"nenes", []string{"nenechi", "supernenechi", "hypernenechi"}}},
+							{Name: "nenes", Users: []string{"nenechi", "supernenechi", "hypernenechi"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -186,13 +210,13 @@ nenes = nenechi, supernenechi, hypernenechi
 				It("grants corresponding permissions respectively", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo", []svnconfig.Permission{
-								{"board", "r"},
-								{"mountains", "rw"},
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "board", Permission: "r", Path: "/"},
+								{Group: "mountains", Permission: "rw", Path: "/"},
 							}}},
 						Groups: []svnconfig.Group{
-							{"board", []string{"shion", "rushia", "kanata", "gura"}},
-							{"mountains", []string{"choco", "noel", "coco"}}},
+							{Name: "board", Users: []string{"shion", "rushia", "kanata", "gura"}},
+							{Name: "mountains", Users: []string{"choco", "noel", "coco"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -212,24 +236,24 @@ mountains = choco, noel, coco
 				It("generates list of repositories and its permissions", func() {
 					config = &svnconfig.Generator{
 						Repositories: []svnconfig.Repository{
-							{"therepo1", []svnconfig.Permission{
-								{"edible", "r"},
+							{Name: "therepo1", Permissions: []svnconfig.Permission{
+								{Group: "edible", Permission: "r", Path: "/"},
 							}},
-							{"therepo2", []svnconfig.Permission{
-								{"edible", "rw"},
-								{"carnivore", "r"},
+							{Name: "therepo2", Permissions: []svnconfig.Permission{
+								{Group: "edible", Permission: "rw", Path: "/"},
+								{Group: "carnivore", Permission: "r", Path: "/"},
 							}},
-							{"therepo3", []svnconfig.Permission{
-								{"edible", ""},
-								{"carnivore", "r"},
+							{Name: "therepo3", Permissions: []svnconfig.Permission{
+								{Group: "edible", Permission: "", Path: "/"},
+								{Group: "carnivore", Permission: "r", Path: "/"},
 							}},
-							{"therepo4", []svnconfig.Permission{
-								{"carnivore", "rw"},
+							{Name: "therepo4", Permissions: []svnconfig.Permission{
+								{Group: "carnivore", Permission: "rw", Path: "/"},
 							}},
 						},
 						Groups: []svnconfig.Group{
-							{"edible", []string{"watame", "ina", "kiara"}},
-							{"carnivore", []string{"botan", "gura"}}},
+							{Name: "edible", Users: []string{"watame", "ina", "kiara"}},
+							{Name: "carnivore", Users: []string{"botan", "gura"}}},
 						Users: []svnconfig.User{},
 					}
 					Expect(render()).To(Equal(`
@@ -255,6 +279,371 @@ carnivore = botan, gura
 				})
 			})
 		})
+
+		Describe("anonymous access", func() {
+			Context("when anonymous users can read from the repository", func() {
+				It("grants 'r' permission to everyone", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "mirror", Permissions: []svnconfig.Permission{
+								{Group: "maintainers", Permission: "rw", Path: "/"},
+							}, AnonymousAccess: "r"},
+							{Name: "private", Permissions: []svnconfig.Permission{
+								{Group: "maintainers", Permission: "rw", Path: "/"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "maintainers", Users: []string{"towa"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+maintainers = towa
+[mirror:/]
+* = r
+@maintainers = rw
+[private:/]
+* = 
+@maintainers = rw
+
+`))
+				})
+			})
+		})
+
+		Describe("read-only repositories", func() {
+			It("reduces all permissions to 'r'", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "frozen", Permissions: []svnconfig.Permission{
+							{Group: "maintainers", Permission: "rw", Path: "/"},
+							{Permission: "rw", Path: "/trunk", User: "towa"},
+							{Permission: "", Path: "/secret", User: "towa"},
+						}, AnonymousAccess: "r", AuthenticatedAccess: "rw", ReadOnly: &svnconfig.ReadOnly{}}},
+					Groups: []svnconfig.Group{
+						{Name: "maintainers", Users: []string{"towa"}}},
+					Users: []svnconfig.User{},
+				}
+				Expect(render()).To(Equal(`
+[groups]
+maintainers = towa
+[frozen:/]
+* = r
+$authenticated = r
+@maintainers = r
+[frozen:/secret]
+towa = 
+[frozen:/trunk]
+towa = r
+
+`))
+			})
+
+			It("reduces all permissions of mirrors to 'r'", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "upstream", Permissions: []svnconfig.Permission{
+							{Group: "maintainers", Permission: "rw", Path: "/"},
+						}, AuthenticatedAccess: "rw", Source: &svnconfig.Source{Mirror: &svnconfig.MirrorSource{URL: "https://svn.example.com/repos/upstream"}}}},
+					Groups: []svnconfig.Group{
+						{Name: "maintainers", Users: []string{"towa"}}},
+					Users: []svnconfig.User{},
+				}
+				Expect(render()).To(Equal(`
+[groups]
+maintainers = towa
+[upstream:/]
+* = 
+$authenticated = r
+@maintainers = r
+
+`))
+			})
+		})
+
+		Describe("replication", func() {
+			It("lets the replication user read every path", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "therepo", Permissions: []svnconfig.Permission{
+							{Group: "writers", Permission: "rw", Path: "/"},
+							{Permission: "", Path: "/secret", User: "towa"},
+						}}},
+					Groups: []svnconfig.Group{
+						{Name: "writers", Users: []string{"towa"}}},
+					Users:       []svnconfig.User{},
+					Replication: &svnconfig.Replication{User: svnconfig.ReplicationUser},
+				}
+				Expect(render()).To(Equal(`
+[groups]
+writers = towa
+[therepo:/]
+* = 
+@writers = rw
+svn_operator_replica = r
+[therepo:/secret]
+towa = 
+svn_operator_replica = r
+
+`))
+			})
+		})
+
+		Describe("section [REPO_NAME:PATH]", func() {
+			Context("when a permission has a path", func() {
+				It("generates a separate section for the path", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "writers", Permission: "r", Path: "/"},
+								{Group: "writers", Permission: "rw", Path: "/trunk/docs"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "writers", Users: []string{"ame", "gura"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+writers = ame, gura
+[therepo:/]
+* = 
+@writers = r
+[therepo:/trunk/docs]
+@writers = rw
+
+`))
+				})
+			})
+
+			Context("when the path is empty", func() {
+				It("treats the permission as the one to the root path", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "readers", Permission: "r", Path: ""},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "readers", Users: []string{"ina"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+readers = ina
+[therepo:/]
+* = 
+@readers = r
+
+`))
+				})
+			})
+
+			Context("when there are permissions to more than one paths", func() {
+				It("generates sections sorted by path", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "docs", Permission: "rw", Path: "/trunk/docs"},
+								{Group: "release", Permission: "rw", Path: "/tags"},
+								{Group: "docs", Permission: "", Path: "/branches"},
+								{Group: "release", Permission: "r", Path: "/trunk/docs"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "docs", Users: []string{"kiara"}},
+							{Name: "release", Users: []string{"calli"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+docs = kiara
+release = calli
+[therepo:/]
+* = 
+[therepo:/branches]
+@docs = 
+[therepo:/tags]
+@release = rw
+[therepo:/trunk/docs]
+@docs = rw
+@release = r
+
+`))
+				})
+			})
+		})
+
+		Describe("permissions given to authenticated users", func() {
+			Context("when authenticated users have a permission", func() {
+				It("grants the permission to all users who have logged in", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "shared", Permissions: []svnconfig.Permission{
+								{Group: "maintainers", Permission: "rw", Path: "/"},
+							}, AuthenticatedAccess: "r"},
+							{Name: "private", Permissions: []svnconfig.Permission{
+								{Group: "maintainers", Permission: "rw", Path: "/"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "maintainers", Users: []string{"polka"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+maintainers = polka
+[shared:/]
+* = 
+$authenticated = r
+@maintainers = rw
+[private:/]
+* = 
+@maintainers = rw
+
+`))
+				})
+			})
+		})
+
+		Describe("duplicate permissions", func() {
+			Context("when the same group has more than one permission to the same path", func() {
+				It("merges them into the strongest one", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "readers", Permission: "r", Path: "/"},
+								{Permission: "", Path: "/", User: "ollie"},
+								{Group: "readers", Permission: "rw", Path: "/"},
+								{Group: "readers", Permission: "", Path: "/"},
+								{Permission: "r", Path: "/", User: "ollie"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "readers", Users: []string{"reine"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+readers = reine
+[therepo:/]
+* = 
+@readers = rw
+ollie = r
+
+`))
+				})
+			})
+		})
+
+		Describe("permissions given to users", func() {
+			Context("when a user has a permission", func() {
+				It("grants the permission to the user", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Permission: "r", Path: "/", User: "contractor"},
+							}}},
+						Groups: []svnconfig.Group{},
+						Users:  []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+[therepo:/]
+* = 
+contractor = r
+
+`))
+				})
+			})
+
+			Context("when both users and groups have permissions to the same path", func() {
+				It("generates user rules next to group rules", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "readers", Permission: "r", Path: "/"},
+								{Permission: "rw", Path: "/", User: "mori"},
+								{Permission: "r", Path: "/", User: "ina"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "readers", Users: []string{"mori", "kiara"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+readers = mori, kiara
+[therepo:/]
+* = 
+@readers = r
+mori = rw
+ina = r
+
+`))
+				})
+			})
+
+			Context("when users and groups have permissions to different paths", func() {
+				It("puts each rule into the section of its path", func() {
+					config = &svnconfig.Generator{
+						Repositories: []svnconfig.Repository{
+							{Name: "therepo", Permissions: []svnconfig.Permission{
+								{Group: "writers", Permission: "rw", Path: "/"},
+								{Permission: "", Path: "/secret", User: "gura"},
+								{Group: "writers", Permission: "r", Path: "/tags"},
+								{Permission: "rw", Path: "/tags", User: "ame"},
+							}}},
+						Groups: []svnconfig.Group{
+							{Name: "writers", Users: []string{"ame", "gura"}}},
+						Users: []svnconfig.User{},
+					}
+					Expect(render()).To(Equal(`
+[groups]
+writers = ame, gura
+[therepo:/]
+* = 
+@writers = rw
+[therepo:/secret]
+gura = 
+[therepo:/tags]
+@writers = r
+ame = rw
+
+`))
+				})
+			})
+		})
+	})
+
+	Describe("IsValidPath", func() {
+		DescribeTable("validates paths inside repositories",
+			func(path string, expected bool) {
+				Expect(svnconfig.IsValidPath(path)).To(Equal(expected))
+			},
+			Entry("root", "/", true),
+			Entry("a directory", "/trunk", true),
+			Entry("a nested directory", "/trunk/docs/api", true),
+			Entry("a path with spaces and dots", "/trunk/my docs/v1.0", true),
+			Entry("an empty path", "", false),
+			Entry("a relative path", "trunk", false),
+			Entry("a path with a trailing slash", "/trunk/", false),
+			Entry("a path with consecutive slashes", "/trunk//docs", false),
+			Entry("a path with brackets", "/trunk]\n[groups", false),
+			Entry("a path with a newline", "/trunk\n* = rw", false),
+		)
+	})
+
+	Describe("IsValidRelativePath", func() {
+		DescribeTable("validates paths relative to the root of repositories",
+			func(path string, expected bool) {
+				Expect(svnconfig.IsValidRelativePath(path)).To(Equal(expected))
+			},
+			Entry("a directory", "trunk", true),
+			Entry("a nested file", "trunk/docs/README.md", true),
+			Entry("a file with dots", "trunk/.svnignore..bak", true),
+			Entry("an empty path", "", false),
+			Entry("the root", ".", false),
+			Entry("an absolute path", "/trunk", false),
+			Entry("a path with a trailing slash", "trunk/", false),
+			Entry("a path with consecutive slashes", "trunk//docs", false),
+			Entry("a path to the parent", "../trunk", false),
+			Entry("a path that is not clean", "trunk/../tags", false),
+			Entry("a path with a newline", "trunk\nREADME", false),
+		)
 	})
 
 	Describe("AuthUserFile", func() {
@@ -308,6 +697,122 @@ noel:$2y$05$dM0mTvqGl8UqFgFY5CPxjO8jhqSntgSDlZeQK1XDwDKc2advIxEh6
 noel:$2y$05$dM0mTvqGl8UqFgFY5CPxjO8jhqSntgSDlZeQK1XDwDKc2advIxEh6
 coco:$2y$05$Vfm5k2KgyNIGMjoML44UNOXg1v2J7EqpeonrX8uuILRF9Oho/YLPy
 
+`))
+			})
+		})
+
+		Context("when the server has replicas", func() {
+			It("generates an entry of the replication user", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{},
+					Groups:       []svnconfig.Group{},
+					Users: []svnconfig.User{
+						{"noel", "$2y$05$dM0mTvqGl8UqFgFY5CPxjO8jhqSntgSDlZeQK1XDwDKc2advIxEh6"},
+					},
+					Replication: &svnconfig.Replication{
+						User:              svnconfig.ReplicationUser,
+						EncryptedPassword: "$2y$05$Vfm5k2KgyNIGMjoML44UNOXg1v2J7EqpeonrX8uuILRF9Oho/YLPy",
+					},
+				}
+				Expect(render()).To(Equal(`
+noel:$2y$05$dM0mTvqGl8UqFgFY5CPxjO8jhqSntgSDlZeQK1XDwDKc2advIxEh6
+svn_operator_replica:$2y$05$Vfm5k2KgyNIGMjoML44UNOXg1v2J7EqpeonrX8uuILRF9Oho/YLPy
+
+`))
+			})
+		})
+	})
+
+	Describe("ApacheConfig", func() {
+		var config *svnconfig.Generator
+		render := func() string {
+			result, err := config.ApacheConfig()
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+		paths := svnconfig.Paths{
+			ReposDir:           "/svn/repos",
+			AuthUserFile:       "/etc/svn-config/AuthUserFile",
+			AuthzSVNAccessFile: "/etc/svn-config/AuthzSVNAccessFile",
+		}
+
+		Context("when no repository can be accessed anonymously", func() {
+			It("requires all users to log in", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "private"},
+					},
+					Groups: []svnconfig.Group{},
+					Users:  []svnconfig.User{},
+					Paths:  paths,
+				}
+				Expect(render()).To(Equal(`
+<Location /repos/>
+  DAV svn
+  SVNParentPath /svn/repos
+  AuthType Basic
+  AuthName "SVN Server"
+  AuthUserFile /etc/svn-config/AuthUserFile
+  AuthzSVNAccessFile /etc/svn-config/AuthzSVNAccessFile
+  Require valid-user
+</Location>
+`))
+			})
+		})
+
+		Context("when some repositories can be accessed anonymously", func() {
+			It("lets AuthzSVNAccessFile decide whether users need to log in", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "private"},
+						{Name: "mirror", AnonymousAccess: "r"},
+					},
+					Groups: []svnconfig.Group{},
+					Users:  []svnconfig.User{},
+					Paths:  paths,
+				}
+				Expect(render()).To(Equal(`
+<Location /repos/>
+  DAV svn
+  SVNParentPath /svn/repos
+  AuthType Basic
+  AuthName "SVN Server"
+  AuthUserFile /etc/svn-config/AuthUserFile
+  AuthzSVNAccessFile /etc/svn-config/AuthzSVNAccessFile
+  # Anonymous users are authorized by AuthzSVNAccessFile.
+  # Others are asked for credentials only when they are needed.
+  Satisfy Any
+  Require valid-user
+</Location>
+`))
+			})
+		})
+
+		Context("when the server has replicas", func() {
+			It("proxies writes to replicas to the primary", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "private"},
+					},
+					Groups:      []svnconfig.Group{},
+					Users:       []svnconfig.User{},
+					Paths:       paths,
+					Replication: &svnconfig.Replication{User: svnconfig.ReplicationUser},
+				}
+				Expect(render()).To(Equal(`
+<Location /repos/>
+  DAV svn
+  SVNParentPath /svn/repos
+  AuthType Basic
+  AuthName "SVN Server"
+  AuthUserFile /etc/svn-config/AuthUserFile
+  AuthzSVNAccessFile /etc/svn-config/AuthzSVNAccessFile
+  <IfDefine SVNReplica>
+    # Replicas serve reads by themselves and proxy writes to the primary.
+    SVNMasterURI ${SVN_MASTER_URI}
+  </IfDefine>
+  Require valid-user
+</Location>
 `))
 			})
 		})
@@ -337,8 +842,8 @@ coco:$2y$05$Vfm5k2KgyNIGMjoML44UNOXg1v2J7EqpeonrX8uuILRF9Oho/YLPy
 			It("returns a list of repository names", func() {
 				config = &svnconfig.Generator{
 					Repositories: []svnconfig.Repository{
-						{"hoge", nil},
-						{"fuga", nil},
+						{Name: "hoge"},
+						{Name: "fuga"},
 					},
 					Groups: []svnconfig.Group{},
 					Users:  []svnconfig.User{},
@@ -349,5 +854,154 @@ coco:$2y$05$Vfm5k2KgyNIGMjoML44UNOXg1v2J7EqpeonrX8uuILRF9Oho/YLPy
 `))
 			})
 		})
+
+		Context("when repositories are being deleted", func() {
+			It("returns a list of deletions", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "hoge"},
+					},
+					Groups: []svnconfig.Group{},
+					Users:  []svnconfig.User{},
+					Deletions: []svnconfig.Deletion{
+						{Name: "fuga", Policy: svnconfig.DeletionPolicyArchive},
+						{Name: "piyo", Policy: svnconfig.DeletionPolicyDelete},
+					},
+				}
+				Expect(render()).To(Equal(`deletions:
+- name: fuga
+  policy: Archive
+- name: piyo
+  policy: Delete
+repositories:
+- name: hoge
+`))
+			})
+		})
+
+		Context("when repositories have initial content", func() {
+			It("returns the content except for pending repositories", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "hoge", Initial: &svnconfig.InitialContent{
+							Directories: []string{"trunk"},
+							Files:       []svnconfig.File{{Path: "trunk/README", Content: []byte("hello")}},
+						}},
+						{Name: "fuga", Pending: true},
+					},
+					Groups: []svnconfig.Group{},
+					Users:  []svnconfig.User{},
+				}
+				Expect(render()).To(Equal(`repositories:
+- initial:
+    directories:
+    - trunk
+    files:
+    - content: aGVsbG8=
+      path: trunk/README
+  name: hoge
+`))
+			})
+		})
+
+		Context("when repositories have hooks", func() {
+			It("returns the hooks", func() {
+				config = &svnconfig.Generator{
+					Repositories: []svnconfig.Repository{
+						{Name: "hoge", Hooks: []svnconfig.Hook{
+							{Name: "pre-commit", Script: []byte("#!/bin/sh\nexit 1\n")},
+						}},
+					},
+					Groups: []svnconfig.Group{},
+					Users:  []svnconfig.User{},
+				}
+				Expect(render()).To(Equal(`repositories:
+- hooks:
+  - name: pre-commit
+    script: IyEvYmluL3NoCmV4aXQgMQo=
+  name: hoge
+`))
+			})
+		})
+	})
+
+	Describe("ReposConfig with quotas", func() {
+		It("returns the limits of repositories and the server", func() {
+			config := &svnconfig.Generator{
+				Repositories: []svnconfig.Repository{
+					{Name: "hoge", MaxSize: 1024},
+				},
+				Groups:       []svnconfig.Group{},
+				Users:        []svnconfig.User{},
+				MaxTotalSize: 4096,
+			}
+			result, err := config.ReposConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(`maxTotalSize: 4096
+repositories:
+- maxSize: 1024
+  name: hoge
+`))
+		})
+	})
+
+	Describe("ReposConfig with creation options", func() {
+		It("returns the options of repositories", func() {
+			config := &svnconfig.Generator{
+				Repositories: []svnconfig.Repository{
+					{Name: "hoge", Create: &svnconfig.CreateOptions{
+						CompatibleVersion: "1.8",
+						UUID:              "6b3c1e2a-0000-4000-8000-000000000000",
+						FSFS:              &svnconfig.FSFSOptions{RevPropPackSize: 64},
+					}},
+				},
+				Groups: []svnconfig.Group{},
+				Users:  []svnconfig.User{},
+			}
+			result, err := config.ReposConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(`repositories:
+- create:
+    compatibleVersion: "1.8"
+    fsfs:
+      revPropPackSize: 64
+    uuid: 6b3c1e2a-0000-4000-8000-000000000000
+  name: hoge
+`))
+		})
+	})
+
+	Describe("ReposConfig with replicas", func() {
+		It("returns the server-updaters of the replicas", func() {
+			config := &svnconfig.Generator{
+				Repositories: []svnconfig.Repository{{Name: "hoge"}},
+				Groups:       []svnconfig.Group{},
+				Users:        []svnconfig.User{},
+				Replication: &svnconfig.Replication{
+					User:        svnconfig.ReplicationUser,
+					UpdaterURLs: []string{"http://svn-1.svn.default.svc:8081"},
+				},
+			}
+			result, err := config.ReposConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(`replicaUpdaterURLs:
+- http://svn-1.svn.default.svc:8081
+repositories:
+- name: hoge
+`))
+		})
+	})
+
+	Describe("IsValidHookName", func() {
+		It("accepts hooks that SVN supports", func() {
+			Expect(svnconfig.IsValidHookName("pre-commit")).To(BeTrue())
+			Expect(svnconfig.IsValidHookName("post-revprop-change")).To(BeTrue())
+		})
+
+		It("rejects other names", func() {
+			Expect(svnconfig.IsValidHookName("")).To(BeFalse())
+			Expect(svnconfig.IsValidHookName("pre-commit.tmpl")).To(BeFalse())
+			Expect(svnconfig.IsValidHookName("../pre-commit")).To(BeFalse())
+		})
 	})
 })
@@ -3,17 +3,36 @@ package svnconfig
 const rawTmplAuthzSVNAccessFile = `
 [groups]
 {{ range $gi, $g := .Groups -}}
-{{- $g.Name }} = {{ range $ui, $u := $g.Users -}}
+{{- $g.Name }} = {{ range $ni, $n := $g.Groups -}}
+{{- if gt $ni 0 -}}, {{ end -}}
+@{{- $n -}}
+{{- end -}}{{/* $g.Groups */}}
+{{- if and $g.Groups $g.Users -}}, {{ end -}}
+{{- range $ui, $u := $g.Users -}}
 {{- if gt $ui 0 -}}, {{ end -}}
 {{- $u -}}
 {{- end -}}{{/* $g.Users */}}
 {{ end -}}{{/* .Groups */}}
 {{- range $ri, $r := .Repositories -}}
-[{{- $r.Name -}}:/]
-* = 
-{{ range $pi, $p := $r.Permissions -}}
+{{- range $si, $s := $r.Sections -}}
+[{{- $r.Name -}}:{{- $s.Path -}}]
+{{ if eq $s.Path "/" -}}
+* = {{ $r.AnonymousAccess }}
+{{ if $r.AuthenticatedPermission -}}
+$authenticated = {{ $r.AuthenticatedPermission }}
+{{ end -}}
+{{ end -}}
+{{- range $pi, $p := $s.Permissions -}}
+{{- if $p.Group -}}
 @{{- $p.Group }} = {{ $p.Permission }}
+{{ else -}}
+{{- $p.User }} = {{ $p.Permission }}
+{{ end -}}
+{{- end -}}
+{{- with $.Replication -}}
+{{- .User }} = r
 {{ end -}}
+{{- end -}}{{/* $r.Sections */}}
 {{- end -}}{{/* .Repositories */}}
 `
 
@@ -21,4 +40,30 @@ const rawTmplAuthUserFile = `
 {{ range $ui, $u := .Users -}}
 {{- $u.Name}}:{{- $u.EncryptedPassword }}
 {{ end -}}{{/* .Users */}}
+{{- with .Replication -}}
+{{- .User }}:{{- .EncryptedPassword }}
+{{ end }}
+`
+
+const rawTmplApacheConfig = `
+<Location /repos/>
+  DAV svn
+  SVNParentPath {{ .Paths.ReposDir }}
+  AuthType Basic
+  AuthName "SVN Server"
+  AuthUserFile {{ .Paths.AuthUserFile }}
+  AuthzSVNAccessFile {{ .Paths.AuthzSVNAccessFile }}
+{{- if .Replication }}
+  <IfDefine SVNReplica>
+    # Replicas serve reads by themselves and proxy writes to the primary.
+    SVNMasterURI ${SVN_MASTER_URI}
+  </IfDefine>
+{{- end }}
+{{- if .AllowsAnonymousAccess }}
+  # Anonymous users are authorized by AuthzSVNAccessFile.
+  # Others are asked for credentials only when they are needed.
+  Satisfy Any
+{{- end }}
+  Require valid-user
+</Location>
 `